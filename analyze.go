@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+)
+
+// Usage: chip8 analyze [-platform name] rom.ch8
+func analyze(args []string) {
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	platformName := flags.String("platform", "chip8", "Memory layout to load the ROM into: chip8, vip, eti660, dream6800, hp48 or octo")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		fail("usage: chip8 analyze [-platform name] rom.ch8")
+	}
+
+	platform, err := chip8.ParsePlatform(*platformName)
+	if err != nil {
+		fail("%v", err)
+	}
+
+	rom, err := os.ReadFile(positional[0])
+	if err != nil {
+		fail("Unable to read ROM file: %v", err)
+	}
+	analysis, err := chip8.AnalyzeQuirks(rom, platform)
+	if err != nil {
+		fail("%v", err)
+	}
+
+	fmt.Printf("Instruction set: %s\n\n", analysis.InstructionSet)
+	if len(analysis.Findings) == 0 {
+		fmt.Println("No quirk-sensitive instructions were found")
+	} else {
+		fmt.Println("Quirk-sensitive instructions:")
+		for _, finding := range analysis.Findings {
+			quirk := finding.Quirk
+			if quirk == "" {
+				quirk = "no quirk"
+			}
+			fmt.Printf("  0x%03X  %04X  %-16s %s: %s\n", finding.Address, finding.Instruction, chip8.Disassemble(finding.Instruction), quirk, finding.Reason)
+		}
+	}
+	var independent []string
+	for _, quirk := range chip8.QuirkNames {
+		// The analyzer doesn't look at timing, so it can't tell
+		if quirk != "displayWait" && !analysis.DependsOn(quirk) {
+			independent = append(independent, quirk)
+		}
+	}
+	if len(independent) > 0 {
+		fmt.Printf("\nNo reachable code depends on: %s\n", strings.Join(independent, ", "))
+	}
+
+	fmt.Printf("\nRecommended platform: %s (-platform %s)\n", analysis.Platform, analysis.Platform)
+	fmt.Printf("Recommended quirks:   %s\n", describeQuirks(analysis.Quirks))
+	for _, reason := range analysis.Reasons {
+		fmt.Printf("  %s\n", reason)
+	}
+
+	// Ready to be added to a ROM database for -romdb
+	entry, _ := json.MarshalIndent([]any{struct {
+		SHA1     string       `json:"sha1"`
+		Title    string       `json:"title"`
+		Platform string       `json:"platform"`
+		Quirks   chip8.Quirks `json:"quirks"`
+	}{
+		SHA1:     chip8.ROMHash(rom),
+		Title:    strings.TrimSuffix(filepath.Base(positional[0]), filepath.Ext(positional[0])),
+		Platform: analysis.Platform,
+		Quirks:   analysis.Quirks,
+	}}, "", "\t")
+	fmt.Printf("\nROM database entry:\n%s\n", entry)
+}
@@ -0,0 +1,109 @@
+package chip8
+
+import "sort"
+
+/*
+ControlFlow is the result of statically tracing a program from its entry
+point, following every path a jump, call, return or skip could take. Code
+that can only be reached through a computed jump (BNNN), or that is written
+at runtime, can't be found this way.
+*/
+type ControlFlow struct {
+	// Addresses of every reachable instruction
+	Instructions map[uint16]bool
+	// Targets of 1NNN jumps
+	JumpTargets map[uint16]bool
+	// Targets of 2NNN calls
+	Subroutines map[uint16]bool
+	// Addresses of BNNN instructions, whose targets depend on V0
+	ComputedJumps []uint16
+}
+
+// A contiguous run of reachable instructions, from Start up to but not including End
+type Region struct {
+	Start uint16
+	End   uint16
+}
+
+// Traces a ROM loaded at the usual program start address
+func AnalyzeROM(rom []byte) *ControlFlow {
+	memory := make([]byte, 4096)
+	copy(memory[memoryStartIndexForGame:], rom)
+	return AnalyzeControlFlow(memory, memoryStartIndexForGame)
+}
+
+func AnalyzeControlFlow(memory []byte, entry uint16) *ControlFlow {
+	flow := &ControlFlow{
+		Instructions: map[uint16]bool{},
+		JumpTargets:  map[uint16]bool{},
+		Subroutines:  map[uint16]bool{},
+	}
+
+	pending := []uint16{entry}
+	for len(pending) > 0 {
+		address := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if int(address)+1 >= len(memory) || flow.Instructions[address] {
+			continue
+		}
+		flow.Instructions[address] = true
+
+		instruction := uint16(memory[address])<<8 | uint16(memory[address+1])
+		next := address + 2
+		switch {
+		case instruction == 0x00EE:
+		case instruction>>12 == 0x1:
+			flow.JumpTargets[nnn(instruction)] = true
+			pending = append(pending, nnn(instruction))
+		case instruction>>12 == 0x2:
+			flow.Subroutines[nnn(instruction)] = true
+			pending = append(pending, nnn(instruction), next)
+		case instruction>>12 == 0xB:
+			flow.ComputedJumps = append(flow.ComputedJumps, address)
+		case isSkip(instruction):
+			pending = append(pending, next, next+2)
+		default:
+			pending = append(pending, next)
+		}
+	}
+
+	sort.Slice(flow.ComputedJumps, func(i, j int) bool {
+		return flow.ComputedJumps[i] < flow.ComputedJumps[j]
+	})
+	return flow
+}
+
+func isSkip(instruction uint16) bool {
+	switch instruction >> 12 {
+	case 0x3, 0x4, 0x5, 0x9:
+		return true
+	case 0xE:
+		return instruction&0xFF == 0x9E || instruction&0xFF == 0xA1
+	}
+	return false
+}
+
+// Returns the reachable instruction addresses in ascending order
+func (flow *ControlFlow) Addresses() []uint16 {
+	addresses := make([]uint16, 0, len(flow.Instructions))
+	for address := range flow.Instructions {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i] < addresses[j]
+	})
+	return addresses
+}
+
+// Groups the reachable instructions into contiguous regions
+func (flow *ControlFlow) Regions() []Region {
+	var regions []Region
+	for _, address := range flow.Addresses() {
+		if len(regions) > 0 && regions[len(regions)-1].End == address {
+			regions[len(regions)-1].End = address + 2
+		} else {
+			regions = append(regions, Region{Start: address, End: address + 2})
+		}
+	}
+	return regions
+}
@@ -0,0 +1,48 @@
+package chip8
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeControlFlow(t *testing.T) {
+	flow := AnalyzeROM([]byte{
+		0x22, 0x0A, // 200: CALL 0x20A
+		0x30, 0x01, // 202: SE V0, 0x01
+		0x12, 0x08, // 204: JP 0x208
+		0xB3, 0x00, // 206: JP V0, 0x300
+		0x12, 0x08, // 208: JP 0x208
+		0x60, 0x01, // 20A: LD V0, 0x01
+		0x00, 0xEE, // 20C: RET
+		0xFF, 0xFF, // 20E: data
+	})
+
+	expected := []uint16{0x200, 0x202, 0x204, 0x206, 0x208, 0x20A, 0x20C}
+	if addresses := flow.Addresses(); !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("Incorrect reachable instructions %X", addresses)
+	}
+	if !flow.Subroutines[0x20A] || !flow.JumpTargets[0x208] {
+		t.Error("Call and jump targets were not recorded")
+	}
+	if !reflect.DeepEqual(flow.ComputedJumps, []uint16{0x206}) || flow.Instructions[0x300] {
+		t.Error("Computed jump was followed or not recorded")
+	}
+	if regions := flow.Regions(); !reflect.DeepEqual(regions, []Region{{0x200, 0x20E}}) {
+		t.Errorf("Incorrect regions %v", regions)
+	}
+}
+
+func TestAnalyzeControlFlowSkipsData(t *testing.T) {
+	flow := AnalyzeROM([]byte{
+		0x12, 0x04, // 200: JP 0x204
+		0xAB, 0xCD, // 202: data
+		0x12, 0x04, // 204: JP 0x204
+	})
+
+	if flow.Instructions[0x202] {
+		t.Error("Data that is jumped over was marked as reachable")
+	}
+	if regions := flow.Regions(); len(regions) != 2 {
+		t.Errorf("Incorrect regions %v", regions)
+	}
+}
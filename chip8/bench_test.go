@@ -0,0 +1,71 @@
+package chip8
+
+import "testing"
+
+// Register arithmetic in a tight loop, typical of game logic
+var aluLoopROM = []byte{
+	0x60, 0x00, // 200: LD V0, 0x00
+	0x61, 0x01, // 202: LD V1, 0x01
+	0x80, 0x14, // 204: ADD V0, V1
+	0x82, 0x06, // 206: SHR V2, V0
+	0x83, 0x23, // 208: XOR V3, V2
+	0x43, 0x00, // 20A: SNE V3, 0x00
+	0x74, 0x01, // 20C: ADD V4, 0x01
+	0x12, 0x04, // 20E: JP 0x204
+}
+
+// Draws font sprites across the screen
+var spriteLoopROM = []byte{
+	0xF0, 0x29, // 200: LD F, V0
+	0xD0, 0x15, // 202: DRW V0, V1, 5
+	0x70, 0x05, // 204: ADD V0, 0x05
+	0x71, 0x01, // 206: ADD V1, 0x01
+	0x12, 0x00, // 208: JP 0x200
+}
+
+// Memory, subroutine and random number heavy code, as used for scores and game state
+var memoryLoopROM = []byte{
+	0xA3, 0x00, // 200: LD I, 0x300
+	0xF0, 0x33, // 202: LD B, V0
+	0xF2, 0x65, // 204: LD V2, [I]
+	0x70, 0x01, // 206: ADD V0, 0x01
+	0xA3, 0x00, // 208: LD I, 0x300
+	0xF2, 0x55, // 20A: LD [I], V2
+	0x22, 0x10, // 20C: CALL 0x210
+	0x12, 0x00, // 20E: JP 0x200
+	0xC0, 0xFF, // 210: RND V0, 0xFF
+	0x00, 0xEE, // 212: RET
+}
+
+func benchmarkROM(b *testing.B, rom []byte) {
+	chip := newTestChip(rom)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		chip.Step()
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds()/1e6, "MIPS")
+}
+
+func BenchmarkALULoop(b *testing.B) {
+	benchmarkROM(b, aluLoopROM)
+}
+
+func BenchmarkSpriteLoop(b *testing.B) {
+	benchmarkROM(b, spriteLoopROM)
+}
+
+func BenchmarkMemoryLoop(b *testing.B) {
+	benchmarkROM(b, memoryLoopROM)
+}
+
+// Includes the cost of reading the clock to check for a timer tick on every cycle
+func BenchmarkExecuteCycle(b *testing.B) {
+	chip := newTestChip(aluLoopROM)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		chip.ExecuteCycle()
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds()/1e6, "MIPS")
+}
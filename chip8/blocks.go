@@ -0,0 +1,276 @@
+package chip8
+
+const maxBlockLength = 64
+
+// A translated instruction, with its operands already decoded
+type compiledInstruction func(chip *Chip) bool
+
+/*
+block is a translated run of straight-line code, ending with the first
+instruction that can change the flow of control (a jump, call, return,
+skip or key wait), fault or write to memory, or after maxBlockLength
+instructions. Only the last instruction can stop the rest of the block
+from running, so the engine runs blocks without checking anything between
+instructions.
+*/
+type block struct {
+	start        uint16
+	instructions []compiledInstruction
+}
+
+func (b *block) end() int {
+	return int(b.start) + 2*len(b.instructions)
+}
+
+/*
+BlockEngine is an alternative to calling Step in a loop, meant for headless
+workloads that execute very large numbers of instructions. It translates
+basic blocks of CHIP-8 code into chains of Go closures the first time they
+are reached and reuses them afterwards.
+
+Since CHIP-8 programs can modify their own code, every write to memory that
+goes through the chip (FX33, FX55 and WriteMemory) invalidates the cached
+blocks that overlap it. The engine updates the chip's state after every
+instruction exactly as Step would, so it can be stopped after any number of
+instructions and swapped with the interpreter at any point.
+*/
+type BlockEngine struct {
+	chip *Chip
+	// Cached blocks by start address
+	blocks []*block
+	// Blocks that contain each address, used for invalidation
+	blocksByAddress [][]*block
+	// Range of addresses that blocks have ever been compiled from, so that
+	// writes to data elsewhere can be let through without looking them up
+	codeStart, codeEnd int
+}
+
+func NewBlockEngine(chip *Chip) *BlockEngine {
+	engine := &BlockEngine{
+		chip:            chip,
+		blocks:          make([]*block, len(chip.memory)),
+		blocksByAddress: make([][]*block, len(chip.memory)),
+		codeStart:       len(chip.memory),
+	}
+	chip.memoryWriteHooks = append(chip.memoryWriteHooks, engine.invalidate)
+	return engine
+}
+
+/*
+Run executes up to cycles instructions and returns how many were executed,
+along with whether any of them updated the screen. Like Step, it doesn't
+touch the timers.
+*/
+func (engine *BlockEngine) Run(cycles int) (int, bool) {
+	chip := engine.chip
+	executed := 0
+	screenUpdated := false
+	for executed < cycles && chip.fault == nil {
+		// Blocks are only ever compiled where there is an instruction to fetch
+		var b *block
+		if pc := int(chip.programCounter); pc < len(engine.blocks) {
+			b = engine.blocks[pc]
+		}
+		if b == nil {
+			if !chip.canFetch() {
+				break
+			}
+			b = engine.compile(chip.programCounter)
+		}
+		instructions := b.instructions
+		if remaining := cycles - executed; len(instructions) > remaining {
+			instructions = instructions[:remaining]
+		}
+		for _, instruction := range instructions {
+			chip.programCounter += 2
+			if instruction(chip) {
+				screenUpdated = true
+			}
+		}
+		executed += len(instructions)
+	}
+	return executed, screenUpdated
+}
+
+// Must only be called with a start address that canFetch has checked
+func (engine *BlockEngine) compile(start uint16) *block {
+	memory := engine.chip.memory[:]
+
+	b := &block{start: start}
+	for address := int(start); address+1 < len(memory) && len(b.instructions) < maxBlockLength; address += 2 {
+		instruction := uint16(memory[address])<<8 | uint16(memory[address+1])
+		// Custom handlers can do anything, including jump
+		if engine.chip.hasOpcodeHandler(instruction) {
+			b.instructions = append(b.instructions, engine.chip.customInstruction(instruction))
+			break
+		}
+		b.instructions = append(b.instructions, compileInstruction(instruction))
+		if endsBlock(instruction) || engine.needsCheck(instruction) {
+			break
+		}
+	}
+
+	engine.blocks[start] = b
+	if int(start) < engine.codeStart {
+		engine.codeStart = int(start)
+	}
+	if b.end() > engine.codeEnd {
+		engine.codeEnd = b.end()
+	}
+	for address := int(start); address < b.end() && address < len(memory); address++ {
+		engine.blocksByAddress[address] = append(engine.blocksByAddress[address], b)
+	}
+	return b
+}
+
+func (engine *BlockEngine) invalidate(address uint16, length int) {
+	end := int(address) + length
+	if end <= len(engine.blocksByAddress) && (end <= engine.codeStart || int(address) >= engine.codeEnd) {
+		return
+	}
+	for a := int(address); a < end; a++ {
+		for _, b := range engine.blocksByAddress[a%len(engine.blocksByAddress)] {
+			engine.unlink(b)
+		}
+	}
+}
+
+// Removes a block from the cache
+func (engine *BlockEngine) unlink(b *block) {
+	if engine.blocks[b.start] == b {
+		engine.blocks[b.start] = nil
+	}
+	for address := int(b.start); address < b.end() && address < len(engine.blocksByAddress); address++ {
+		blocks := engine.blocksByAddress[address]
+		for i, other := range blocks {
+			if other == b {
+				engine.blocksByAddress[address] = append(blocks[:i:i], blocks[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Reports whether the instruction can continue anywhere other than the next instruction
+func endsBlock(instruction uint16) bool {
+	switch instruction >> 12 {
+	case 0x0:
+		return instruction == 0x00EE
+	case 0x1, 0x2, 0x3, 0x4, 0x5, 0x9, 0xB, 0xE:
+		return true
+	case 0xF:
+		return instruction&0xFF == 0x0A
+	}
+	return false
+}
+
+/*
+Reports whether the instruction can fault or write to memory. Either one
+has to be noticed before any more of the block runs, since a faulted chip
+stops and the write may have overwritten the block.
+*/
+func (engine *BlockEngine) needsCheck(instruction uint16) bool {
+	// Unknown opcodes can fault, or call a callback that changes anything
+	if !implementedInstructions[instruction] {
+		return true
+	}
+	faults := engine.chip.memoryAccess == FaultMemoryAccess
+	switch {
+	case instruction&0xF0FF == 0xF033, instruction&0xF0FF == 0xF055:
+		return true
+	case instruction&0xF0FF == 0xF030:
+		// Faults for a glyph the font doesn't have
+		return len(engine.chip.font.Big) != 0 && len(engine.chip.font.Big) < 16*bigGlyphSize
+	case instruction&0xF0FF == 0xF065, instruction>>12 == 0xD:
+		return faults
+	}
+	return false
+}
+
+/*
+Translates an instruction into a closure. The most common instructions get
+specialised closures with their operands captured, and everything else
+calls the interpreter's handler.
+*/
+func compileInstruction(instruction uint16) compiledInstruction {
+	vx := x(instruction)
+	vy := y(instruction)
+	value := nn(instruction)
+	address := nnn(instruction)
+
+	switch instruction >> 12 {
+	case 0x0:
+		if instruction == 0x00EE {
+			return func(chip *Chip) bool {
+				// The handler raises the fault
+				if chip.stackPointer == 0 {
+					return op00EE(chip, instruction)
+				}
+				chip.stackPointer--
+				chip.programCounter = chip.stack[chip.stackPointer]
+				return false
+			}
+		}
+	case 0x1:
+		return func(chip *Chip) bool {
+			chip.programCounter = address
+			return false
+		}
+	case 0x2:
+		return func(chip *Chip) bool {
+			if chip.stackPointer == len(chip.stack) {
+				return op2NNN(chip, instruction)
+			}
+			chip.stack[chip.stackPointer] = chip.programCounter
+			chip.stackPointer++
+			chip.programCounter = address
+			return false
+		}
+	case 0x3:
+		return func(chip *Chip) bool {
+			if chip.generalRegisters[vx] == value {
+				chip.programCounter += 2
+			}
+			return false
+		}
+	case 0x4:
+		return func(chip *Chip) bool {
+			if chip.generalRegisters[vx] != value {
+				chip.programCounter += 2
+			}
+			return false
+		}
+	case 0x6:
+		return func(chip *Chip) bool {
+			chip.generalRegisters[vx] = value
+			return false
+		}
+	case 0x7:
+		return func(chip *Chip) bool {
+			chip.generalRegisters[vx] += value
+			return false
+		}
+	case 0x8:
+		if instruction&0xF == 0x0 {
+			return func(chip *Chip) bool {
+				chip.generalRegisters[vx] = chip.generalRegisters[vy]
+				return false
+			}
+		}
+	case 0xA:
+		return func(chip *Chip) bool {
+			chip.indexRegister = address
+			return false
+		}
+	case 0xC:
+		return func(chip *Chip) bool {
+			chip.generalRegisters[vx] = value & byte(chip.rng.Intn(256))
+			return false
+		}
+	}
+
+	handler := instructionTable[instruction]
+	return func(chip *Chip) bool {
+		return handler(chip, instruction)
+	}
+}
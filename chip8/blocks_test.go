@@ -0,0 +1,159 @@
+package chip8
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// Overwrites a subroutine after it has been run once, then an instruction
+// later on in the block that is currently executing, and finally the
+// target of a jump with FX33
+var selfModifyingROM = []byte{
+	0xA2, 0x30, // 200: LD I, 0x230
+	0x60, 0x65, // 202: LD V0, 0x65
+	0x61, 0x77, // 204: LD V1, 0x77
+	0x22, 0x30, // 206: CALL 0x230
+	0xF1, 0x55, // 208: LD [I], V1 (0x230 becomes LD V5, 0x77)
+	0x22, 0x30, // 20A: CALL 0x230
+	0xA2, 0x12, // 20C: LD I, 0x212
+	0xF1, 0x55, // 20E: LD [I], V1 (0x212 becomes LD V5, 0x77)
+	0x66, 0x01, // 210: LD V6, 0x01
+	0x67, 0x01, // 212: LD V7, 0x01
+	0x62, 0x64, // 214: LD V2, 0x64
+	0xA2, 0x1C, // 216: LD I, 0x21C
+	0xF2, 0x33, // 218: LD B, V2 (0x21C-0x21E become 01 00 00)
+	0x12, 0x1C, // 21A: JP 0x21C
+	0x12, 0x1C, // 21C: JP 0x21C, then SYS 0x100
+	0x00, 0x00, // 21E: SYS 0x000
+	0x12, 0x20, // 220: JP 0x220
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // 222-22F
+	0x64, 0x01, // 230: LD V4, 0x01
+	0x00, 0xEE, // 232: RET
+}
+
+func newSeededChip(rom []byte, options ...Option) *Chip {
+	return newTestChip(rom, append([]Option{WithRandomSource(rand.NewSource(1))}, options...)...)
+}
+
+// Returns a description of the first difference between the two chips' states, if any
+func diffChips(a, b *Chip) string {
+	return strings.Join(compareChips(a, b), ", ")
+}
+
+func runDifferential(t *testing.T, rom []byte, cycles int) {
+	t.Helper()
+	interpreted := newSeededChip(rom)
+	translated := newSeededChip(rom)
+	engine := NewBlockEngine(translated)
+
+	for i := 0; i < cycles; i++ {
+		interpretedScreen := interpreted.Step()
+		executed, translatedScreen := engine.Run(1)
+
+		if executed != 1 {
+			t.Fatalf("Cycle %d: engine executed %d instructions", i, executed)
+		}
+		if interpretedScreen != translatedScreen {
+			t.Fatalf("Cycle %d: screen update %t != %t", i, interpretedScreen, translatedScreen)
+		}
+		if diff := diffChips(interpreted, translated); diff != "" {
+			t.Fatalf("Cycle %d at %03X: %s", i, interpreted.programCounter, diff)
+		}
+	}
+}
+
+func TestBlockEngineMatchesInterpreter(t *testing.T) {
+	roms := map[string][]byte{
+		"alu":            aluLoopROM,
+		"sprite":         spriteLoopROM,
+		"memory":         memoryLoopROM,
+		"self-modifying": selfModifyingROM,
+	}
+	for name, rom := range roms {
+		t.Run(name, func(t *testing.T) {
+			runDifferential(t, rom, 5000)
+		})
+	}
+}
+
+func TestBlockEngineSelfModifyingCode(t *testing.T) {
+	chip := newSeededChip(selfModifyingROM)
+	engine := NewBlockEngine(chip)
+	engine.Run(100)
+
+	if chip.generalRegisters[5] != 0x77 {
+		t.Error("Overwritten subroutine was not retranslated")
+	}
+	if chip.generalRegisters[6] != 0x01 || chip.generalRegisters[7] != 0x00 {
+		t.Error("Overwritten instruction in the running block was executed")
+	}
+	if chip.programCounter != 0x220 {
+		t.Error("Code overwritten by FX33 was not retranslated")
+	}
+}
+
+func TestBlockEngineRunsInBatches(t *testing.T) {
+	interpreted := newSeededChip(memoryLoopROM)
+	translated := newSeededChip(memoryLoopROM)
+	engine := NewBlockEngine(translated)
+
+	for i := 0; i < 1000; i++ {
+		interpreted.Step()
+	}
+	executed, _ := engine.Run(1000)
+
+	if executed != 1000 {
+		t.Errorf("Engine executed %d instructions", executed)
+	}
+	if diff := diffChips(interpreted, translated); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestBlockEngineHostWriteInvalidates(t *testing.T) {
+	chip := newSeededChip([]byte{0x60, 0x01, 0x12, 0x00})
+	engine := NewBlockEngine(chip)
+	engine.Run(2)
+
+	chip.WriteMemory(0x201, 0x02)
+	engine.Run(1)
+
+	if chip.generalRegisters[0] != 0x02 {
+		t.Error("Block was not invalidated by a host write")
+	}
+}
+
+func TestBlockEngineWithRecompiledCode(t *testing.T) {
+	chip := newSeededChip([]byte{0x60, 0x05, 0x70, 0x01, 0x12, 0x02})
+	chip.UseRecompiledCode(recompiledCounter)
+	engine := NewBlockEngine(chip)
+	engine.Run(2)
+
+	// Turns ADD V0, 0x01 into ADD V0, 0x10
+	chip.WriteMemory(0x203, 0x10)
+	engine.Run(2)
+	if chip.generalRegisters[0] != 0x16 {
+		t.Error("Block was not invalidated by a host write")
+	}
+	if !chip.runtime.modified[0x203] {
+		t.Error("Recompiled code was not told about the host write")
+	}
+}
+
+func BenchmarkBlockEngineALULoop(b *testing.B) {
+	benchmarkBlockEngine(b, aluLoopROM)
+}
+
+func BenchmarkBlockEngineMemoryLoop(b *testing.B) {
+	benchmarkBlockEngine(b, memoryLoopROM)
+}
+
+func benchmarkBlockEngine(b *testing.B, rom []byte) {
+	engine := NewBlockEngine(newTestChip(rom))
+	b.ReportAllocs()
+	b.ResetTimer()
+	engine.Run(b.N)
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds()/1e6, "MIPS")
+}
@@ -1,42 +1,22 @@
 package chip8
 
 import (
-	"crypto/rand"
+	cryptorand "crypto/rand"
 	"encoding/binary"
-	"math"
+	"math/rand"
 	"time"
 )
 
 const (
 	flagRegisterIndex       = 15
-	memoryStartIndexForFont = uint16(0x50)
 	memoryStartIndexForGame = uint16(0x200)
 	pixelsWidth             = 64
 	pixelsHeight            = 32
 	timerRateHz             = 60
 )
 
-var font = [80]byte{
-	0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
-	0x20, 0x60, 0x20, 0x20, 0x70, // 1
-	0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
-	0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
-	0x90, 0x90, 0xF0, 0x10, 0x10, // 4
-	0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
-	0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
-	0xF0, 0x10, 0x20, 0x40, 0x40, // 7
-	0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
-	0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
-	0xF0, 0x90, 0xF0, 0x90, 0x90, // A
-	0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
-	0xF0, 0x80, 0x80, 0x80, 0xF0, // C
-	0xE0, 0x90, 0x90, 0x90, 0xE0, // D
-	0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
-	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
-}
-
 type Chip struct {
-	memory           [4096]byte
+	memory           []byte
 	programCounter   uint16
 	indexRegister    uint16
 	stack            [16]uint16
@@ -52,277 +32,310 @@ type Chip struct {
 	// which keys were formerly pressed and now released
 	previousKeys [16]bool
 	keys         [16]bool
-	delayTicker  *time.Ticker
-	soundTicker  *time.Ticker
+	// Time of the last timer tick that ExecuteCycle counted
+	lastTimerTick time.Time
+	rng           *rand.Rand
+	// The source behind rng if it was created from a seed, which save states copy
+	random *seededSource
+	// Called before memory is written to, used by BlockEngine and Runtime to
+	// invalidate code that has been translated
+	memoryWriteHooks []func(address uint16, length int)
+	// Set by UseRecompiledCode
+	runtime *Runtime
+	// The shared instructionTable, or a copy of it with the handlers
+	// registered by HandleOpcodes
+	instructions *[0x10000]instructionHandler
+	// Patterns claimed by HandleOpcodes
+	opcodePatterns []opcodePattern
+
+	// Set by options and kept across resets
+	rom             []byte
+	romInfo         ROMInfo
+	platform        Platform
+	quirks          Quirks
+	font            Font
+	memoryAccess    MemoryAccessPolicy
+	unknownOpcodes  UnknownOpcodePolicy
+	executionRateHz int
+	clock           Clock
+	callbacks       Callbacks
+	// Fraction of an instruction carried over between calls to RunFrame
+	cycleBudget float64
+	soundOn     bool
+	fault       *Fault
+	// Number of times each unknown opcode was executed, keyed by its
+	// address and instruction. Kept across resets, but not LoadROM.
+	unknownOpcodeCounts map[uint32]int
 }
 
-func NewChip(fileBytes []byte) *Chip {
-	pixels := make([][]bool, pixelsWidth)
-	for i := range pixels {
-		pixels[i] = make([]bool, pixelsHeight)
+// FrameResult describes what happened during a call to RunFrame
+type FrameResult struct {
+	Instructions  int
+	ScreenUpdated bool
+	SoundOn       bool
+	// Set when the program is blocked on FX0A until a key is released
+	WaitingForKey bool
+	// Set if the chip has faulted
+	Fault *Fault
+}
+
+/*
+NewChip creates a chip with the ROM loaded at the program start address,
+or returns an error if the ROM can't be loaded. Without any options it
+behaves like the COSMAC VIP interpreter, with a randomly seeded RNG,
+timers driven by the system clock and an execution rate of 700
+instructions per second for RunFrame.
+*/
+func NewChip(fileBytes []byte, options ...Option) (*Chip, error) {
+	chip := &Chip{
+		platform:        DefaultPlatform,
+		quirks:          DefaultQuirks,
+		font:            DefaultFont,
+		executionRateHz: defaultExecutionRateHz,
+		clock:           systemClock{},
+		instructions:    &instructionTable,
+	}
+	for _, option := range options {
+		option(chip)
+	}
+	if err := chip.font.validate(); err != nil {
+		return nil, err
+	}
+	if err := chip.platform.validate(chip.font); err != nil {
+		return nil, err
+	}
+	if chip.rng == nil {
+		chip.random = newSeededSource(randomSeed())
+		chip.rng = rand.New(chip.random)
 	}
 
-	chip := Chip{
-		programCounter: 0x200,
-		Pixels:         pixels,
-		delayTicker:    time.NewTicker(time.Second / timerRateHz),
-		soundTicker:    time.NewTicker(time.Second / timerRateHz),
+	chip.memory = make([]byte, chip.platform.MemorySize)
+	chip.Pixels = make([][]bool, pixelsWidth)
+	for i := range chip.Pixels {
+		chip.Pixels[i] = make([]bool, pixelsHeight)
+	}
+	if err := chip.LoadROM(fileBytes); err != nil {
+		return nil, err
 	}
-	chip.loadGameIntoMemory(fileBytes)
-	chip.loadFontIntoMemory()
-	return &chip
+	return chip, nil
 }
 
-func (chip *Chip) ExecuteCycle() bool {
-	/* Note that the tickers are unnecessary when their corresponding values
-	are 0, and thus can sometimes be wasteful. However, since they only
-	tick at a rate of 60Hz, this is a fine tradeoff for now. A better
-	solution may be to pause the ticker when its corresponding value is 0. */
-	select {
-	case <-chip.delayTicker.C:
-		chip.decrementDelayTimer()
-	case <-chip.soundTicker.C:
-		chip.decrementSoundTimer()
-	default:
+/*
+Reset puts the chip back in the state it was in when it was created, with
+the current ROM reloaded. The options it was created with still apply.
+*/
+func (chip *Chip) Reset() {
+	memory := make([]byte, len(chip.memory))
+	copy(memory[chip.platform.FontAddress:], chip.font.Small)
+	copy(memory[chip.bigFontAddress():], chip.font.Big)
+	copy(memory[chip.platform.ProgramStart:], chip.rom)
+	// Only the bytes that actually change invalidate translated code
+	for address := range memory {
+		if memory[address] != chip.memory[address] {
+			chip.memoryWritten(uint16(address), 1)
+		}
 	}
-	instruction := chip.fetchInstruction()
-	screenUpdated := chip.executeInstruction(instruction)
-	return screenUpdated
+	copy(chip.memory, memory)
+
+	chip.programCounter = chip.platform.ProgramStart
+	chip.indexRegister = 0
+	chip.stack = [16]uint16{}
+	chip.stackPointer = 0
+	chip.delayTimerValue = 0
+	chip.SoundTimerValue = 0
+	chip.generalRegisters = [16]byte{}
+	for _, column := range chip.Pixels {
+		for j := range column {
+			column[j] = false
+		}
+	}
+	chip.waitingOnKeyRelease = false
+	chip.previousKeys = [16]bool{}
+	chip.keys = [16]bool{}
+	chip.lastTimerTick = chip.clock.Now()
+	chip.cycleBudget = 0
+	chip.soundOn = false
+	chip.fault = nil
 }
 
-func (chip *Chip) fetchInstruction() uint16 {
-	if chip.programCounter+1 > uint16(len(chip.memory)) {
-		panic("Instruction is out of bounds")
+// Replaces the ROM and resets the chip. If the ROM isn't valid, the chip is left unchanged.
+func (chip *Chip) LoadROM(rom []byte) error {
+	info, err := ValidateROM(rom, chip.platform)
+	if err != nil {
+		return err
 	}
-	currInstruction := binary.BigEndian.Uint16(chip.memory[chip.programCounter : chip.programCounter+2])
-	chip.programCounter += 2
-	return currInstruction
+	chip.romInfo = info
+	chip.rom = append([]byte(nil), rom...)
+	chip.unknownOpcodeCounts = nil
+	chip.Reset()
+	return nil
 }
 
-func (chip *Chip) executeInstruction(instruction uint16) bool {
-	screenUpdated := false
-	firstHexit := (instruction >> 12) & 0xF
-	secondHexit := (instruction >> 8) & 0xF
-	thirdHexit := (instruction >> 4) & 0xF
-	fourthHexit := instruction & 0xF
-	secondByteOfInstruction := byte(instruction)
-	last12BitsOfInstruction := instruction & 0x0FFF
-
-	switch firstHexit {
-	case 0x0:
-		switch instruction {
-		case 0x00E0:
-			for i, v := range chip.Pixels {
-				for j := range v {
-					chip.Pixels[i][j] = false
-				}
-			}
-			screenUpdated = true
-		case 0x00EE:
-			chip.stackPointer--
-			if chip.stackPointer < 0 {
-				panic("Returning from main routine")
-			}
-			chip.programCounter = chip.stack[chip.stackPointer]
-		}
-	case 0x1:
-		chip.programCounter = last12BitsOfInstruction
-	case 0x2:
-		if chip.stackPointer > 15 {
-			panic("Stack limit of 16 reached")
-		} else {
-			chip.stack[chip.stackPointer] = chip.programCounter
-			chip.programCounter = last12BitsOfInstruction
-			chip.stackPointer++
-		}
-	case 0x3:
-		registerValue := chip.generalRegisters[secondHexit]
-		if registerValue == byte(secondByteOfInstruction) {
-			chip.programCounter += 2
-		}
-	case 0x4:
-		registerValue := chip.generalRegisters[secondHexit]
-		if registerValue != byte(secondByteOfInstruction) {
-			chip.programCounter += 2
-		}
-	case 0x5:
-		registerValueOne := chip.generalRegisters[secondHexit]
-		registerValueTwo := chip.generalRegisters[thirdHexit]
-		if registerValueOne == registerValueTwo {
-			chip.programCounter += 2
-		}
-	case 0x6:
-		chip.generalRegisters[secondHexit] = byte(secondByteOfInstruction)
-	case 0x7:
-		chip.generalRegisters[secondHexit] += byte(secondByteOfInstruction)
-	case 0x8:
-		switch fourthHexit {
-		case 0x0:
-			chip.generalRegisters[secondHexit] = chip.generalRegisters[thirdHexit]
-		case 0x1:
-			chip.generalRegisters[secondHexit] |= chip.generalRegisters[thirdHexit]
-			chip.generalRegisters[flagRegisterIndex] = 0
-		case 0x2:
-			chip.generalRegisters[secondHexit] &= chip.generalRegisters[thirdHexit]
-			chip.generalRegisters[flagRegisterIndex] = 0
-		case 0x3:
-			chip.generalRegisters[secondHexit] ^= chip.generalRegisters[thirdHexit]
-			chip.generalRegisters[flagRegisterIndex] = 0
-		case 0x4:
-			registerValueOne := chip.generalRegisters[secondHexit]
-			registerValueTwo := chip.generalRegisters[thirdHexit]
-			chip.generalRegisters[secondHexit] += registerValueTwo
-			if registerValueOne > math.MaxUint8-registerValueTwo {
-				chip.generalRegisters[flagRegisterIndex] = 1
-			} else {
-				chip.generalRegisters[flagRegisterIndex] = 0
-			}
-		case 0x5:
-			registerValueOne := chip.generalRegisters[secondHexit]
-			registerValueTwo := chip.generalRegisters[thirdHexit]
-			chip.generalRegisters[secondHexit] -= registerValueTwo
-			if registerValueOne >= registerValueTwo {
-				chip.generalRegisters[flagRegisterIndex] = 1
-			} else {
-				chip.generalRegisters[flagRegisterIndex] = 0
-			}
-		case 0x6:
-			registerValue := chip.generalRegisters[thirdHexit]
-			chip.generalRegisters[secondHexit] = registerValue >> 1
-			chip.generalRegisters[flagRegisterIndex] = registerValue & 0x1
-		case 0x7:
-			registerValueOne := chip.generalRegisters[secondHexit]
-			registerValueTwo := chip.generalRegisters[thirdHexit]
-			chip.generalRegisters[secondHexit] = registerValueTwo - registerValueOne
-			if registerValueTwo >= registerValueOne {
-				chip.generalRegisters[flagRegisterIndex] = 1
-			} else {
-				chip.generalRegisters[flagRegisterIndex] = 0
-			}
-		case 0xE:
-			registerValue := chip.generalRegisters[thirdHexit]
-			chip.generalRegisters[secondHexit] = registerValue << 1
-			chip.generalRegisters[flagRegisterIndex] = registerValue >> 7
-		}
-	case 0x9:
-		registerValueOne := chip.generalRegisters[secondHexit]
-		registerValueTwo := chip.generalRegisters[thirdHexit]
-		if registerValueOne != registerValueTwo {
-			chip.programCounter += 2
+// Seeds the chip's random number generator, which is fast enough to be used on every CXNN instruction
+func randomSeed() int64 {
+	var seed [8]byte
+	if _, err := cryptorand.Read(seed[:]); err != nil {
+		panic("Random number generation failed")
+	}
+	return int64(binary.LittleEndian.Uint64(seed[:]))
+}
+
+/*
+ExecuteCycle executes a single instruction, first decrementing the timers
+if a 60Hz tick has passed on the chip's clock since the last one. Like a
+ticker, it drops ticks rather than catching up if it isn't called often
+enough.
+*/
+func (chip *Chip) ExecuteCycle() bool {
+	if now := chip.clock.Now(); now.Sub(chip.lastTimerTick) >= time.Second/timerRateHz {
+		chip.lastTimerTick = chip.lastTimerTick.Add(time.Second / timerRateHz)
+		if now.Sub(chip.lastTimerTick) >= time.Second/timerRateHz {
+			chip.lastTimerTick = now
 		}
-	case 0xA:
-		chip.indexRegister = last12BitsOfInstruction
-	case 0xB:
-		chip.programCounter = uint16(chip.generalRegisters[0]) + last12BitsOfInstruction
-	case 0xC:
-		randomByteSlice := make([]byte, 1)
-		_, err := rand.Read(randomByteSlice)
-		if err != nil {
-			panic("Random number generation failed")
+		chip.DecrementTimers()
+	}
+	return chip.Step()
+}
+
+/*
+RunFrame executes one 60Hz frame's worth of instructions at the chip's
+execution rate and then decrements the timers once, independently of the
+clock. This is the simplest way to drive the chip from a game loop.
+*/
+func (chip *Chip) RunFrame() FrameResult {
+	chip.cycleBudget += float64(chip.executionRateHz) / timerRateHz
+	cycles := int(chip.cycleBudget)
+	if cycles < 1 {
+		cycles = 1
+	}
+	chip.cycleBudget -= float64(cycles)
+	if chip.cycleBudget < 0 {
+		chip.cycleBudget = 0
+	}
+
+	var result FrameResult
+	for result.Instructions < cycles && chip.fault == nil {
+		executed, screenUpdated, frameOver := chip.frameStep(cycles - result.Instructions)
+		result.Instructions += executed
+		if screenUpdated {
+			result.ScreenUpdated = true
 		}
-		chip.generalRegisters[secondHexit] = secondByteOfInstruction & randomByteSlice[0]
-	case 0xD:
-		height := fourthHexit
-		startingX := chip.generalRegisters[secondHexit] % pixelsWidth
-		startingY := chip.generalRegisters[thirdHexit] % pixelsHeight
-		chip.generalRegisters[flagRegisterIndex] = 0
-		for j := byte(0); j < byte(height); j++ {
-			currByte := chip.memory[chip.indexRegister+uint16(j)]
-			currentY := startingY + j
-			if currentY >= pixelsHeight {
-				break
-			}
-			for i := byte(0); i < 8; i++ {
-				currX := startingX + i
-				if currX >= pixelsWidth {
-					break
-				}
-				currPixel := chip.Pixels[currX][currentY]
-				var newPixel bool
-				if ((currByte >> (8 - i - 1)) & 1) == 1 {
-					newPixel = true
-				} else {
-					newPixel = false
-				}
-				if currPixel && newPixel {
-					chip.generalRegisters[flagRegisterIndex] = 1
-				}
-				chip.Pixels[currX][currentY] = currPixel != newPixel
-			}
+		if frameOver {
+			break
 		}
-		screenUpdated = true
-	case 0xE:
-		key := chip.generalRegisters[secondHexit]
-		switch secondByteOfInstruction {
-		case 0x9E:
-			if chip.keys[key] {
-				chip.programCounter += 2
-			}
-		case 0xA1:
-			if !chip.keys[key] {
-				chip.programCounter += 2
-			}
+	}
+	chip.DecrementTimers()
+	result.SoundOn = chip.soundOn
+	result.WaitingForKey = chip.waitingOnKeyRelease
+	result.Fault = chip.fault
+	return result
+}
+
+/*
+Executes up to cycles instructions as part of a 60Hz frame, which is more
+than one at a time only when the chip runs recompiled code. Returns how
+many were executed, counting an instruction that couldn't be fetched,
+whether the screen was updated, and whether the frame has to end there
+because the DisplayWait quirk makes drawing wait for the next frame.
+*/
+func (chip *Chip) frameStep(cycles int) (executed int, screenUpdated bool, frameOver bool) {
+	executed, screenUpdated = chip.execute(cycles)
+	if executed == 0 {
+		executed = 1
+	}
+	return executed, screenUpdated, screenUpdated && chip.quirks.DisplayWait
+}
+
+/*
+Step executes a single instruction without checking the clock for timer
+ticks, which lets headless callers run the chip as fast as possible and
+drive the timers themselves with DecrementTimers. Returns whether the
+screen was updated.
+*/
+func (chip *Chip) Step() bool {
+	_, screenUpdated := chip.execute(1)
+	return screenUpdated
+}
+
+// Executes up to cycles instructions, stopping after any that updates the screen, and returns how many were executed
+func (chip *Chip) execute(cycles int) (int, bool) {
+	if chip.fault != nil {
+		return 0, false
+	}
+	executed, screenUpdated := 0, false
+	if chip.runtime != nil {
+		executed, screenUpdated = chip.runtime.run(cycles)
+	}
+	if executed == 0 {
+		if !chip.canFetch() {
+			return 0, false
 		}
-	case 0xF:
-		switch secondByteOfInstruction {
-		case 0x07:
-			chip.generalRegisters[secondHexit] = chip.delayTimerValue
-		case 0x0A:
-			chip.programCounter -= 2
-			if !chip.waitingOnKeyRelease {
-				chip.waitingOnKeyRelease = true
-				chip.previousKeys = chip.keys
-			} else {
-				for i, v := range chip.keys {
-					if !v && chip.previousKeys[i] {
-						chip.generalRegisters[secondHexit] = byte(i)
-						chip.programCounter += 2
-						chip.waitingOnKeyRelease = false
-						break
-					}
-				}
-				chip.previousKeys = chip.keys
-			}
-		case 0x15:
-			chip.delayTimerValue = chip.generalRegisters[secondHexit]
-		case 0x18:
-			chip.SoundTimerValue = chip.generalRegisters[secondHexit]
-		case 0x1E:
-			chip.indexRegister += uint16(chip.generalRegisters[secondHexit])
-		case 0x29:
-			registerValue := chip.generalRegisters[secondHexit]
-			registerValue &= 0xF
-			chip.indexRegister = memoryStartIndexForFont + uint16(registerValue*5)
-		case 0x33:
-			registerValue := chip.generalRegisters[secondHexit]
-
-			hundredsDigit := (registerValue / 100) % 10
-			tensDigit := (registerValue / 10) % 10
-			onesDigit := registerValue % 10
-
-			chip.memory[chip.indexRegister] = hundredsDigit
-			chip.memory[chip.indexRegister+1] = tensDigit
-			chip.memory[chip.indexRegister+2] = onesDigit
-		case 0x55:
-			chip.dumpRegisters(secondHexit)
-		case 0x65:
-			chip.loadRegisters(secondHexit)
+		executed, screenUpdated = 1, chip.executeInstruction(chip.fetchInstruction())
+	}
+	if screenUpdated && chip.callbacks.Draw != nil {
+		chip.callbacks.Draw()
+	}
+	return executed, screenUpdated
+}
+
+// Decrements both timers as if one 60Hz tick had passed
+func (chip *Chip) DecrementTimers() {
+	chip.decrementDelayTimer()
+	chip.decrementSoundTimer()
+	chip.updateSound()
+}
+
+// Calls the Sound callback when the buzzer starts or stops
+func (chip *Chip) updateSound() {
+	if soundOn := chip.SoundTimerValue > 0; soundOn != chip.soundOn {
+		chip.soundOn = soundOn
+		if chip.callbacks.Sound != nil {
+			chip.callbacks.Sound(soundOn)
 		}
 	}
-	return screenUpdated
+}
+
+// Must only be called once canFetch has checked the program counter
+func (chip *Chip) fetchInstruction() uint16 {
+	pc := int(chip.programCounter)
+	currInstruction := binary.BigEndian.Uint16(chip.memory[pc : pc+2])
+	chip.programCounter += 2
+	return currInstruction
+}
+
+func (chip *Chip) executeInstruction(instruction uint16) bool {
+	return chip.instructions[instruction](chip, instruction)
 }
 
 func (chip *Chip) dumpRegisters(finalRegisterIndex uint16) {
+	chip.indexedMemoryWritten(int(finalRegisterIndex) + 1)
 	for i := 0; i <= int(finalRegisterIndex); i++ {
-		chip.memory[chip.indexRegister] = chip.generalRegisters[i]
-		chip.indexRegister++
+		chip.memory[chip.indexedAddress(i)] = chip.generalRegisters[i]
+	}
+	if chip.quirks.MemoryIncrementsIndex {
+		chip.indexRegister += finalRegisterIndex + 1
+	}
+}
+
+// Must be called whenever an instruction or the host writes to memory, so
+// that anything caching the contents of memory can be invalidated
+func (chip *Chip) memoryWritten(address uint16, length int) {
+	for _, hook := range chip.memoryWriteHooks {
+		hook(address, length)
 	}
 }
 
+// The big font is loaded straight after the small one
+func (chip *Chip) bigFontAddress() uint16 {
+	return chip.platform.FontAddress + uint16(len(chip.font.Small))
+}
+
 func (chip *Chip) loadRegisters(finalRegisterIndex uint16) {
 	for i := 0; i <= int(finalRegisterIndex); i++ {
-		chip.generalRegisters[i] = chip.memory[chip.indexRegister]
-		chip.indexRegister++
+		chip.generalRegisters[i] = chip.memory[chip.indexedAddress(i)]
+	}
+	if chip.quirks.MemoryIncrementsIndex {
+		chip.indexRegister += finalRegisterIndex + 1
 	}
 }
 
@@ -342,10 +355,92 @@ func (chip *Chip) SetKeys(keyState [16]bool) {
 	chip.keys = keyState
 }
 
-func (chip *Chip) loadGameIntoMemory(fileBytes []byte) {
-	copy(chip.memory[memoryStartIndexForGame:memoryStartIndexForGame+uint16(len(fileBytes))], fileBytes)
+func (chip *Chip) Keys() [16]bool {
+	return chip.keys
+}
+
+func (chip *Chip) Registers() [16]byte {
+	return chip.generalRegisters
+}
+
+func (chip *Chip) IndexRegister() uint16 {
+	return chip.indexRegister
+}
+
+func (chip *Chip) ProgramCounter() uint16 {
+	return chip.programCounter
+}
+
+func (chip *Chip) StackPointer() int {
+	return chip.stackPointer
+}
+
+func (chip *Chip) DelayTimerValue() uint8 {
+	return chip.delayTimerValue
+}
+
+func (chip *Chip) SoundTimer() uint8 {
+	return chip.SoundTimerValue
+}
+
+func (chip *Chip) Quirks() Quirks {
+	return chip.quirks
+}
+
+func (chip *Chip) Platform() Platform {
+	return chip.platform
+}
+
+func (chip *Chip) Font() Font {
+	return chip.font
+}
+
+// Returns what validation found out about the current ROM, including any warnings
+func (chip *Chip) ROMInfo() ROMInfo {
+	return chip.romInfo
+}
+
+func (chip *Chip) SetRegister(index int, value byte) {
+	chip.generalRegisters[index] = value
 }
 
-func (chip *Chip) loadFontIntoMemory() {
-	copy(chip.memory[memoryStartIndexForFont:memoryStartIndexForFont+uint16(len(font))], font[:])
+func (chip *Chip) SetIndexRegister(value uint16) {
+	chip.indexRegister = value
+}
+
+func (chip *Chip) SetProgramCounter(value uint16) {
+	chip.programCounter = value
+}
+
+func (chip *Chip) SetDelayTimerValue(value uint8) {
+	chip.delayTimerValue = value
+}
+
+// Returns the return addresses of all active subroutine calls, innermost last
+func (chip *Chip) Stack() []uint16 {
+	stack := make([]uint16, chip.stackPointer)
+	copy(stack, chip.stack[:chip.stackPointer])
+	return stack
+}
+
+// Returns a copy of the whole of memory
+func (chip *Chip) Memory() []byte {
+	memory := make([]byte, len(chip.memory))
+	copy(memory, chip.memory)
+	return memory
+}
+
+func (chip *Chip) WriteMemory(address uint16, value byte) {
+	address = uint16(int(address) % len(chip.memory))
+	chip.memoryWritten(address, 1)
+	chip.memory[address] = value
+}
+
+// Returns the instruction that the next cycle will execute, without advancing the program counter
+func (chip *Chip) CurrentInstruction() uint16 {
+	pc := int(chip.programCounter)
+	if pc+2 > len(chip.memory) {
+		return 0
+	}
+	return binary.BigEndian.Uint16(chip.memory[pc : pc+2])
 }
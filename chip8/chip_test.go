@@ -6,18 +6,26 @@ const (
 	testingCycleSleepTime = 0
 )
 
-func TestFetchOutOfBoundsInstruction(t *testing.T) {
-	defer func() { _ = recover() }()
+func newTestChip(rom []byte, options ...Option) *Chip {
+	chip, err := NewChip(rom, options...)
+	if err != nil {
+		panic(err)
+	}
+	return chip
+}
 
-	chip := NewChip([]byte{0x00E0})
+func TestFetchOutOfBoundsInstruction(t *testing.T) {
+	chip := newTestChip([]byte{0x00E0})
 	chip.programCounter = 4095
 	chip.ExecuteCycle()
 
-	t.Errorf("did not panic")
+	if fault := chip.Fault(); fault == nil || fault.Address != 4095 || chip.programCounter != 4095 {
+		t.Errorf("Fetching past the end of memory faulted with %v", fault)
+	}
 }
 
 func Test00E0(t *testing.T) {
-	chip := NewChip([]byte{0x00, 0xE0})
+	chip := newTestChip([]byte{0x00, 0xE0})
 	var expectedDisplay [64][32]bool
 	for i, v := range chip.Pixels {
 		for j := range v {
@@ -37,7 +45,7 @@ func Test00E0(t *testing.T) {
 }
 
 func Test00EE(t *testing.T) {
-	chip := NewChip([]byte{0x22, 0x02, 0x00, 0xEE})
+	chip := newTestChip([]byte{0x22, 0x02, 0x00, 0xEE})
 	chip.ExecuteCycle()
 	chip.ExecuteCycle()
 
@@ -47,7 +55,7 @@ func Test00EE(t *testing.T) {
 }
 
 func Test1NNN(t *testing.T) {
-	chip := NewChip([]byte{0x1E, 0xEE})
+	chip := newTestChip([]byte{0x1E, 0xEE})
 	chip.ExecuteCycle()
 
 	if chip.programCounter != 0xEEE {
@@ -56,7 +64,7 @@ func Test1NNN(t *testing.T) {
 }
 
 func Test2NNN(t *testing.T) {
-	chip := NewChip([]byte{0x2E, 0xEE})
+	chip := newTestChip([]byte{0x2E, 0xEE})
 	originalProgramCounter := chip.programCounter
 	chip.ExecuteCycle()
 
@@ -66,7 +74,7 @@ func Test2NNN(t *testing.T) {
 }
 
 func Test3XNN(t *testing.T) {
-	chip := NewChip([]byte{0x31, 0x45, 0x00, 0x00, 0x31, 0x46})
+	chip := newTestChip([]byte{0x31, 0x45, 0x00, 0x00, 0x31, 0x46})
 	chip.generalRegisters[1] = 0x45
 	chip.ExecuteCycle()
 
@@ -82,7 +90,7 @@ func Test3XNN(t *testing.T) {
 }
 
 func Test4XNN(t *testing.T) {
-	chip := NewChip([]byte{0x41, 0x46, 0x00, 0x00, 0x41, 0x45})
+	chip := newTestChip([]byte{0x41, 0x46, 0x00, 0x00, 0x41, 0x45})
 	chip.generalRegisters[1] = 0x45
 	chip.ExecuteCycle()
 
@@ -98,7 +106,7 @@ func Test4XNN(t *testing.T) {
 }
 
 func Test5XY0(t *testing.T) {
-	chip := NewChip([]byte{0x50, 0x10, 0x00, 0x00, 0x50, 0x20})
+	chip := newTestChip([]byte{0x50, 0x10, 0x00, 0x00, 0x50, 0x20})
 	chip.generalRegisters[0] = 0x45
 	chip.generalRegisters[1] = 0x45
 	chip.ExecuteCycle()
@@ -115,7 +123,7 @@ func Test5XY0(t *testing.T) {
 }
 
 func Test6XNN(t *testing.T) {
-	chip := NewChip([]byte{0x60, 0x11})
+	chip := newTestChip([]byte{0x60, 0x11})
 	chip.ExecuteCycle()
 
 	if chip.generalRegisters[0] != 0x11 {
@@ -124,7 +132,7 @@ func Test6XNN(t *testing.T) {
 }
 
 func Test7XNN(t *testing.T) {
-	chip := NewChip([]byte{0x70, 0xEE, 0x70, 0xEE})
+	chip := newTestChip([]byte{0x70, 0xEE, 0x70, 0xEE})
 	chip.ExecuteCycle()
 
 	if chip.generalRegisters[0] != 0xEE || chip.generalRegisters[flagRegisterIndex] == 1 {
@@ -139,7 +147,7 @@ func Test7XNN(t *testing.T) {
 }
 
 func Test8XY0(t *testing.T) {
-	chip := NewChip([]byte{0x80, 0x10})
+	chip := newTestChip([]byte{0x80, 0x10})
 	chip.generalRegisters[1] = 0xEE
 	chip.ExecuteCycle()
 
@@ -149,7 +157,7 @@ func Test8XY0(t *testing.T) {
 }
 
 func Test8XY1(t *testing.T) {
-	chip := NewChip([]byte{0x80, 0x11})
+	chip := newTestChip([]byte{0x80, 0x11})
 	chip.generalRegisters[1] = 0xEE
 	chip.generalRegisters[flagRegisterIndex] = 1
 	chip.ExecuteCycle()
@@ -160,7 +168,7 @@ func Test8XY1(t *testing.T) {
 }
 
 func Test8XY2(t *testing.T) {
-	chip := NewChip([]byte{0x80, 0x12})
+	chip := newTestChip([]byte{0x80, 0x12})
 	chip.generalRegisters[1] = 0xEE
 	chip.generalRegisters[flagRegisterIndex] = 1
 	chip.ExecuteCycle()
@@ -171,7 +179,7 @@ func Test8XY2(t *testing.T) {
 }
 
 func Test8XY3(t *testing.T) {
-	chip := NewChip([]byte{0x80, 0x13})
+	chip := newTestChip([]byte{0x80, 0x13})
 	chip.generalRegisters[1] = 0xEE
 	chip.generalRegisters[flagRegisterIndex] = 1
 	chip.ExecuteCycle()
@@ -182,7 +190,7 @@ func Test8XY3(t *testing.T) {
 }
 
 func Test8XY4(t *testing.T) {
-	chip := NewChip([]byte{0x80, 0x14, 0x80, 0x14})
+	chip := newTestChip([]byte{0x80, 0x14, 0x80, 0x14})
 	chip.generalRegisters[1] = 0xEE
 	chip.ExecuteCycle()
 
@@ -198,7 +206,7 @@ func Test8XY4(t *testing.T) {
 }
 
 func Test8XY5(t *testing.T) {
-	chip := NewChip([]byte{0x80, 0x15, 0x80, 0x25})
+	chip := newTestChip([]byte{0x80, 0x15, 0x80, 0x25})
 	chip.generalRegisters[1] = 0xEE
 	chip.ExecuteCycle()
 
@@ -214,7 +222,7 @@ func Test8XY5(t *testing.T) {
 }
 
 func Test8XY6(t *testing.T) {
-	chip := NewChip([]byte{0x80, 0x16})
+	chip := newTestChip([]byte{0x80, 0x16})
 	chip.generalRegisters[1] = 0x3
 	chip.ExecuteCycle()
 
@@ -224,7 +232,7 @@ func Test8XY6(t *testing.T) {
 }
 
 func Test8XY7(t *testing.T) {
-	chip := NewChip([]byte{0x80, 0x17, 0x82, 0x17})
+	chip := newTestChip([]byte{0x80, 0x17, 0x82, 0x17})
 	chip.generalRegisters[0] = 0xEE
 	chip.ExecuteCycle()
 
@@ -240,7 +248,7 @@ func Test8XY7(t *testing.T) {
 }
 
 func Test8XYE(t *testing.T) {
-	chip := NewChip([]byte{0x80, 0x1E})
+	chip := newTestChip([]byte{0x80, 0x1E})
 	chip.generalRegisters[1] = 0x81
 	chip.ExecuteCycle()
 
@@ -250,7 +258,7 @@ func Test8XYE(t *testing.T) {
 }
 
 func Test9XY0(t *testing.T) {
-	chip := NewChip([]byte{0x90, 0x20, 0x00, 0x00, 0x90, 0x10})
+	chip := newTestChip([]byte{0x90, 0x20, 0x00, 0x00, 0x90, 0x10})
 	chip.generalRegisters[0] = 0x45
 	chip.generalRegisters[1] = 0x45
 	chip.ExecuteCycle()
@@ -267,7 +275,7 @@ func Test9XY0(t *testing.T) {
 }
 
 func TestANNN(t *testing.T) {
-	chip := NewChip([]byte{0xAE, 0xEE})
+	chip := newTestChip([]byte{0xAE, 0xEE})
 	chip.ExecuteCycle()
 
 	if chip.indexRegister != 0xEEE {
@@ -276,7 +284,7 @@ func TestANNN(t *testing.T) {
 }
 
 func TestBNNN(t *testing.T) {
-	chip := NewChip([]byte{0xBE, 0xED})
+	chip := newTestChip([]byte{0xBE, 0xED})
 	chip.generalRegisters[0] = 0x1
 	chip.ExecuteCycle()
 
@@ -285,8 +293,40 @@ func TestBNNN(t *testing.T) {
 	}
 }
 
+func TestCXNN(t *testing.T) {
+	chip := newTestChip([]byte{0xC0, 0x0F})
+	for i := 0; i < 100; i++ {
+		chip.programCounter = 0x200
+		chip.ExecuteCycle()
+
+		if chip.generalRegisters[0]&0xF0 != 0 {
+			t.Error("Random number was not masked")
+			break
+		}
+	}
+}
+
+func TestStepWithDecrementTimers(t *testing.T) {
+	chip := newTestChip([]byte{0xF0, 0x15, 0xF1, 0x18})
+	chip.generalRegisters[0] = 2
+	chip.generalRegisters[1] = 1
+	chip.Step()
+	chip.Step()
+	chip.DecrementTimers()
+
+	if chip.delayTimerValue != 1 || chip.SoundTimerValue != 0 {
+		t.Error("Timers were not decremented")
+	}
+
+	chip.DecrementTimers()
+
+	if chip.delayTimerValue != 0 || chip.SoundTimerValue != 0 {
+		t.Error("Timers were decremented past 0")
+	}
+}
+
 func TestDXYN(t *testing.T) {
-	chip := NewChip([]byte{0xD0, 0x15})
+	chip := newTestChip([]byte{0xD0, 0x15})
 	xCord := byte(3)
 	yCord := byte(4)
 
@@ -319,7 +359,7 @@ func TestDXYN(t *testing.T) {
 }
 
 func TestDXYNWrap(t *testing.T) {
-	chip := NewChip([]byte{0xD0, 0x11})
+	chip := newTestChip([]byte{0xD0, 0x11})
 	xCord := byte(64)
 	yCord := byte(32)
 
@@ -337,7 +377,7 @@ func TestDXYNWrap(t *testing.T) {
 }
 
 func TestDXYNTruncate(t *testing.T) {
-	chip := NewChip([]byte{0xD0, 0x11})
+	chip := newTestChip([]byte{0xD0, 0x11})
 	xCord := byte(63)
 	yCord := byte(31)
 
@@ -363,7 +403,7 @@ func TestDXYNTruncate(t *testing.T) {
 }
 
 func TestEX9E(t *testing.T) {
-	chip := NewChip([]byte{0xE0, 0x9E, 0x00, 0x00, 0xE1, 0x9E})
+	chip := newTestChip([]byte{0xE0, 0x9E, 0x00, 0x00, 0xE1, 0x9E})
 	chip.keys[0] = true
 	chip.generalRegisters[1] = 1
 	chip.ExecuteCycle()
@@ -380,7 +420,7 @@ func TestEX9E(t *testing.T) {
 }
 
 func TestEXA1(t *testing.T) {
-	chip := NewChip([]byte{0xE0, 0xA1, 0x00, 0x00, 0xE1, 0xA1})
+	chip := newTestChip([]byte{0xE0, 0xA1, 0x00, 0x00, 0xE1, 0xA1})
 	chip.keys[1] = true
 	chip.generalRegisters[1] = 1
 	chip.ExecuteCycle()
@@ -397,7 +437,7 @@ func TestEXA1(t *testing.T) {
 }
 
 func TestFX07(t *testing.T) {
-	chip := NewChip([]byte{0xF0, 0x07})
+	chip := newTestChip([]byte{0xF0, 0x07})
 	chip.delayTimerValue = 5
 	chip.ExecuteCycle()
 
@@ -407,7 +447,7 @@ func TestFX07(t *testing.T) {
 }
 
 func TestFX0A(t *testing.T) {
-	chip := NewChip([]byte{0xF0, 0x0A})
+	chip := newTestChip([]byte{0xF0, 0x0A})
 	chip.ExecuteCycle()
 
 	if chip.programCounter != 0x200 {
@@ -430,7 +470,7 @@ func TestFX0A(t *testing.T) {
 }
 
 func TestFX15(t *testing.T) {
-	chip := NewChip([]byte{0xF0, 0x15})
+	chip := newTestChip([]byte{0xF0, 0x15})
 	chip.generalRegisters[0] = 5
 	chip.ExecuteCycle()
 
@@ -440,7 +480,7 @@ func TestFX15(t *testing.T) {
 }
 
 func TestFX18(t *testing.T) {
-	chip := NewChip([]byte{0xF0, 0x18})
+	chip := newTestChip([]byte{0xF0, 0x18})
 	chip.generalRegisters[0] = 5
 	chip.ExecuteCycle()
 
@@ -450,7 +490,7 @@ func TestFX18(t *testing.T) {
 }
 
 func TestFX1E(t *testing.T) {
-	chip := NewChip([]byte{0xF0, 0x1E})
+	chip := newTestChip([]byte{0xF0, 0x1E})
 	chip.indexRegister = 0xFF
 	chip.generalRegisters[0] = 0xFF
 	chip.ExecuteCycle()
@@ -461,7 +501,7 @@ func TestFX1E(t *testing.T) {
 }
 
 func TestFX29(t *testing.T) {
-	chip := NewChip([]byte{0xF0, 0x29})
+	chip := newTestChip([]byte{0xF0, 0x29})
 	chip.generalRegisters[0] = 0x2
 	chip.ExecuteCycle()
 
@@ -471,7 +511,7 @@ func TestFX29(t *testing.T) {
 }
 
 func TestFX33(t *testing.T) {
-	chip := NewChip([]byte{0xF0, 0x33})
+	chip := newTestChip([]byte{0xF0, 0x33})
 	chip.generalRegisters[0] = 173
 	chip.indexRegister = 0x202
 	chip.ExecuteCycle()
@@ -482,7 +522,7 @@ func TestFX33(t *testing.T) {
 }
 
 func TestFX55(t *testing.T) {
-	chip := NewChip([]byte{0xF5, 0x55})
+	chip := newTestChip([]byte{0xF5, 0x55})
 	for i := range chip.generalRegisters {
 		chip.generalRegisters[i] = 0xDE
 	}
@@ -506,7 +546,7 @@ func TestFX55(t *testing.T) {
 }
 
 func TestFX65(t *testing.T) {
-	chip := NewChip([]byte{0xF5, 0x65})
+	chip := newTestChip([]byte{0xF5, 0x65})
 	chip.indexRegister = 0x202
 	for i := 0; i < 6; i++ {
 		chip.memory[chip.indexRegister+uint16(i)] = 0xDE
@@ -524,3 +564,75 @@ func TestFX65(t *testing.T) {
 		}
 	}
 }
+
+func TestStackAccessor(t *testing.T) {
+	chip := newTestChip([]byte{0x22, 0x02, 0x22, 0x04})
+	chip.ExecuteCycle()
+	chip.ExecuteCycle()
+
+	stack := chip.Stack()
+	if len(stack) != 2 || stack[0] != 0x202 || stack[1] != 0x204 {
+		t.Errorf("Incorrect stack %v", stack)
+	}
+}
+
+func TestMemoryAccessors(t *testing.T) {
+	chip := newTestChip([]byte{0x00, 0xE0})
+	chip.WriteMemory(0x300, 0xAB)
+	memory := chip.Memory()
+
+	if memory[0x300] != 0xAB || memory[0x201] != 0xE0 {
+		t.Error("Memory was not read back correctly")
+	}
+
+	memory[0x300] = 0
+	if chip.memory[0x300] != 0xAB {
+		t.Error("Memory copy aliased the chip's memory")
+	}
+}
+
+func TestReset(t *testing.T) {
+	chip := newTestChip([]byte{0x60, 0x01, 0xA3, 0x00, 0xF0, 0x55, 0xD0, 0x11})
+	for i := 0; i < 4; i++ {
+		chip.Step()
+	}
+	chip.Reset()
+
+	if chip.programCounter != 0x200 || chip.indexRegister != 0 || chip.generalRegisters[0] != 0 {
+		t.Error("Registers were not reset")
+	}
+	if chip.memory[0x300] != 0 || chip.memory[0x201] != 0x01 || chip.memory[DefaultPlatform.FontAddress] != DefaultFont.Small[0] {
+		t.Error("Memory was not reset")
+	}
+	if chip.Pixels[1][0] {
+		t.Error("Display was not cleared")
+	}
+}
+
+func TestLoadROM(t *testing.T) {
+	chip := newTestChip([]byte{0x60, 0x01})
+	if err := chip.LoadROM([]byte{0x61, 0x02}); err != nil {
+		t.Fatal(err)
+	}
+	chip.Step()
+	if chip.generalRegisters[0] != 0 || chip.generalRegisters[1] != 2 {
+		t.Error("New ROM was not loaded")
+	}
+
+	if err := chip.LoadROM(make([]byte, 4096)); err == nil {
+		t.Error("Oversized ROM was loaded")
+	}
+}
+
+func TestRunFrame(t *testing.T) {
+	chip := newTestChip([]byte{0x70, 0x01, 0x12, 0x00}, WithExecutionRate(600))
+	chip.delayTimerValue = 5
+	result := chip.RunFrame()
+
+	if result.Instructions != 10 || result.ScreenUpdated {
+		t.Errorf("Frame ran %d instructions", result.Instructions)
+	}
+	if chip.delayTimerValue != 4 {
+		t.Error("Timers were not decremented once")
+	}
+}
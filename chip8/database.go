@@ -0,0 +1,214 @@
+package chip8
+
+import (
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// ROMMetadata is what the ROM database knows about a ROM, and the settings it runs best with
+type ROMMetadata struct {
+	SHA1     string
+	Title    string
+	Author   string
+	Platform Platform
+	// The platform's quirks, with any the database overrides
+	Quirks Quirks
+	// Instructions per 60Hz frame the ROM was meant to run at, or 0 if it isn't known
+	CyclesPerFrame int
+	// The CHIP-8 key for each of the KeyActions that the ROM uses
+	Keys map[string]byte
+	// Colours the ROM was designed for, or zero if it has none
+	Foreground color.RGBA
+	Background color.RGBA
+}
+
+// Options for NewChip that set up the ROM's platform and quirks
+func (m ROMMetadata) Options() []Option {
+	options := []Option{WithPlatform(m.Platform), WithQuirks(m.Quirks)}
+	if m.CyclesPerFrame > 0 {
+		options = append(options, WithExecutionRate(m.CyclesPerFrame*60))
+	}
+	return options
+}
+
+// Actions that the key hints in the ROM database can give a CHIP-8 key for
+var KeyActions = []string{"up", "down", "left", "right", "fire"}
+
+// ROMDatabase looks up ROM metadata by the SHA-1 hash of the ROM
+type ROMDatabase struct {
+	roms map[string]ROMMetadata
+}
+
+/*
+The embedded database, in the same format ParseROMDatabase reads. Entries
+are keyed by the SHA-1 of the exact ROM file, so every known revision of a
+ROM needs its own entry.
+*/
+//go:embed roms.json
+var embeddedROMDatabase []byte
+
+var defaultROMDatabase = mustParseROMDatabase(embeddedROMDatabase)
+
+func mustParseROMDatabase(data []byte) *ROMDatabase {
+	db, err := ParseROMDatabase(data)
+	if err != nil {
+		panic(fmt.Sprintf("Embedded ROM database is invalid: %v", err))
+	}
+	return db
+}
+
+// Returns the ROM database built into the emulator
+func DefaultROMDatabase() *ROMDatabase {
+	return defaultROMDatabase
+}
+
+// Returns the hex encoded SHA-1 hash of a ROM, which the ROM database is keyed by
+func ROMHash(rom []byte) string {
+	hash := sha1.Sum(rom)
+	return hex.EncodeToString(hash[:])
+}
+
+func (db *ROMDatabase) Lookup(rom []byte) (ROMMetadata, bool) {
+	metadata, ok := db.roms[ROMHash(rom)]
+	return metadata, ok
+}
+
+// An entry in the JSON form of the database
+type romEntry struct {
+	SHA1           string          `json:"sha1"`
+	Title          string          `json:"title"`
+	Author         string          `json:"author"`
+	Platform       string          `json:"platform"`
+	Quirks         json.RawMessage `json:"quirks"`
+	CyclesPerFrame int             `json:"cyclesPerFrame"`
+	Keys           map[string]int  `json:"keys"`
+	Colors         struct {
+		Foreground string `json:"foreground"`
+		Background string `json:"background"`
+	} `json:"colors"`
+}
+
+/*
+ParseROMDatabase reads a JSON array of ROMs, such as:
+
+	[{
+		"sha1": "0123456789abcdef0123456789abcdef01234567",
+		"title": "Example",
+		"author": "Someone",
+		"platform": "hp48",
+		"quirks": {"shiftUsesVY": false},
+		"cyclesPerFrame": 30,
+		"keys": {"up": 5, "down": 8, "fire": 6},
+		"colors": {"foreground": "#ffcc00", "background": "#996600"}
+	}]
+
+Everything but the hash and title is optional. The platform defaults to
+chip8, and the quirks are the platform's with any that are listed
+overridden.
+*/
+func ParseROMDatabase(data []byte) (*ROMDatabase, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var entries []romEntry
+	if err := decoder.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("invalid ROM database: %w", err)
+	}
+
+	db := &ROMDatabase{roms: make(map[string]ROMMetadata, len(entries))}
+	for i, entry := range entries {
+		metadata, err := entry.metadata()
+		if err != nil {
+			return nil, fmt.Errorf("ROM database entry %d (%s): %w", i, entry.Title, err)
+		}
+		if _, ok := db.roms[metadata.SHA1]; ok {
+			return nil, fmt.Errorf("ROM database entry %d (%s): duplicate hash %s", i, entry.Title, metadata.SHA1)
+		}
+		db.roms[metadata.SHA1] = metadata
+	}
+	return db, nil
+}
+
+func (entry romEntry) metadata() (ROMMetadata, error) {
+	metadata := ROMMetadata{
+		SHA1:           strings.ToLower(entry.SHA1),
+		Title:          entry.Title,
+		Author:         entry.Author,
+		CyclesPerFrame: entry.CyclesPerFrame,
+	}
+	if hash, err := hex.DecodeString(metadata.SHA1); err != nil || len(hash) != sha1.Size {
+		return metadata, fmt.Errorf("invalid SHA-1 hash %q", entry.SHA1)
+	}
+	if entry.Title == "" {
+		return metadata, fmt.Errorf("missing title")
+	}
+	if entry.CyclesPerFrame < 0 {
+		return metadata, fmt.Errorf("negative cycles per frame")
+	}
+
+	metadata.Platform = DefaultPlatform
+	if entry.Platform != "" {
+		platform, err := ParsePlatform(entry.Platform)
+		if err != nil {
+			return metadata, err
+		}
+		metadata.Platform = platform
+	}
+	// Only the quirks that are listed replace the platform's
+	metadata.Quirks = metadata.Platform.Quirks
+	if len(entry.Quirks) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(entry.Quirks))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&metadata.Quirks); err != nil {
+			return metadata, fmt.Errorf("invalid quirks: %w", err)
+		}
+	}
+
+	if len(entry.Keys) > 0 {
+		metadata.Keys = make(map[string]byte, len(entry.Keys))
+		for action, key := range entry.Keys {
+			if !isKeyAction(action) {
+				return metadata, fmt.Errorf("unknown action %q (expected up, down, left, right or fire)", action)
+			}
+			if key < 0 || key > 0xF {
+				return metadata, fmt.Errorf("key %d for %q isn't a CHIP-8 key", key, action)
+			}
+			metadata.Keys[action] = byte(key)
+		}
+	}
+
+	var err error
+	if metadata.Foreground, err = parseColor(entry.Colors.Foreground); err != nil {
+		return metadata, err
+	}
+	if metadata.Background, err = parseColor(entry.Colors.Background); err != nil {
+		return metadata, err
+	}
+	return metadata, nil
+}
+
+func isKeyAction(action string) bool {
+	for _, known := range KeyActions {
+		if action == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses a colour written as #rrggbb, or returns zero for an empty string
+func parseColor(s string) (color.RGBA, error) {
+	if s == "" {
+		return color.RGBA{}, nil
+	}
+	rgb, err := hex.DecodeString(strings.TrimPrefix(s, "#"))
+	if err != nil || len(rgb) != 3 || !strings.HasPrefix(s, "#") {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q (expected #rrggbb)", s)
+	}
+	return color.RGBA{rgb[0], rgb[1], rgb[2], 0xff}, nil
+}
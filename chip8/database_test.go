@@ -0,0 +1,112 @@
+package chip8
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestROMDatabaseLookup(t *testing.T) {
+	rom := []byte{0x12, 0x00}
+	db, err := ParseROMDatabase([]byte(`[{
+		"sha1": "` + strings.ToUpper(ROMHash(rom)) + `",
+		"title": "Loop",
+		"author": "Someone",
+		"platform": "hp48",
+		"quirks": {"clipSprites": false, "vfReset": true},
+		"cyclesPerFrame": 30,
+		"keys": {"up": 5, "fire": 15},
+		"colors": {"foreground": "#ffcc00", "background": "#996600"}
+	}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, ok := db.Lookup(rom)
+	if !ok {
+		t.Fatal("ROM was not found")
+	}
+	if metadata.Title != "Loop" || metadata.Author != "Someone" || metadata.Platform.Name != HP48.Name || metadata.CyclesPerFrame != 30 {
+		t.Errorf("Metadata is %+v", metadata)
+	}
+	expected := SCHIPQuirks
+	expected.ClipSprites = false
+	expected.VFReset = true
+	if metadata.Quirks != expected {
+		t.Errorf("Quirks are %+v", metadata.Quirks)
+	}
+	if metadata.Keys["up"] != 5 || metadata.Keys["fire"] != 0xF {
+		t.Errorf("Keys are %v", metadata.Keys)
+	}
+	if metadata.Foreground != (color.RGBA{0xff, 0xcc, 0x00, 0xff}) || metadata.Background != (color.RGBA{0x99, 0x66, 0x00, 0xff}) {
+		t.Errorf("Colours are %v and %v", metadata.Foreground, metadata.Background)
+	}
+	if _, ok := db.Lookup([]byte{0x12, 0x02}); ok {
+		t.Error("Found a ROM that isn't in the database")
+	}
+
+	chip := newTestChip(rom, metadata.Options()...)
+	if chip.Quirks() != expected || chip.Platform().Name != HP48.Name || chip.executionRateHz != 1800 {
+		t.Error("Options don't apply the metadata")
+	}
+}
+
+// The IBM Logo ROM that is often the first one run on a new emulator
+var ibmLogoROM = []byte{
+	0x00, 0xE0, 0xA2, 0x2A, 0x60, 0x0C, 0x61, 0x08, 0xD0, 0x1F, 0x70, 0x09, 0xA2, 0x39, 0xD0, 0x1F,
+	0xA2, 0x48, 0x70, 0x08, 0xD0, 0x1F, 0x70, 0x04, 0xA2, 0x57, 0xD0, 0x1F, 0x70, 0x08, 0xA2, 0x66,
+	0xD0, 0x1F, 0x70, 0x08, 0xA2, 0x75, 0xD0, 0x1F, 0x12, 0x28, 0xFF, 0x00, 0xFF, 0x00, 0x3C, 0x00,
+	0x3C, 0x00, 0x3C, 0x00, 0x3C, 0x00, 0xFF, 0x00, 0xFF, 0xFF, 0x00, 0xFF, 0x00, 0x38, 0x00, 0x3F,
+	0x00, 0x3F, 0x00, 0x38, 0x00, 0xFF, 0x00, 0xFF, 0x80, 0x00, 0xE0, 0x00, 0xE0, 0x00, 0x80, 0x00,
+	0x80, 0x00, 0xE0, 0x00, 0xE0, 0x00, 0x80, 0xF8, 0x00, 0xFC, 0x00, 0x3E, 0x00, 0x3F, 0x00, 0x3B,
+	0x00, 0x39, 0x00, 0xF8, 0x00, 0xF8, 0x03, 0x00, 0x07, 0x00, 0x0F, 0x00, 0xBF, 0x00, 0xFB, 0x00,
+	0xF3, 0x00, 0xE3, 0x00, 0x43, 0xE0, 0x00, 0xE0, 0x00, 0x80, 0x00, 0x80, 0x00, 0x80, 0x00, 0x80,
+	0x00, 0xE0, 0x00, 0xE0,
+}
+
+func TestROMDatabaseDefaults(t *testing.T) {
+	db, err := ParseROMDatabase([]byte(`[{"sha1": "0123456789abcdef0123456789abcdef01234567", "title": "Minimal"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := db.roms["0123456789abcdef0123456789abcdef01234567"]
+	if metadata.Platform.Name != DefaultPlatform.Name || metadata.Quirks != DefaultQuirks || metadata.Keys != nil || metadata.Foreground != (color.RGBA{}) {
+		t.Errorf("Metadata is %+v", metadata)
+	}
+}
+
+func TestROMDatabaseErrors(t *testing.T) {
+	hash := `"sha1": "0123456789abcdef0123456789abcdef01234567", "title": "Bad"`
+	for _, data := range []string{
+		`{}`,
+		`[{"sha1": "0123", "title": "Bad"}]`,
+		`[{"sha1": "0123456789abcdef0123456789abcdef01234567"}]`,
+		`[{` + hash + `, "platform": "nes"}]`,
+		`[{` + hash + `, "quirks": {"noSuchQuirk": true}}]`,
+		`[{` + hash + `, "keys": {"up": 16}}]`,
+		`[{` + hash + `, "keys": {"jump": 5}}]`,
+		`[{` + hash + `, "colors": {"foreground": "ffcc00"}}]`,
+		`[{` + hash + `, "cyclesPerFrame": -1}]`,
+		`[{` + hash + `, "year": 1977}]`,
+		`[{` + hash + `}, {` + hash + `}]`,
+	} {
+		if _, err := ParseROMDatabase([]byte(data)); err == nil {
+			t.Errorf("Parsed %s", data)
+		}
+	}
+}
+
+func TestDefaultROMDatabase(t *testing.T) {
+	// Parsing the embedded database panics if it's invalid, so this checks it's usable at all
+	if _, ok := DefaultROMDatabase().Lookup(nil); ok {
+		t.Error("Found an empty ROM")
+	}
+
+	metadata, ok := DefaultROMDatabase().Lookup(ibmLogoROM)
+	if !ok {
+		t.Fatal("IBM Logo was not found")
+	}
+	if metadata.Title != "IBM Logo" || metadata.Platform.Name != COSMACVIP.Name || metadata.Quirks != VIPQuirks {
+		t.Errorf("Metadata is %+v", metadata)
+	}
+}
@@ -0,0 +1,220 @@
+package chip8
+
+import "fmt"
+
+// Most differences of one kind, such as memory addresses, listed in a Divergence
+const maxListedDifferences = 8
+
+// Executed is an instruction that a chip executed, or was about to execute when it stopped
+type Executed struct {
+	Address     uint16
+	Instruction uint16
+	// Set if the chip had faulted and didn't execute anything
+	Faulted bool
+}
+
+func (e Executed) String() string {
+	if e.Faulted {
+		return "(faulted)"
+	}
+	return fmt.Sprintf("0x%03X  %04X  %s", e.Address, e.Instruction, Disassemble(e.Instruction))
+}
+
+// TraceStep is what each chip executed in one cycle of a Differ
+type TraceStep struct {
+	Cycle int
+	A     Executed
+	B     Executed
+}
+
+// Divergence is the first point at which the two chips in a Differ were in different states
+type Divergence struct {
+	// Instructions each chip had executed, and the frame they were executed in
+	Cycle int
+	Frame int
+	// Every part of the state that differed, such as "V3: 0x04 != 0x05"
+	Differences []string
+	// The instructions leading up to the divergence, ending with the one that caused it
+	Before []TraceStep
+	// The instructions that followed, up to the Differ's context length
+	After []TraceStep
+}
+
+/*
+Differ runs two chips side by side with the same input, one instruction at
+a time, and finds the first instruction after which their registers,
+timers, stack, memory or pixels differ. The chips would normally be created
+from the same ROM with the same random source seed and different options,
+to find where a quirk changes a game's behaviour.
+
+Each frame runs a fixed number of instructions followed by a timer tick,
+so timing doesn't depend on the clock. DisplayWait isn't applied, since
+the chips are compared after every instruction.
+*/
+type Differ struct {
+	A *Chip
+	B *Chip
+	// Instructions run per 60Hz frame
+	CyclesPerFrame int
+	// Number of instructions kept in the trace on each side of the divergence
+	Context int
+
+	cycle      int
+	frame      int
+	trace      []TraceStep
+	divergence *Divergence
+}
+
+func NewDiffer(a, b *Chip, cyclesPerFrame int) *Differ {
+	d := &Differ{A: a, B: b, CyclesPerFrame: cyclesPerFrame, Context: 8}
+	// Chips with different memory layouts or fonts differ before they start
+	d.compare()
+	return d
+}
+
+// Returns the first divergence, or nil if the chips haven't diverged
+func (d *Differ) Divergence() *Divergence {
+	return d.divergence
+}
+
+// Reports whether the divergence has been found and the trace after it is complete, or both chips have faulted
+func (d *Differ) Done() bool {
+	if d.A.Fault() != nil && d.B.Fault() != nil {
+		return true
+	}
+	return d.divergence != nil && len(d.divergence.After) >= d.Context
+}
+
+func (d *Differ) Cycle() int {
+	return d.cycle
+}
+
+func (d *Differ) Frame() int {
+	return d.frame
+}
+
+/*
+RunFrame presses the keys on both chips, runs a frame's worth of
+instructions and ticks the timers. It returns whether either screen was
+updated. The chips keep running after they diverge, so that they can
+still be watched.
+*/
+func (d *Differ) RunFrame(keys [16]bool) bool {
+	d.A.SetKeys(keys)
+	d.B.SetKeys(keys)
+	screenUpdated := false
+	for i := 0; i < d.CyclesPerFrame; i++ {
+		step := TraceStep{Cycle: d.cycle, A: nextExecuted(d.A), B: nextExecuted(d.B)}
+		if d.A.Step() {
+			screenUpdated = true
+		}
+		if d.B.Step() {
+			screenUpdated = true
+		}
+		d.cycle++
+		d.record(step)
+	}
+	d.A.DecrementTimers()
+	d.B.DecrementTimers()
+	d.frame++
+	if d.divergence == nil {
+		d.compare()
+	}
+	return screenUpdated
+}
+
+func nextExecuted(chip *Chip) Executed {
+	return Executed{Address: chip.ProgramCounter(), Instruction: chip.CurrentInstruction(), Faulted: chip.Fault() != nil}
+}
+
+// Adds a step to the trace, and checks whether it made the chips diverge
+func (d *Differ) record(step TraceStep) {
+	if d.divergence != nil {
+		if len(d.divergence.After) < d.Context {
+			d.divergence.After = append(d.divergence.After, step)
+		}
+		return
+	}
+	d.trace = append(d.trace, step)
+	if len(d.trace) > d.Context+1 {
+		d.trace = d.trace[1:]
+	}
+	d.compare()
+}
+
+func (d *Differ) compare() {
+	if differences := compareChips(d.A, d.B); len(differences) > 0 {
+		d.divergence = &Divergence{
+			Cycle:       d.cycle,
+			Frame:       d.frame,
+			Differences: differences,
+			Before:      append([]TraceStep(nil), d.trace...),
+		}
+	}
+}
+
+// Lists every difference between the state of two chips
+func compareChips(a, b *Chip) []string {
+	var differences []string
+	differ := func(format string, args ...any) {
+		differences = append(differences, fmt.Sprintf(format, args...))
+	}
+
+	if (a.fault == nil) != (b.fault == nil) {
+		differ("fault: %v != %v", a.fault, b.fault)
+	}
+	if a.programCounter != b.programCounter {
+		differ("PC: 0x%03X != 0x%03X", a.programCounter, b.programCounter)
+	}
+	if a.indexRegister != b.indexRegister {
+		differ("I: 0x%03X != 0x%03X", a.indexRegister, b.indexRegister)
+	}
+	for i := range a.generalRegisters {
+		if a.generalRegisters[i] != b.generalRegisters[i] {
+			differ("V%X: 0x%02X != 0x%02X", i, a.generalRegisters[i], b.generalRegisters[i])
+		}
+	}
+	if a.stackPointer != b.stackPointer || a.stack != b.stack {
+		differ("stack: %03X != %03X", a.stack[:a.stackPointer], b.stack[:b.stackPointer])
+	}
+	if a.delayTimerValue != b.delayTimerValue {
+		differ("delay timer: %d != %d", a.delayTimerValue, b.delayTimerValue)
+	}
+	if a.SoundTimerValue != b.SoundTimerValue {
+		differ("sound timer: %d != %d", a.SoundTimerValue, b.SoundTimerValue)
+	}
+
+	if len(a.memory) != len(b.memory) {
+		differ("memory size: %d != %d", len(a.memory), len(b.memory))
+	}
+	count := 0
+	for address := 0; address < len(a.memory) && address < len(b.memory); address++ {
+		if a.memory[address] == b.memory[address] {
+			continue
+		}
+		if count < maxListedDifferences {
+			differ("memory at 0x%03X: 0x%02X != 0x%02X", address, a.memory[address], b.memory[address])
+		}
+		count++
+	}
+	if count > maxListedDifferences {
+		differ("memory: %d more bytes differ", count-maxListedDifferences)
+	}
+
+	count = 0
+	for x := range a.Pixels {
+		for y := range a.Pixels[x] {
+			if a.Pixels[x][y] == b.Pixels[x][y] {
+				continue
+			}
+			if count < maxListedDifferences {
+				differ("pixel at (%d, %d): %t != %t", x, y, a.Pixels[x][y], b.Pixels[x][y])
+			}
+			count++
+		}
+	}
+	if count > maxListedDifferences {
+		differ("pixels: %d more differ", count-maxListedDifferences)
+	}
+	return differences
+}
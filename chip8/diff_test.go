@@ -0,0 +1,74 @@
+package chip8
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+var shiftROM = []byte{
+	0x60, 0x01, // 200: LD V0, 0x01
+	0x61, 0x08, // 202: LD V1, 0x08
+	0x70, 0x01, // 204: ADD V0, 0x01
+	0x70, 0x01, // 206: ADD V0, 0x01
+	0x70, 0x01, // 208: ADD V0, 0x01
+	0x80, 0x16, // 20A: SHR V0, V1
+	0x12, 0x0C, // 20C: JP 0x20C
+}
+
+func TestDifferFindsDivergence(t *testing.T) {
+	quirks := DefaultQuirks
+	quirks.ShiftUsesVY = false
+	differ := NewDiffer(newSeededChip(shiftROM), newSeededChip(shiftROM, WithQuirks(quirks)), 10)
+	differ.Context = 2
+	differ.RunFrame([16]bool{})
+
+	divergence := differ.Divergence()
+	if divergence == nil {
+		t.Fatal("Chips did not diverge")
+	}
+	if divergence.Cycle != 6 || divergence.Frame != 0 {
+		t.Errorf("Diverged at cycle %d in frame %d", divergence.Cycle, divergence.Frame)
+	}
+	if len(divergence.Differences) != 1 || divergence.Differences[0] != "V0: 0x04 != 0x02" {
+		t.Errorf("Differences are %v", divergence.Differences)
+	}
+	if len(divergence.Before) != 3 || divergence.Before[2].A.Address != 0x20A || divergence.Before[2].B.Instruction != 0x8016 {
+		t.Errorf("Trace before is %v", divergence.Before)
+	}
+	if len(divergence.After) != 2 || divergence.After[0].Cycle != 6 || !differ.Done() {
+		t.Errorf("Trace after is %v", divergence.After)
+	}
+	if !strings.Contains(divergence.Before[2].A.String(), "SHR V0, V1") {
+		t.Errorf("Trace shows %s", divergence.Before[2].A)
+	}
+}
+
+func TestDifferWithoutDivergence(t *testing.T) {
+	rom := []byte{0xC0, 0xFF, 0xF0, 0x15, 0xF0, 0x29, 0xD1, 0x25, 0x71, 0x05, 0x12, 0x00}
+	a := newTestChip(rom, WithRandomSource(rand.NewSource(7)))
+	b := newTestChip(rom, WithRandomSource(rand.NewSource(7)), WithQuirks(SCHIPQuirks))
+	differ := NewDiffer(a, b, 11)
+	for i := 0; i < 100; i++ {
+		var keys [16]bool
+		keys[i%3] = true
+		differ.RunFrame(keys)
+	}
+	if differ.Divergence() != nil || differ.Done() {
+		t.Errorf("Chips diverged: %v", differ.Divergence().Differences)
+	}
+	if differ.Cycle() != 1100 || differ.Frame() != 100 {
+		t.Errorf("Ran %d cycles in %d frames", differ.Cycle(), differ.Frame())
+	}
+}
+
+func TestDifferComparesInitialState(t *testing.T) {
+	differ := NewDiffer(newSeededChip(shiftROM), newSeededChip(shiftROM, WithFont(VIPFont)), 10)
+	divergence := differ.Divergence()
+	if divergence == nil || divergence.Cycle != 0 || len(divergence.Before) != 0 {
+		t.Fatalf("Divergence is %+v", divergence)
+	}
+	if !strings.HasPrefix(divergence.Differences[0], "memory at 0x") {
+		t.Errorf("Differences are %v", divergence.Differences)
+	}
+}
@@ -0,0 +1,47 @@
+package chip8
+
+import "testing"
+
+func TestDisassemble(t *testing.T) {
+	cases := map[uint16]string{
+		0x00E0: "CLS",
+		0x00EE: "RET",
+		0x0123: "SYS 0x123",
+		0x1228: "JP 0x228",
+		0x2ABC: "CALL 0xABC",
+		0x3A45: "SE VA, 0x45",
+		0x5120: "SE V1, V2",
+		0x5121: "DW 0x5121",
+		0x7F01: "ADD VF, 0x01",
+		0x8016: "SHR V0, V1",
+		0x8018: "DW 0x8018",
+		0xA22A: "LD I, 0x22A",
+		0xB300: "JP V0, 0x300",
+		0xD01F: "DRW V0, V1, 15",
+		0xE29E: "SKP V2",
+		0xE300: "DW 0xE300",
+		0xF40A: "LD V4, K",
+		0xF555: "LD [I], V5",
+		0xF565: "LD V5, [I]",
+		0xF5FF: "DW 0xF5FF",
+	}
+	for instruction, expected := range cases {
+		if actual := Disassemble(instruction); actual != expected {
+			t.Errorf("Disassembled %04X as %q, expected %q", instruction, actual, expected)
+		}
+	}
+}
+
+func TestCurrentInstruction(t *testing.T) {
+	chip := newTestChip([]byte{0x60, 0x01, 0x12, 0x34})
+	chip.ExecuteCycle()
+
+	if chip.CurrentInstruction() != 0x1234 || chip.ProgramCounter() != 0x202 {
+		t.Error("Current instruction was not read at the program counter")
+	}
+
+	chip.programCounter = 4095
+	if chip.CurrentInstruction() != 0 {
+		t.Error("Out of bounds instruction was not handled")
+	}
+}
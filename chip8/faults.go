@@ -0,0 +1,125 @@
+package chip8
+
+import "fmt"
+
+/*
+Fault describes an instruction that the chip refused to execute. A
+faulted chip stops executing instructions, with the program counter left
+on the faulting instruction, until it is reset.
+*/
+type Fault struct {
+	Address     uint16
+	Instruction uint16
+	Reason      string
+	// Set when the program counter left memory, so there was no instruction to fetch
+	unfetched bool
+}
+
+func (f *Fault) Error() string {
+	if f.unfetched {
+		return fmt.Sprintf("fault at 0x%03X: %s", f.Address, f.Reason)
+	}
+	return fmt.Sprintf("fault at 0x%03X (%04X %s): %s", f.Address, f.Instruction, Disassemble(f.Instruction), f.Reason)
+}
+
+// MemoryAccessPolicy decides what happens when DXYN, FX33, FX55 or FX65 reach past the end of memory from I
+type MemoryAccessPolicy int
+
+const (
+	// Addresses wrap around to the start of memory, as they do on a machine with 4KB and 12-bit addresses
+	WrapMemoryAccess MemoryAccessPolicy = iota
+	// The instruction faults without accessing memory
+	FaultMemoryAccess
+	// Addresses past the end are clamped to the last byte of memory
+	ClampMemoryAccess
+)
+
+// Returns the fault that stopped the chip, or nil if it hasn't faulted
+func (chip *Chip) Fault() *Fault {
+	return chip.fault
+}
+
+// Stops the chip on the instruction that was just fetched
+func (chip *Chip) raiseFault(instruction uint16, reason string) {
+	chip.programCounter -= 2
+	chip.fault = &Fault{Address: chip.programCounter, Instruction: instruction, Reason: reason}
+	if chip.callbacks.Fault != nil {
+		chip.callbacks.Fault(chip.fault)
+	}
+}
+
+/*
+Reports whether there is a whole instruction at the program counter,
+raising a fault if it has run off the end of memory.
+*/
+func (chip *Chip) canFetch() bool {
+	if int(chip.programCounter)+2 <= len(chip.memory) {
+		return true
+	}
+	chip.fault = &Fault{Address: chip.programCounter, Reason: "program counter is past the end of memory", unfetched: true}
+	if chip.callbacks.Fault != nil {
+		chip.callbacks.Fault(chip.fault)
+	}
+	return false
+}
+
+/*
+Reports whether an instruction may access length bytes of memory from I,
+raising a fault if the access goes past the end of memory and the policy
+is to fault.
+*/
+func (chip *Chip) canAccessMemory(instruction uint16, length int) bool {
+	if chip.memoryAccess != FaultMemoryAccess || int(chip.indexRegister)+length <= len(chip.memory) {
+		return true
+	}
+	chip.raiseFault(instruction, fmt.Sprintf("%d bytes from I=0x%03X reach past the end of memory", length, chip.indexRegister))
+	return false
+}
+
+// Returns the index into memory of the byte offset bytes past I, wrapped or clamped by the policy
+func (chip *Chip) indexedAddress(offset int) int {
+	address := int(chip.indexRegister) + offset
+	if address < len(chip.memory) {
+		return address
+	}
+	if chip.memoryAccess == ClampMemoryAccess {
+		return len(chip.memory) - 1
+	}
+	return address % len(chip.memory)
+}
+
+// Tells the memory write hooks about a write of length bytes from I, in a single call
+func (chip *Chip) indexedMemoryWritten(length int) {
+	start := int(chip.indexRegister)
+	// Clamped bytes all land between I and the end of memory
+	if chip.memoryAccess == ClampMemoryAccess && start+length > len(chip.memory) {
+		if start > len(chip.memory)-1 {
+			start = len(chip.memory) - 1
+		}
+		length = len(chip.memory) - start
+	}
+	chip.memoryWritten(uint16(start), length)
+}
+
+// Returns the name ParseMemoryAccessPolicy accepts for the policy
+func (p MemoryAccessPolicy) String() string {
+	switch p {
+	case FaultMemoryAccess:
+		return "fault"
+	case ClampMemoryAccess:
+		return "clamp"
+	}
+	return "wrap"
+}
+
+func ParseMemoryAccessPolicy(name string) (MemoryAccessPolicy, error) {
+	switch name {
+	case "wrap":
+		return WrapMemoryAccess, nil
+	case "fault":
+		return FaultMemoryAccess, nil
+	case "clamp":
+		return ClampMemoryAccess, nil
+	}
+	return WrapMemoryAccess, fmt.Errorf("unknown memory access policy %q (expected wrap, fault or clamp)", name)
+}
@@ -0,0 +1,155 @@
+package chip8
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWrapMemoryAccess(t *testing.T) {
+	chip := newTestChip([]byte{0xF0, 0x33, 0xF2, 0x55, 0xF2, 0x65, 0xD3, 0x42})
+	chip.generalRegisters[0] = 123
+	chip.indexRegister = 0xFFE
+	chip.Step()
+
+	if chip.memory[0xFFE] != 1 || chip.memory[0xFFF] != 2 || chip.memory[0x000] != 3 {
+		t.Error("FX33 did not wrap around")
+	}
+
+	chip.generalRegisters = [16]byte{0xAA, 0xBB, 0xCC}
+	chip.indexRegister = 0xFFF
+	chip.Step()
+	if chip.memory[0xFFF] != 0xAA || chip.memory[0x000] != 0xBB || chip.memory[0x001] != 0xCC {
+		t.Error("FX55 did not wrap around")
+	}
+	if chip.indexRegister != 0x1002 {
+		t.Errorf("I is 0x%03X after FX55", chip.indexRegister)
+	}
+
+	chip.generalRegisters = [16]byte{}
+	chip.indexRegister = 0xFFF
+	chip.Step()
+	if chip.generalRegisters[0] != 0xAA || chip.generalRegisters[1] != 0xBB || chip.generalRegisters[2] != 0xCC {
+		t.Error("FX65 did not wrap around")
+	}
+
+	chip.indexRegister = 0xFFF
+	chip.Step()
+	// Rows from 0xFFF (0xAA) and 0x000 (0xBB)
+	if !chip.Pixels[0][0] || chip.Pixels[1][0] || !chip.Pixels[2][1] || chip.Pixels[1][1] {
+		t.Error("DXYN did not wrap around")
+	}
+	if chip.Fault() != nil {
+		t.Error(chip.Fault())
+	}
+}
+
+func TestClampMemoryAccess(t *testing.T) {
+	chip := newTestChip([]byte{0xF0, 0x33, 0xF1, 0x65}, WithMemoryAccess(ClampMemoryAccess))
+	chip.generalRegisters[0] = 123
+	chip.indexRegister = 0xFFE
+	chip.Step()
+
+	if chip.memory[0xFFE] != 1 || chip.memory[0xFFF] != 3 || chip.memory[0x000] != 0 {
+		t.Error("FX33 was not clamped to the end of memory")
+	}
+
+	chip.indexRegister = 0xFFF
+	chip.Step()
+	if chip.generalRegisters[0] != 3 || chip.generalRegisters[1] != 3 {
+		t.Error("FX65 was not clamped to the end of memory")
+	}
+}
+
+func TestFaultMemoryAccess(t *testing.T) {
+	var reported *Fault
+	chip := newTestChip([]byte{0xF0, 0x33, 0xF2, 0x55}, WithMemoryAccess(FaultMemoryAccess), WithCallbacks(Callbacks{
+		Fault: func(fault *Fault) { reported = fault },
+	}))
+
+	// Exactly reaching the end of memory is fine
+	chip.indexRegister = 0xFFD
+	chip.Step()
+	if chip.Fault() != nil {
+		t.Fatal(chip.Fault())
+	}
+
+	chip.indexRegister = 0xFFE
+	chip.Step()
+	fault := chip.Fault()
+	if fault == nil || reported != fault {
+		t.Fatal("Access past the end of memory did not fault")
+	}
+	if fault.Address != 0x202 || fault.Instruction != 0xF255 || chip.programCounter != 0x202 {
+		t.Errorf("Fault %v left PC at 0x%03X", fault, chip.programCounter)
+	}
+	if chip.memory[0xFFE] != 0 {
+		t.Error("Faulting instruction wrote to memory")
+	}
+	var err error = fault
+	if !errors.As(err, &fault) {
+		t.Error("Fault is not an error")
+	}
+
+	chip.Step()
+	if chip.programCounter != 0x202 {
+		t.Error("Faulted chip kept executing")
+	}
+	chip.Reset()
+	if chip.Fault() != nil {
+		t.Error("Reset did not clear the fault")
+	}
+}
+
+func TestFaultStopsRunFrame(t *testing.T) {
+	chip := newTestChip([]byte{0xAF, 0xFF, 0xD0, 0x15, 0x12, 0x00}, WithMemoryAccess(FaultMemoryAccess))
+	result := chip.RunFrame()
+
+	if result.Fault == nil || result.Instructions != 2 {
+		t.Errorf("Frame ran %d instructions, fault %v", result.Instructions, result.Fault)
+	}
+}
+
+func TestFaultStopsBlockEngine(t *testing.T) {
+	chip := newTestChip([]byte{0xAF, 0xFF, 0xF3, 0x55, 0x12, 0x00}, WithMemoryAccess(FaultMemoryAccess))
+	engine := NewBlockEngine(chip)
+	executed, _ := engine.Run(100)
+
+	if executed != 2 || chip.Fault() == nil || chip.programCounter != 0x202 {
+		t.Errorf("Engine executed %d instructions and stopped at 0x%03X", executed, chip.programCounter)
+	}
+}
+
+func TestStackFaults(t *testing.T) {
+	chip := newTestChip([]byte{0x00, 0xEE})
+	chip.Step()
+	if fault := chip.Fault(); fault == nil || fault.Address != 0x200 || fault.Instruction != 0x00EE {
+		t.Errorf("Returning with an empty stack faulted with %v", fault)
+	}
+
+	// Calls itself until the stack is full
+	chip = newTestChip([]byte{0x22, 0x00})
+	for i := 0; i < 20; i++ {
+		chip.Step()
+	}
+	if fault := chip.Fault(); fault == nil || chip.stackPointer != 16 || chip.programCounter != 0x200 {
+		t.Errorf("Calling with a full stack faulted with %v", fault)
+	}
+}
+
+func TestJumpPastMemoryFaults(t *testing.T) {
+	chip := newTestChip([]byte{0x1F, 0xFF})
+	chip.RunFrame()
+	fault := chip.Fault()
+	if fault == nil || fault.Address != 0xFFF {
+		t.Fatalf("Jumping to the last byte of memory faulted with %v", fault)
+	}
+	if fault.Error() != "fault at 0xFFF: program counter is past the end of memory" {
+		t.Errorf("Fault is described as %q", fault.Error())
+	}
+
+	chip = newTestChip([]byte{0x1F, 0xFF})
+	executed, _ := NewBlockEngine(chip).Run(10)
+	if executed != 1 || chip.Fault() == nil {
+		t.Errorf("Engine executed %d instructions and faulted with %v", executed, chip.Fault())
+	}
+}
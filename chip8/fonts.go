@@ -0,0 +1,230 @@
+package chip8
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	smallGlyphSize = 5
+	bigGlyphSize   = 10
+	smallFontSize  = 16 * smallGlyphSize
+)
+
+/*
+Font holds the hex digit sprites that FX29 points I at. Interpreters for
+different machines drew the digits differently, so ROMs that display them
+only match screenshots from the original hardware with the right font.
+*/
+type Font struct {
+	Name string
+	// 5 bytes for each of the 16 hex digits
+	Small []byte
+	// 10 bytes for each digit of the SUPER-CHIP 8x10 font used by FX30.
+	// Empty if the font doesn't have one, and some only have 0-9.
+	Big []byte
+}
+
+// The font used by most emulators, from Cowgod's technical reference, and by SUPER-CHIP and Octo
+var commonSmallFont = []byte{
+	0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+	0x20, 0x60, 0x20, 0x20, 0x70, // 1
+	0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+	0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+	0x90, 0x90, 0xF0, 0x10, 0x10, // 4
+	0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+	0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+	0xF0, 0x10, 0x20, 0x40, 0x40, // 7
+	0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+	0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+	0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+	0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
+	0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+	0xE0, 0x90, 0x90, 0x90, 0xE0, // D
+	0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+}
+
+var (
+	DefaultFont = Font{Name: "default", Small: commonSmallFont}
+
+	VIPFont = Font{Name: "vip", Small: []byte{
+		0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+		0x60, 0x20, 0x20, 0x20, 0x70, // 1
+		0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+		0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+		0xA0, 0xA0, 0xF0, 0x20, 0x20, // 4
+		0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+		0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+		0xF0, 0x10, 0x10, 0x10, 0x10, // 7
+		0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+		0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+		0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+		0xF0, 0x50, 0x70, 0x50, 0xF0, // B
+		0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+		0xF0, 0x50, 0x50, 0x50, 0xF0, // D
+		0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+		0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+	}}
+
+	DREAM6800Font = Font{Name: "dream6800", Small: []byte{
+		0xE0, 0xA0, 0xA0, 0xA0, 0xE0, // 0
+		0x40, 0x40, 0x40, 0x40, 0x40, // 1
+		0xE0, 0x20, 0xE0, 0x80, 0xE0, // 2
+		0xE0, 0x20, 0xE0, 0x20, 0xE0, // 3
+		0x80, 0xA0, 0xA0, 0xE0, 0x20, // 4
+		0xE0, 0x80, 0xE0, 0x20, 0xE0, // 5
+		0xE0, 0x80, 0xE0, 0xA0, 0xE0, // 6
+		0xE0, 0x20, 0x20, 0x20, 0x20, // 7
+		0xE0, 0xA0, 0xE0, 0xA0, 0xE0, // 8
+		0xE0, 0xA0, 0xE0, 0x20, 0xE0, // 9
+		0xE0, 0xA0, 0xE0, 0xA0, 0xA0, // A
+		0xC0, 0xA0, 0xE0, 0xA0, 0xC0, // B
+		0xE0, 0x80, 0x80, 0x80, 0xE0, // C
+		0xC0, 0xA0, 0xA0, 0xA0, 0xC0, // D
+		0xE0, 0x80, 0xE0, 0x80, 0xE0, // E
+		0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+	}}
+
+	ETI660Font = Font{Name: "eti660", Small: []byte{
+		0xE0, 0xA0, 0xA0, 0xA0, 0xE0, // 0
+		0x20, 0x20, 0x20, 0x20, 0x20, // 1
+		0xE0, 0x20, 0xE0, 0x80, 0xE0, // 2
+		0xE0, 0x20, 0xE0, 0x20, 0xE0, // 3
+		0xA0, 0xA0, 0xE0, 0x20, 0x20, // 4
+		0xE0, 0x80, 0xE0, 0x20, 0xE0, // 5
+		0xE0, 0x80, 0xE0, 0xA0, 0xE0, // 6
+		0xE0, 0x20, 0x20, 0x20, 0x20, // 7
+		0xE0, 0xA0, 0xE0, 0xA0, 0xE0, // 8
+		0xE0, 0xA0, 0xE0, 0x20, 0xE0, // 9
+		0xE0, 0xA0, 0xE0, 0xA0, 0xA0, // A
+		0x80, 0x80, 0xE0, 0xA0, 0xE0, // B
+		0xE0, 0x80, 0x80, 0x80, 0xE0, // C
+		0x20, 0x20, 0xE0, 0xA0, 0xE0, // D
+		0xE0, 0x80, 0xE0, 0x80, 0xE0, // E
+		0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+	}}
+
+	FishNChipsFont = Font{Name: "fishnchips", Small: []byte{
+		0x60, 0xA0, 0xA0, 0xA0, 0xC0, // 0
+		0x40, 0xC0, 0x40, 0x40, 0xE0, // 1
+		0xC0, 0x20, 0x40, 0x80, 0xE0, // 2
+		0xC0, 0x20, 0x40, 0x20, 0xC0, // 3
+		0x20, 0xA0, 0xE0, 0x20, 0x20, // 4
+		0xE0, 0x80, 0xC0, 0x20, 0xC0, // 5
+		0x40, 0x80, 0xC0, 0xA0, 0x40, // 6
+		0xE0, 0x20, 0x60, 0x40, 0x40, // 7
+		0x40, 0xA0, 0x40, 0xA0, 0x40, // 8
+		0x40, 0xA0, 0x60, 0x20, 0x40, // 9
+		0x40, 0xA0, 0xE0, 0xA0, 0xA0, // A
+		0xC0, 0xA0, 0xC0, 0xA0, 0xC0, // B
+		0x60, 0x80, 0x80, 0x80, 0x60, // C
+		0xC0, 0xA0, 0xA0, 0xA0, 0xC0, // D
+		0xE0, 0x80, 0xC0, 0x80, 0xE0, // E
+		0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+	}}
+
+	// SUPER-CHIP 1.1, whose big font only has the digits 0-9
+	SCHIPFont = Font{Name: "schip", Small: commonSmallFont, Big: []byte{
+		0x3C, 0x7E, 0xE7, 0xC3, 0xC3, 0xC3, 0xC3, 0xE7, 0x7E, 0x3C, // 0
+		0x18, 0x38, 0x58, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x3C, // 1
+		0x3E, 0x7F, 0xC3, 0x06, 0x0C, 0x18, 0x30, 0x60, 0xFF, 0xFF, // 2
+		0x3C, 0x7E, 0xC3, 0x03, 0x0E, 0x0E, 0x03, 0xC3, 0x7E, 0x3C, // 3
+		0x06, 0x0E, 0x1E, 0x36, 0x66, 0xC6, 0xFF, 0xFF, 0x06, 0x06, // 4
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFC, 0xFE, 0x03, 0xC3, 0x7E, 0x3C, // 5
+		0x3E, 0x7C, 0xC0, 0xC0, 0xFC, 0xFE, 0xC3, 0xC3, 0x7E, 0x3C, // 6
+		0xFF, 0xFF, 0x03, 0x06, 0x0C, 0x18, 0x30, 0x60, 0x60, 0x60, // 7
+		0x3C, 0x7E, 0xC3, 0xC3, 0x7E, 0x7E, 0xC3, 0xC3, 0x7E, 0x3C, // 8
+		0x3C, 0x7E, 0xC3, 0xC3, 0x7F, 0x3F, 0x03, 0x03, 0x3E, 0x7C, // 9
+	}}
+
+	OctoFont = Font{Name: "octo", Small: commonSmallFont, Big: []byte{
+		0xFF, 0xFF, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, // 0
+		0x18, 0x78, 0x78, 0x18, 0x18, 0x18, 0x18, 0x18, 0xFF, 0xFF, // 1
+		0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // 2
+		0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 3
+		0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0x03, 0x03, // 4
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 5
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 6
+		0xFF, 0xFF, 0x03, 0x03, 0x06, 0x0C, 0x18, 0x18, 0x18, 0x18, // 7
+		0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 8
+		0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 9
+		0x7E, 0xFF, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xC3, // A
+		0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, // B
+		0x3C, 0xFF, 0xC3, 0xC0, 0xC0, 0xC0, 0xC0, 0xC3, 0xFF, 0x3C, // C
+		0xFC, 0xFE, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFE, 0xFC, // D
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // E
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xC0, 0xC0, // F
+	}}
+)
+
+var (
+	fontsMutex sync.RWMutex
+	fonts      = map[string]Font{}
+)
+
+func init() {
+	for _, font := range []Font{DefaultFont, VIPFont, DREAM6800Font, ETI660Font, FishNChipsFont, SCHIPFont, OctoFont} {
+		if err := RegisterFont(font); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Adds a font that LookupFont can find by name, replacing any font with the same name
+func RegisterFont(font Font) error {
+	if err := font.validate(); err != nil {
+		return err
+	}
+	fontsMutex.Lock()
+	defer fontsMutex.Unlock()
+	fonts[strings.ToLower(font.Name)] = font
+	return nil
+}
+
+func LookupFont(name string) (Font, error) {
+	fontsMutex.RLock()
+	defer fontsMutex.RUnlock()
+	if font, ok := fonts[strings.ToLower(name)]; ok {
+		return font, nil
+	}
+	names := make([]string, 0, len(fonts))
+	for name := range fonts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return Font{}, fmt.Errorf("unknown font %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+/*
+ParseFont reads a font file, which holds the 80 bytes of the small font
+optionally followed by a big font of 100 bytes (0-9) or 160 bytes (0-F).
+*/
+func ParseFont(name string, data []byte) (Font, error) {
+	font := Font{Name: name}
+	if len(data) >= smallFontSize {
+		font.Small = append([]byte(nil), data[:smallFontSize]...)
+		font.Big = append([]byte(nil), data[smallFontSize:]...)
+	}
+	if err := font.validate(); err != nil {
+		return Font{}, fmt.Errorf("font file is %d bytes: %w", len(data), err)
+	}
+	return font, nil
+}
+
+func (font Font) validate() error {
+	if len(font.Small) != smallFontSize {
+		return fmt.Errorf("small font must be %d bytes", smallFontSize)
+	}
+	if size := len(font.Big); size != 0 && size != 10*bigGlyphSize && size != 16*bigGlyphSize {
+		return fmt.Errorf("big font must be %d or %d bytes", 10*bigGlyphSize, 16*bigGlyphSize)
+	}
+	return nil
+}
+
+// Number of bytes of memory the font takes up
+func (font Font) size() int {
+	return len(font.Small) + len(font.Big)
+}
@@ -0,0 +1,98 @@
+package chip8
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBuiltInFonts(t *testing.T) {
+	for _, name := range []string{"default", "vip", "dream6800", "eti660", "fishnchips", "schip", "octo"} {
+		font, err := LookupFont(name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if err := font.validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := LookupFont("VIP"); err != nil {
+		t.Error("Font names are case sensitive")
+	}
+}
+
+func TestFontOption(t *testing.T) {
+	chip := newTestChip([]byte{0xF0, 0x29}, WithFont(VIPFont))
+	chip.generalRegisters[0] = 0xB
+	chip.Step()
+
+	glyph := chip.memory[chip.indexRegister : chip.indexRegister+5]
+	if !bytes.Equal(glyph, []byte{0xF0, 0x50, 0x70, 0x50, 0xF0}) {
+		t.Errorf("Loaded glyph %X", glyph)
+	}
+}
+
+func TestPlatformFont(t *testing.T) {
+	chip := newTestChip([]byte{0x00, 0xE0}, WithPlatform(ETI660))
+	address := ETI660.FontAddress
+	if !bytes.Equal(chip.memory[address:address+smallFontSize], ETI660Font.Small) {
+		t.Error("Platform's font was not loaded")
+	}
+}
+
+func TestBigFont(t *testing.T) {
+	chip := newTestChip([]byte{0xF0, 0x30}, WithFont(OctoFont))
+	chip.generalRegisters[0] = 0x13
+	chip.Step()
+
+	glyph := chip.memory[chip.indexRegister : chip.indexRegister+bigGlyphSize]
+	if !bytes.Equal(glyph, OctoFont.Big[30:40]) {
+		t.Errorf("Loaded big glyph %X", glyph)
+	}
+
+	chip = newTestChip([]byte{0xF0, 0x30})
+	chip.indexRegister = 0x300
+	chip.Step()
+	if chip.indexRegister != 0x300 {
+		t.Error("FX30 changed I without a big font")
+	}
+
+	// SCHIPFont only has the digits 0-9
+	chip = newTestChip([]byte{0xF0, 0x30, 0xF1, 0x30}, WithFont(SCHIPFont))
+	chip.generalRegisters[0] = 9
+	chip.generalRegisters[1] = 0xA
+	chip.Step()
+	if chip.indexRegister != chip.bigFontAddress()+9*bigGlyphSize {
+		t.Errorf("Big glyph 9 is at 0x%03X", chip.indexRegister)
+	}
+	chip.Step()
+	if fault := chip.Fault(); fault == nil || fault.Address != 0x202 {
+		t.Errorf("FX30 for a missing glyph returned fault %v", fault)
+	}
+}
+
+func TestParseFont(t *testing.T) {
+	for size, valid := range map[int]bool{80: true, 180: true, 240: true, 50: false, 81: false, 200: false} {
+		_, err := ParseFont("custom", make([]byte, size))
+		if valid != (err == nil) {
+			t.Errorf("%d byte font file returned %v", size, err)
+		}
+	}
+}
+
+func TestRegisterFont(t *testing.T) {
+	custom, _ := ParseFont("Custom", bytes.Repeat([]byte{0xFF}, smallFontSize))
+	if err := RegisterFont(custom); err != nil {
+		t.Fatal(err)
+	}
+	if font, err := LookupFont("custom"); err != nil || font.Small[0] != 0xFF {
+		t.Error("Registered font was not found")
+	}
+
+	if err := RegisterFont(Font{Name: "broken", Small: []byte{0xFF}}); err == nil {
+		t.Error("Invalid font was registered")
+	}
+	if _, err := NewChip([]byte{0x00, 0xE0}, WithFont(Font{Name: "broken"})); err == nil {
+		t.Error("Chip was created with an invalid font")
+	}
+}
@@ -0,0 +1,473 @@
+package chip8
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+Each instruction is handled by a function that returns whether the screen
+was updated. Rather than decoding every instruction with nested switches
+as it is executed, the handler for each of the 65536 possible instructions
+is looked up once when the package is initialised, so executing an
+instruction is a single indexed call.
+*/
+type instructionHandler func(chip *Chip, instruction uint16) bool
+
+var instructionTable [0x10000]instructionHandler
+
+// Whether each instruction has a handler of its own, rather than opUnknown
+var implementedInstructions [0x10000]bool
+
+func init() {
+	for i := range instructionTable {
+		handler := decodeInstruction(uint16(i))
+		implementedInstructions[i] = handler != nil
+		if handler == nil {
+			handler = opUnknown
+		}
+		instructionTable[i] = handler
+	}
+}
+
+// Returns the handler for an instruction, or nil if it isn't implemented
+func decodeInstruction(instruction uint16) instructionHandler {
+	switch instruction >> 12 {
+	case 0x0:
+		switch instruction {
+		case 0x00E0:
+			return op00E0
+		case 0x00EE:
+			return op00EE
+		}
+	case 0x1:
+		return op1NNN
+	case 0x2:
+		return op2NNN
+	case 0x3:
+		return op3XNN
+	case 0x4:
+		return op4XNN
+	case 0x5:
+		return op5XY0
+	case 0x6:
+		return op6XNN
+	case 0x7:
+		return op7XNN
+	case 0x8:
+		switch instruction & 0xF {
+		case 0x0:
+			return op8XY0
+		case 0x1:
+			return op8XY1
+		case 0x2:
+			return op8XY2
+		case 0x3:
+			return op8XY3
+		case 0x4:
+			return op8XY4
+		case 0x5:
+			return op8XY5
+		case 0x6:
+			return op8XY6
+		case 0x7:
+			return op8XY7
+		case 0xE:
+			return op8XYE
+		}
+	case 0x9:
+		return op9XY0
+	case 0xA:
+		return opANNN
+	case 0xB:
+		return opBNNN
+	case 0xC:
+		return opCXNN
+	case 0xD:
+		return opDXYN
+	case 0xE:
+		switch instruction & 0xFF {
+		case 0x9E:
+			return opEX9E
+		case 0xA1:
+			return opEXA1
+		}
+	case 0xF:
+		switch instruction & 0xFF {
+		case 0x07:
+			return opFX07
+		case 0x0A:
+			return opFX0A
+		case 0x15:
+			return opFX15
+		case 0x18:
+			return opFX18
+		case 0x1E:
+			return opFX1E
+		case 0x29:
+			return opFX29
+		case 0x30:
+			return opFX30
+		case 0x33:
+			return opFX33
+		case 0x55:
+			return opFX55
+		case 0x65:
+			return opFX65
+		}
+	}
+	return nil
+}
+
+func x(instruction uint16) uint16 {
+	return (instruction >> 8) & 0xF
+}
+
+func y(instruction uint16) uint16 {
+	return (instruction >> 4) & 0xF
+}
+
+func nn(instruction uint16) byte {
+	return byte(instruction)
+}
+
+func nnn(instruction uint16) uint16 {
+	return instruction & 0x0FFF
+}
+
+func opUnknown(chip *Chip, instruction uint16) bool {
+	chip.unknownOpcode(instruction)
+	return false
+}
+
+func op00E0(chip *Chip, instruction uint16) bool {
+	for _, column := range chip.Pixels {
+		for j := range column {
+			column[j] = false
+		}
+	}
+	return true
+}
+
+func op00EE(chip *Chip, instruction uint16) bool {
+	if chip.stackPointer == 0 {
+		chip.raiseFault(instruction, "return with an empty stack")
+		return false
+	}
+	chip.stackPointer--
+	chip.programCounter = chip.stack[chip.stackPointer]
+	return false
+}
+
+func op1NNN(chip *Chip, instruction uint16) bool {
+	chip.programCounter = nnn(instruction)
+	return false
+}
+
+func op2NNN(chip *Chip, instruction uint16) bool {
+	if chip.stackPointer == len(chip.stack) {
+		chip.raiseFault(instruction, "call with all 16 stack entries in use")
+		return false
+	}
+	chip.stack[chip.stackPointer] = chip.programCounter
+	chip.programCounter = nnn(instruction)
+	chip.stackPointer++
+	return false
+}
+
+func op3XNN(chip *Chip, instruction uint16) bool {
+	if chip.generalRegisters[x(instruction)] == nn(instruction) {
+		chip.programCounter += 2
+	}
+	return false
+}
+
+func op4XNN(chip *Chip, instruction uint16) bool {
+	if chip.generalRegisters[x(instruction)] != nn(instruction) {
+		chip.programCounter += 2
+	}
+	return false
+}
+
+func op5XY0(chip *Chip, instruction uint16) bool {
+	if chip.generalRegisters[x(instruction)] == chip.generalRegisters[y(instruction)] {
+		chip.programCounter += 2
+	}
+	return false
+}
+
+func op6XNN(chip *Chip, instruction uint16) bool {
+	chip.generalRegisters[x(instruction)] = nn(instruction)
+	return false
+}
+
+func op7XNN(chip *Chip, instruction uint16) bool {
+	chip.generalRegisters[x(instruction)] += nn(instruction)
+	return false
+}
+
+func op8XY0(chip *Chip, instruction uint16) bool {
+	chip.generalRegisters[x(instruction)] = chip.generalRegisters[y(instruction)]
+	return false
+}
+
+func op8XY1(chip *Chip, instruction uint16) bool {
+	chip.generalRegisters[x(instruction)] |= chip.generalRegisters[y(instruction)]
+	if chip.quirks.VFReset {
+		chip.generalRegisters[flagRegisterIndex] = 0
+	}
+	return false
+}
+
+func op8XY2(chip *Chip, instruction uint16) bool {
+	chip.generalRegisters[x(instruction)] &= chip.generalRegisters[y(instruction)]
+	if chip.quirks.VFReset {
+		chip.generalRegisters[flagRegisterIndex] = 0
+	}
+	return false
+}
+
+func op8XY3(chip *Chip, instruction uint16) bool {
+	chip.generalRegisters[x(instruction)] ^= chip.generalRegisters[y(instruction)]
+	if chip.quirks.VFReset {
+		chip.generalRegisters[flagRegisterIndex] = 0
+	}
+	return false
+}
+
+func op8XY4(chip *Chip, instruction uint16) bool {
+	registerValueOne := chip.generalRegisters[x(instruction)]
+	registerValueTwo := chip.generalRegisters[y(instruction)]
+	chip.generalRegisters[x(instruction)] += registerValueTwo
+	if registerValueOne > math.MaxUint8-registerValueTwo {
+		chip.generalRegisters[flagRegisterIndex] = 1
+	} else {
+		chip.generalRegisters[flagRegisterIndex] = 0
+	}
+	return false
+}
+
+func op8XY5(chip *Chip, instruction uint16) bool {
+	registerValueOne := chip.generalRegisters[x(instruction)]
+	registerValueTwo := chip.generalRegisters[y(instruction)]
+	chip.generalRegisters[x(instruction)] -= registerValueTwo
+	if registerValueOne >= registerValueTwo {
+		chip.generalRegisters[flagRegisterIndex] = 1
+	} else {
+		chip.generalRegisters[flagRegisterIndex] = 0
+	}
+	return false
+}
+
+func op8XY6(chip *Chip, instruction uint16) bool {
+	registerValue := chip.generalRegisters[x(instruction)]
+	if chip.quirks.ShiftUsesVY {
+		registerValue = chip.generalRegisters[y(instruction)]
+	}
+	chip.generalRegisters[x(instruction)] = registerValue >> 1
+	chip.generalRegisters[flagRegisterIndex] = registerValue & 0x1
+	return false
+}
+
+func op8XY7(chip *Chip, instruction uint16) bool {
+	registerValueOne := chip.generalRegisters[x(instruction)]
+	registerValueTwo := chip.generalRegisters[y(instruction)]
+	chip.generalRegisters[x(instruction)] = registerValueTwo - registerValueOne
+	if registerValueTwo >= registerValueOne {
+		chip.generalRegisters[flagRegisterIndex] = 1
+	} else {
+		chip.generalRegisters[flagRegisterIndex] = 0
+	}
+	return false
+}
+
+func op8XYE(chip *Chip, instruction uint16) bool {
+	registerValue := chip.generalRegisters[x(instruction)]
+	if chip.quirks.ShiftUsesVY {
+		registerValue = chip.generalRegisters[y(instruction)]
+	}
+	chip.generalRegisters[x(instruction)] = registerValue << 1
+	chip.generalRegisters[flagRegisterIndex] = registerValue >> 7
+	return false
+}
+
+func op9XY0(chip *Chip, instruction uint16) bool {
+	if chip.generalRegisters[x(instruction)] != chip.generalRegisters[y(instruction)] {
+		chip.programCounter += 2
+	}
+	return false
+}
+
+func opANNN(chip *Chip, instruction uint16) bool {
+	chip.indexRegister = nnn(instruction)
+	return false
+}
+
+func opBNNN(chip *Chip, instruction uint16) bool {
+	offset := chip.generalRegisters[0]
+	if chip.quirks.JumpUsesVX {
+		offset = chip.generalRegisters[x(instruction)]
+	}
+	chip.programCounter = uint16(offset) + nnn(instruction)
+	return false
+}
+
+func opCXNN(chip *Chip, instruction uint16) bool {
+	chip.generalRegisters[x(instruction)] = nn(instruction) & byte(chip.rng.Intn(256))
+	return false
+}
+
+func opDXYN(chip *Chip, instruction uint16) bool {
+	height := byte(instruction & 0xF)
+	if !chip.canAccessMemory(instruction, int(height)) {
+		return false
+	}
+	chip.drawSprite(chip.generalRegisters[x(instruction)], chip.generalRegisters[y(instruction)], int(height))
+	return true
+}
+
+// Draws the height bytes at I as a sprite at x, y and sets VF if any pixels were erased
+func (chip *Chip) drawSprite(x, y byte, height int) {
+	startingX := x % pixelsWidth
+	startingY := y % pixelsHeight
+	chip.generalRegisters[flagRegisterIndex] = 0
+	for j := byte(0); j < byte(height); j++ {
+		currByte := chip.memory[chip.indexedAddress(int(j))]
+		currentY := startingY + j
+		if currentY >= pixelsHeight {
+			if chip.quirks.ClipSprites {
+				break
+			}
+			currentY %= pixelsHeight
+		}
+		for i := byte(0); i < 8; i++ {
+			currX := startingX + i
+			if currX >= pixelsWidth {
+				if chip.quirks.ClipSprites {
+					break
+				}
+				currX %= pixelsWidth
+			}
+			currPixel := chip.Pixels[currX][currentY]
+			newPixel := (currByte>>(8-i-1))&1 == 1
+			if currPixel && newPixel {
+				chip.generalRegisters[flagRegisterIndex] = 1
+			}
+			chip.Pixels[currX][currentY] = currPixel != newPixel
+		}
+	}
+}
+
+func opEX9E(chip *Chip, instruction uint16) bool {
+	if chip.keys[chip.generalRegisters[x(instruction)]&0xF] {
+		chip.programCounter += 2
+	}
+	return false
+}
+
+func opEXA1(chip *Chip, instruction uint16) bool {
+	if !chip.keys[chip.generalRegisters[x(instruction)]&0xF] {
+		chip.programCounter += 2
+	}
+	return false
+}
+
+func opFX07(chip *Chip, instruction uint16) bool {
+	chip.generalRegisters[x(instruction)] = chip.delayTimerValue
+	return false
+}
+
+func opFX0A(chip *Chip, instruction uint16) bool {
+	chip.programCounter -= 2
+	if !chip.waitingOnKeyRelease {
+		chip.waitingOnKeyRelease = true
+		chip.previousKeys = chip.keys
+		if chip.callbacks.KeyWait != nil {
+			chip.callbacks.KeyWait()
+		}
+		return false
+	}
+	for i, v := range chip.keys {
+		if !v && chip.previousKeys[i] {
+			chip.generalRegisters[x(instruction)] = byte(i)
+			chip.programCounter += 2
+			chip.waitingOnKeyRelease = false
+			break
+		}
+	}
+	chip.previousKeys = chip.keys
+	return false
+}
+
+func opFX15(chip *Chip, instruction uint16) bool {
+	chip.delayTimerValue = chip.generalRegisters[x(instruction)]
+	return false
+}
+
+func opFX18(chip *Chip, instruction uint16) bool {
+	chip.SoundTimerValue = chip.generalRegisters[x(instruction)]
+	chip.updateSound()
+	return false
+}
+
+func opFX1E(chip *Chip, instruction uint16) bool {
+	chip.indexRegister += uint16(chip.generalRegisters[x(instruction)])
+	return false
+}
+
+func opFX29(chip *Chip, instruction uint16) bool {
+	registerValue := chip.generalRegisters[x(instruction)] & 0xF
+	chip.indexRegister = chip.platform.FontAddress + uint16(registerValue)*smallGlyphSize
+	return false
+}
+
+/*
+Points I at a digit of the SUPER-CHIP big font. Ignored if the font doesn't
+have one, and faults for A-F if it only has 0-9, rather than pointing I
+past the end of the font.
+*/
+func opFX30(chip *Chip, instruction uint16) bool {
+	if len(chip.font.Big) == 0 {
+		return false
+	}
+	registerValue := chip.generalRegisters[x(instruction)] & 0xF
+	if int(registerValue) >= len(chip.font.Big)/bigGlyphSize {
+		chip.raiseFault(instruction, fmt.Sprintf("the %s font has no big glyph for %X", chip.font.Name, registerValue))
+		return false
+	}
+	chip.indexRegister = chip.bigFontAddress() + uint16(registerValue)*bigGlyphSize
+	return false
+}
+
+func opFX33(chip *Chip, instruction uint16) bool {
+	registerValue := chip.generalRegisters[x(instruction)]
+
+	hundredsDigit := (registerValue / 100) % 10
+	tensDigit := (registerValue / 10) % 10
+	onesDigit := registerValue % 10
+
+	if !chip.canAccessMemory(instruction, 3) {
+		return false
+	}
+	chip.indexedMemoryWritten(3)
+	for i, digit := range [3]byte{hundredsDigit, tensDigit, onesDigit} {
+		chip.memory[chip.indexedAddress(i)] = digit
+	}
+	return false
+}
+
+func opFX55(chip *Chip, instruction uint16) bool {
+	if !chip.canAccessMemory(instruction, int(x(instruction))+1) {
+		return false
+	}
+	chip.dumpRegisters(x(instruction))
+	return false
+}
+
+func opFX65(chip *Chip, instruction uint16) bool {
+	if !chip.canAccessMemory(instruction, int(x(instruction))+1) {
+		return false
+	}
+	chip.loadRegisters(x(instruction))
+	return false
+}
@@ -0,0 +1,305 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"reflect"
+)
+
+const (
+	movieVersion = 1
+	// Frames between state checksums
+	defaultChecksumInterval = 60
+)
+
+/*
+Movie is a recording of everything that affects a run of a ROM: the
+settings and random seed the chip was created with, and the keys held and
+number of instructions executed in every frame. Replaying it on a chip
+created from the same ROM reproduces the run exactly, and the state
+checksums taken while recording show where a replay goes out of sync.
+
+Custom opcode handlers, callbacks and changes made to the chip through its
+setters or the debugger aren't recorded.
+*/
+type Movie struct {
+	Version    int    `json:"version"`
+	ROMHash    string `json:"romSHA1"`
+	Seed       int64  `json:"seed"`
+	Platform   string `json:"platform"`
+	MemorySize int    `json:"memorySize"`
+	Quirks     Quirks `json:"quirks"`
+	Font       string `json:"font"`
+	// Names accepted by ParseMemoryAccessPolicy and ParseUnknownOpcodePolicy
+	MemoryAccess     string       `json:"memoryAccess"`
+	UnknownOpcodes   string       `json:"unknownOpcodes"`
+	ChecksumInterval int          `json:"checksumInterval"`
+	Frames           []MovieFrame `json:"frames"`
+}
+
+type MovieFrame struct {
+	// Bit n is set if key n was held
+	Keys   uint16 `json:"keys"`
+	Cycles int    `json:"cycles"`
+	// Set if the chip was reset before the frame
+	Reset bool `json:"reset,omitempty"`
+	// State checksum at the end of the frame, taken every ChecksumInterval frames
+	Checksum uint32 `json:"checksum,omitempty"`
+}
+
+// Desync is returned when a chip's state doesn't match a checksum from a movie or a netplay peer
+type Desync struct {
+	// Index of the frame whose checksum didn't match
+	Frame    int
+	Expected uint32
+	Actual   uint32
+}
+
+func (d *Desync) Error() string {
+	return fmt.Sprintf("desync at frame %d: state checksum is %08X, but %08X was expected", d.Frame, d.Actual, d.Expected)
+}
+
+/*
+RecordMovie creates a chip like NewChip, with a random source seeded with
+seed, along with an empty movie of it. Pass the movie to Runner.Record, or
+add frames with RunMovieFrame, to record it.
+*/
+func RecordMovie(rom []byte, seed int64, options ...Option) (*Chip, *Movie, error) {
+	chip, err := NewChip(rom, append(options, WithRandomSeed(seed))...)
+	if err != nil {
+		return nil, nil, err
+	}
+	movie := &Movie{
+		Version:          movieVersion,
+		ROMHash:          ROMHash(rom),
+		Seed:             seed,
+		MemorySize:       chip.platform.MemorySize,
+		Quirks:           chip.quirks,
+		MemoryAccess:     chip.memoryAccess.String(),
+		UnknownOpcodes:   chip.unknownOpcodes.String(),
+		ChecksumInterval: defaultChecksumInterval,
+	}
+
+	platform := chip.platform
+	for name, known := range platforms {
+		known.MemorySize = platform.MemorySize
+		if reflect.DeepEqual(known, platform) {
+			movie.Platform = name
+		}
+	}
+	if movie.Platform == "" {
+		return nil, nil, fmt.Errorf("platform %s can't be recorded, since it isn't one of the built-in platforms", platform.Name)
+	}
+	if font, err := LookupFont(chip.font.Name); err != nil || !reflect.DeepEqual(font, chip.font) {
+		return nil, nil, fmt.Errorf("font %q can't be recorded, since it isn't registered", chip.font.Name)
+	}
+	movie.Font = chip.font.Name
+	return chip, movie, nil
+}
+
+// ParseMovie reads a movie written by Marshal
+func ParseMovie(data []byte) (*Movie, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var movie Movie
+	if err := decoder.Decode(&movie); err != nil {
+		return nil, fmt.Errorf("invalid movie: %w", err)
+	}
+	if movie.Version != movieVersion {
+		return nil, fmt.Errorf("movie is version %d, but only version %d is supported", movie.Version, movieVersion)
+	}
+	if movie.ChecksumInterval < 1 {
+		return nil, fmt.Errorf("invalid checksum interval %d", movie.ChecksumInterval)
+	}
+	return &movie, nil
+}
+
+func (m *Movie) Marshal() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+/*
+NewChip creates a chip from the ROM with the settings and random seed the
+movie was recorded with, ready to replay it. It returns an error if the
+ROM isn't the one the movie was recorded with.
+*/
+func (m *Movie) NewChip(rom []byte) (*Chip, error) {
+	if hash := ROMHash(rom); hash != m.ROMHash {
+		return nil, fmt.Errorf("ROM doesn't match the movie: its SHA-1 is %s, but the movie was recorded with %s", hash, m.ROMHash)
+	}
+	platform, err := ParsePlatform(m.Platform)
+	if err != nil {
+		return nil, err
+	}
+	font, err := LookupFont(m.Font)
+	if err != nil {
+		return nil, err
+	}
+	memoryAccess, err := ParseMemoryAccessPolicy(m.MemoryAccess)
+	if err != nil {
+		return nil, err
+	}
+	unknownOpcodes, err := ParseUnknownOpcodePolicy(m.UnknownOpcodes)
+	if err != nil {
+		return nil, err
+	}
+	return NewChip(rom,
+		WithPlatform(platform),
+		WithMemorySize(m.MemorySize),
+		WithQuirks(m.Quirks),
+		WithFont(font),
+		WithMemoryAccess(memoryAccess),
+		WithUnknownOpcodes(unknownOpcodes),
+		WithRandomSeed(m.Seed),
+	)
+}
+
+/*
+RunMovieFrame runs a frame on a chip being recorded without a Runner:
+it resets the chip if asked, presses the keys, executes up to the given
+number of instructions and ticks the timers, and adds the frame to the
+movie. Like RunFrame, the frame ends early where the DisplayWait quirk
+waits for the display, and the movie records the instructions that
+actually ran. Returns whether the screen was updated.
+*/
+func (m *Movie) RunMovieFrame(chip *Chip, keys [16]bool, cycles int, reset bool) bool {
+	screenUpdated := false
+	if reset {
+		chip.Reset()
+		screenUpdated = true
+	}
+	chip.SetKeys(keys)
+	executed := 0
+	for executed < cycles && chip.fault == nil {
+		n, updated, frameOver := chip.frameStep(cycles - executed)
+		executed += n
+		if updated {
+			screenUpdated = true
+		}
+		if frameOver {
+			break
+		}
+	}
+	chip.DecrementTimers()
+	m.addFrame(keys, executed, reset, chip)
+	return screenUpdated
+}
+
+/*
+RerecordFrame plays back a frame of the movie on the chip like a replay,
+but takes the frame's state checksum from the chip instead of checking
+it. Editors use it to keep the checksums right after changing the keys of
+a frame. Returns whether the screen was updated.
+*/
+func (m *Movie) RerecordFrame(chip *Chip, index int) bool {
+	screenUpdated := m.Frames[index].run(chip)
+	m.Frames[index].Checksum = 0
+	if (index+1)%m.ChecksumInterval == 0 {
+		m.Frames[index].Checksum = chip.StateChecksum()
+	}
+	return screenUpdated
+}
+
+// Recomputes every state checksum in the movie by replaying it on a new chip created from the ROM
+func (m *Movie) UpdateChecksums(rom []byte) error {
+	chip, err := m.NewChip(rom)
+	if err != nil {
+		return err
+	}
+	for i := range m.Frames {
+		m.RerecordFrame(chip, i)
+	}
+	return nil
+}
+
+// Adds a frame that has just run on the chip
+func (m *Movie) addFrame(keys [16]bool, cycles int, reset bool, chip *Chip) {
+	frame := MovieFrame{Keys: keysToMask(keys), Cycles: cycles, Reset: reset}
+	if (len(m.Frames)+1)%m.ChecksumInterval == 0 {
+		frame.Checksum = chip.StateChecksum()
+	}
+	m.Frames = append(m.Frames, frame)
+}
+
+/*
+Plays back a frame of the movie on the chip, and checks the state checksum
+if the frame has one. Returns whether the screen was updated.
+*/
+func (m *Movie) playFrame(chip *Chip, index int) (bool, *Desync) {
+	screenUpdated := m.Frames[index].run(chip)
+	if (index+1)%m.ChecksumInterval == 0 {
+		if expected, checksum := m.Frames[index].Checksum, chip.StateChecksum(); checksum != expected {
+			return screenUpdated, &Desync{Frame: index, Expected: expected, Actual: checksum}
+		}
+	}
+	return screenUpdated, nil
+}
+
+// Runs the frame on the chip, returning whether the screen was updated
+func (frame MovieFrame) run(chip *Chip) bool {
+	screenUpdated := false
+	if frame.Reset {
+		chip.Reset()
+		screenUpdated = true
+	}
+	chip.SetKeys(maskToKeys(frame.Keys))
+	for i := 0; i < frame.Cycles; i++ {
+		if chip.Step() {
+			screenUpdated = true
+		}
+	}
+	chip.DecrementTimers()
+	return screenUpdated
+}
+
+// Replays the whole movie on a chip created by NewChip, stopping at the first desync
+func (m *Movie) Replay(chip *Chip) error {
+	for i := range m.Frames {
+		if _, desync := m.playFrame(chip, i); desync != nil {
+			return desync
+		}
+	}
+	return nil
+}
+
+/*
+StateChecksum returns a CRC-32 of everything a program can observe: the
+registers, timers, stack, keys, memory and display. Two chips running the
+same program in step have the same checksum.
+*/
+func (chip *Chip) StateChecksum() uint32 {
+	hash := crc32.NewIEEE()
+	binary.Write(hash, binary.BigEndian, chip.generalRegisters)
+	binary.Write(hash, binary.BigEndian, []uint16{chip.indexRegister, chip.programCounter, uint16(chip.stackPointer)})
+	binary.Write(hash, binary.BigEndian, chip.stack)
+	binary.Write(hash, binary.BigEndian, []uint8{chip.delayTimerValue, chip.SoundTimerValue})
+	binary.Write(hash, binary.BigEndian, keysToMask(chip.keys))
+	binary.Write(hash, binary.BigEndian, keysToMask(chip.previousKeys))
+	binary.Write(hash, binary.BigEndian, chip.waitingOnKeyRelease)
+	hash.Write(chip.memory)
+	for _, column := range chip.Pixels {
+		binary.Write(hash, binary.BigEndian, column)
+	}
+	return hash.Sum32()
+}
+
+func keysToMask(keys [16]bool) uint16 {
+	var mask uint16
+	for i, pressed := range keys {
+		if pressed {
+			mask |= 1 << i
+		}
+	}
+	return mask
+}
+
+func maskToKeys(mask uint16) [16]bool {
+	var keys [16]bool
+	for i := range keys {
+		keys[i] = mask&(1<<i) != 0
+	}
+	return keys
+}
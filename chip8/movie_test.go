@@ -0,0 +1,172 @@
+package chip8
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// Mixes random numbers and key presses into memory and the display
+var movieROM = []byte{
+	0xC0, 0xFF, // 200: RND V0, 0xFF
+	0xE1, 0x9E, // 202: SKP V1
+	0x72, 0x01, // 204: ADD V2, 0x01
+	0x82, 0x04, // 206: ADD V2, V0
+	0xA3, 0x00, // 208: LD I, 0x300
+	0xF2, 0x33, // 20A: LD B, V2
+	0xD2, 0x03, // 20C: DRW V2, V0, 3
+	0x12, 0x00, // 20E: JP 0x200
+}
+
+func recordTestMovie(t *testing.T, frames int) (*Chip, *Movie) {
+	t.Helper()
+	chip, movie, err := RecordMovie(movieROM, 42, WithPlatform(HP48))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < frames; i++ {
+		var keys [16]bool
+		keys[0] = i%7 < 3
+		movie.RunMovieFrame(chip, keys, 5+i%4, i == 70)
+	}
+	return chip, movie
+}
+
+func TestMovieReplay(t *testing.T) {
+	recorded, movie := recordTestMovie(t, 150)
+	data, err := movie.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseMovie(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Platform != "hp48" || parsed.Quirks != SCHIPQuirks || parsed.Font != "schip" || len(parsed.Frames) != 150 {
+		t.Errorf("Movie is %+v", parsed)
+	}
+
+	chip, err := parsed.NewChip(movieROM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parsed.Replay(chip); err != nil {
+		t.Fatal(err)
+	}
+	if chip.StateChecksum() != recorded.StateChecksum() || diffChips(chip, recorded) != "" {
+		t.Errorf("Replay ended in a different state: %s", diffChips(chip, recorded))
+	}
+}
+
+func TestMovieDisplayWait(t *testing.T) {
+	recorded, movie, err := RecordMovie(movieROM, 42, WithPlatform(COSMACVIP))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 60; i++ {
+		movie.RunMovieFrame(recorded, [16]bool{}, 20, false)
+	}
+	// The ROM draws on every eighth instruction, which ends the frame
+	if movie.Frames[0].Cycles != 7 || movie.Frames[1].Cycles != 8 {
+		t.Errorf("Frames ran %d and %d instructions", movie.Frames[0].Cycles, movie.Frames[1].Cycles)
+	}
+
+	chip, err := movie.NewChip(movieROM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := movie.Replay(chip); err != nil {
+		t.Fatal(err)
+	}
+	if diff := diffChips(chip, recorded); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestMovieDesync(t *testing.T) {
+	_, movie := recordTestMovie(t, 150)
+	movie.Frames[75].Keys ^= 1
+
+	chip, err := movie.NewChip(movieROM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var desync *Desync
+	if err := movie.Replay(chip); !errors.As(err, &desync) || desync.Frame != 119 {
+		t.Errorf("Replay returned %v", err)
+	}
+}
+
+func TestMovieErrors(t *testing.T) {
+	_, movie := recordTestMovie(t, 1)
+	if _, err := movie.NewChip(shiftROM); err == nil {
+		t.Error("Created a chip from a different ROM")
+	}
+
+	platform := DefaultPlatform
+	platform.FontAddress = 0
+	if _, _, err := RecordMovie(movieROM, 1, WithPlatform(platform)); err == nil {
+		t.Error("Recorded a custom platform")
+	}
+	font := DefaultFont
+	font.Name = "unregistered"
+	if _, _, err := RecordMovie(movieROM, 1, WithFont(font)); err == nil {
+		t.Error("Recorded an unregistered font")
+	}
+	if _, _, err := RecordMovie(movieROM, 1, WithMemorySize(0x2000)); err != nil {
+		t.Errorf("Can't record with a different memory size: %v", err)
+	}
+	if _, err := ParseMovie([]byte(`{"version": 2, "checksumInterval": 60}`)); err == nil {
+		t.Error("Parsed a movie from a later version")
+	}
+}
+
+func TestRunnerRecordAndPlay(t *testing.T) {
+	chip, movie, err := RecordMovie(movieROM, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := NewRunner(chip, 600)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(stopped)
+	}()
+	runner.Record(movie)
+	runner.SetKeys([16]bool{true})
+	waitForFrame(t, runner, func(f *Frame) bool {
+		return f.MovieFrame >= 15
+	})
+	runner.Reset()
+	runner.SetKeys([16]bool{})
+	waitForFrame(t, runner, func(f *Frame) bool {
+		return f.MovieFrame >= 40
+	})
+	runner.Pause()
+	var checksum uint32
+	runner.Inspect(func(chip *Chip) {
+		checksum = chip.StateChecksum()
+	})
+	cancel()
+	<-stopped
+
+	replayed, err := movie.NewChip(movieROM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner, stop := startRunnerWithChip(t, replayed, 600)
+	defer stop()
+	runner.Play(movie)
+	frame := waitForFrame(t, runner, func(f *Frame) bool {
+		return f.MovieFinished
+	})
+	if frame.Desync != nil || !frame.Paused || frame.MovieFrame != len(movie.Frames) {
+		t.Errorf("Playback finished at frame %d, paused %t, with %v", frame.MovieFrame, frame.Paused, frame.Desync)
+	}
+	runner.Inspect(func(chip *Chip) {
+		if chip.StateChecksum() != checksum {
+			t.Error("Playback ended in a different state")
+		}
+	})
+}
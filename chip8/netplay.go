@@ -0,0 +1,556 @@
+package chip8
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	messageJoin byte = iota + 1
+	messageSettings
+	messageInput
+	messageQuit
+)
+
+const (
+	// Most frames of input sent in one message
+	maxInputsPerMessage = 64
+	// How long to go without hearing from the peer before giving up
+	netplayTimeout = 5 * time.Second
+	// How often a guest asks to join until the host answers
+	joinInterval = 200 * time.Millisecond
+)
+
+var (
+	ErrPeerLeft       = errors.New("the other player left the game")
+	ErrNetplayTimeout = errors.New("lost connection to the other player")
+)
+
+// NetplayConfig sets up one player's side of a netplay game
+type NetplayConfig struct {
+	// Keys this player controls. A host that doesn't set any controls every key the guest doesn't.
+	Keys [16]bool
+	// Frames between a key being pressed and the chip seeing it, which gives
+	// the peer's input time to arrive. Only the host's is used.
+	InputDelay int
+	// Instructions executed in each frame. Only the host's is used.
+	CyclesPerFrame int
+	/*
+		Most frames to run ahead of the other player's keys, predicting that
+		they're still holding the keys they last held, which hides the time
+		their keys take to arrive. When a prediction turns out to be wrong,
+		the chip is rolled back to the frame and the frames since are run
+		again. With none, the game runs in lockstep. Each player chooses
+		their own.
+	*/
+	RollbackFrames int
+}
+
+// Sent by the host in answer to a join, with everything the guest needs to create the same chip
+type netplaySettings struct {
+	// Settings and random seed of the chip, without any frames
+	Movie          *Movie `json:"movie,omitempty"`
+	HostKeys       uint16 `json:"hostKeys"`
+	GuestKeys      uint16 `json:"guestKeys"`
+	InputDelay     int    `json:"inputDelay"`
+	CyclesPerFrame int    `json:"cyclesPerFrame"`
+	// Set if the host refused to let the guest join
+	Error string `json:"error,omitempty"`
+}
+
+// NetplayResult describes what happened during a call to Netplay.Tick
+type NetplayResult struct {
+	// Set if a frame was run, rather than waiting for the other player's input
+	Ran           bool
+	ScreenUpdated bool
+	SoundOn       bool
+	// Frames run again because the other player's keys were predicted wrongly
+	RolledBack int
+}
+
+type received struct {
+	message []byte
+	err     error
+}
+
+/*
+Netplay runs a game with two players on two machines. Each player's keys
+are sent to the other, and in lockstep a frame only runs once both
+players' keys for it are known, so both chips always run exactly the same
+frames. With rollback, frames run ahead with the other player's keys
+predicted, and are run again from a save state if the prediction was
+wrong, so the frames the chips end up running are still the same. Every
+message resends the keys the peer hasn't acknowledged yet,
+which makes up for lost messages over UDP, and carries the latest state
+checksum so that the chips going out of sync is noticed.
+
+Both players' frames are recorded in a movie, which can be replayed like
+any other. Callbacks set on the chip are also called for frames that are
+run again.
+*/
+type Netplay struct {
+	chip      *Chip
+	movie     *Movie
+	transport Transport
+	incoming  chan received
+	// Closed by Close to stop the goroutine receiving messages
+	closed chan struct{}
+	// Keys each player controls, as masks
+	localKeys, remoteKeys uint16
+	inputDelay            int
+	cyclesPerFrame        int
+	rollbackFrames        int
+	// Keys for each frame, starting with inputDelay frames of nothing. The
+	// local keys run inputDelay frames ahead of the frame being run.
+	local, remote []uint16
+	// The other player's keys that each frame was run with, which were
+	// predicted for frames whose keys hadn't arrived yet
+	used []uint16
+	// Frames whose keys have all arrived and match the ones they were run with
+	verified int
+	// States at the start of the last rollbackFrames+1 frames, indexed by frame modulo their number
+	states []State
+	// Number of local frames of keys the peer has received
+	acknowledged int
+	// Checksums from the peer for frames that haven't been run here yet, keyed by frame
+	remoteChecksums map[int]uint32
+	lastHeard       time.Time
+	// Kept by the host to answer repeated joins
+	settings []byte
+	err      error
+}
+
+/*
+HostNetplay waits for a guest to join over the transport, and creates the
+chip for the game like RecordMovie, with a random source seeded with seed.
+The guest gets the chip's settings and seed from the host, so only the
+host's options matter.
+*/
+func HostNetplay(transport Transport, rom []byte, seed int64, config NetplayConfig, options ...Option) (*Netplay, error) {
+	chip, movie, err := RecordMovie(rom, seed, options...)
+	if err != nil {
+		return nil, err
+	}
+	n := newNetplay(chip, movie, transport, config)
+
+	var guestKeys uint16
+	deadline := time.Now().Add(netplayTimeout)
+	for {
+		message, err := n.wait(time.Until(deadline))
+		if err != nil {
+			n.Close()
+			return nil, err
+		}
+		if len(message) == 3 && message[0] == messageJoin {
+			guestKeys = binary.BigEndian.Uint16(message[1:])
+			break
+		}
+	}
+
+	settings := netplaySettings{
+		Movie:          movie,
+		HostKeys:       keysToMask(config.Keys),
+		GuestKeys:      guestKeys,
+		InputDelay:     n.inputDelay,
+		CyclesPerFrame: n.cyclesPerFrame,
+	}
+	if settings.HostKeys == 0 {
+		settings.HostKeys = ^guestKeys
+	}
+	if guestKeys == 0 {
+		settings.Error = "the guest doesn't control any keys"
+	} else if overlap := settings.HostKeys & guestKeys; overlap != 0 {
+		settings.Error = fmt.Sprintf("both players control keys %s", describeMask(overlap))
+	}
+	if settings.Error != "" {
+		settings.Movie = nil
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		n.Close()
+		return nil, err
+	}
+	n.settings = append([]byte{messageSettings}, data...)
+	if err := transport.Send(n.settings); err != nil {
+		n.Close()
+		return nil, err
+	}
+	if settings.Error != "" {
+		n.Close()
+		return nil, errors.New(settings.Error)
+	}
+	n.localKeys, n.remoteKeys = settings.HostKeys, settings.GuestKeys
+	n.lastHeard = time.Now()
+	return n, nil
+}
+
+/*
+JoinNetplay joins a game hosted with HostNetplay over the transport, and
+creates the chip for it with the host's settings. It returns an error if
+the ROM isn't the one the host is running.
+*/
+func JoinNetplay(transport Transport, rom []byte, config NetplayConfig) (*Netplay, error) {
+	n := newNetplay(nil, nil, transport, config)
+	join := []byte{messageJoin, 0, 0}
+	binary.BigEndian.PutUint16(join[1:], keysToMask(config.Keys))
+
+	var settings netplaySettings
+	deadline := time.Now().Add(netplayTimeout)
+	for found := false; !found; {
+		// Joins are repeated in case they're lost
+		if err := transport.Send(join); err != nil {
+			n.Close()
+			return nil, err
+		}
+		wait := time.Now().Add(joinInterval)
+		for !found && time.Now().Before(wait) {
+			message, err := n.wait(time.Until(wait))
+			if errors.Is(err, ErrNetplayTimeout) && time.Now().Before(deadline) {
+				break
+			}
+			if err != nil {
+				n.Close()
+				return nil, err
+			}
+			if len(message) > 0 && message[0] == messageSettings {
+				if err := json.Unmarshal(message[1:], &settings); err != nil {
+					n.Close()
+					return nil, fmt.Errorf("invalid settings from the host: %w", err)
+				}
+				found = true
+			}
+		}
+	}
+	if settings.Error != "" {
+		n.Close()
+		return nil, fmt.Errorf("the host refused to start the game: %s", settings.Error)
+	}
+	if settings.Movie == nil || settings.Movie.ChecksumInterval < 1 {
+		n.Close()
+		return nil, errors.New("invalid settings from the host")
+	}
+
+	chip, err := settings.Movie.NewChip(rom)
+	if err != nil {
+		n.Close()
+		return nil, err
+	}
+	n.chip, n.movie = chip, settings.Movie
+	n.localKeys, n.remoteKeys = settings.GuestKeys, settings.HostKeys
+	n.inputDelay, n.cyclesPerFrame = settings.InputDelay, settings.CyclesPerFrame
+	n.local = make([]uint16, n.inputDelay)
+	n.remote = make([]uint16, n.inputDelay)
+	n.acknowledged = n.inputDelay
+	n.lastHeard = time.Now()
+	return n, nil
+}
+
+func newNetplay(chip *Chip, movie *Movie, transport Transport, config NetplayConfig) *Netplay {
+	n := &Netplay{
+		chip:            chip,
+		movie:           movie,
+		transport:       transport,
+		incoming:        make(chan received, 256),
+		closed:          make(chan struct{}),
+		inputDelay:      config.InputDelay,
+		cyclesPerFrame:  config.CyclesPerFrame,
+		rollbackFrames:  config.RollbackFrames,
+		remoteChecksums: map[int]uint32{},
+		lastHeard:       time.Now(),
+	}
+	if n.inputDelay < 0 {
+		n.inputDelay = 0
+	}
+	if n.cyclesPerFrame < 1 {
+		n.cyclesPerFrame = defaultExecutionRateHz / timerRateHz
+	}
+	if n.rollbackFrames < 0 {
+		n.rollbackFrames = 0
+	}
+	if n.rollbackFrames > 0 {
+		n.states = make([]State, n.rollbackFrames+1)
+	}
+	n.local = make([]uint16, n.inputDelay)
+	n.remote = make([]uint16, n.inputDelay)
+	// Both players know that the keys for the delayed frames are empty
+	n.acknowledged = n.inputDelay
+
+	go func() {
+		for {
+			message, err := transport.Receive()
+			select {
+			case n.incoming <- received{message, err}:
+			case <-n.closed:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return n
+}
+
+// Waits for the next message, for up to the timeout
+func (n *Netplay) wait(timeout time.Duration) ([]byte, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-n.incoming:
+		return r.message, r.err
+	case <-timer.C:
+		return nil, ErrNetplayTimeout
+	}
+}
+
+/*
+Tick is called once per frame with the keys held on this machine. It runs
+the next frame if the other player's keys for it have arrived, or are
+within the rollback distance, and sends this player's keys. It returns an error once the players have gone out of
+sync, the connection has been lost or the other player has left, after
+which the game can't continue.
+*/
+func (n *Netplay) Tick(keys [16]bool) (NetplayResult, error) {
+	if n.err != nil {
+		return NetplayResult{}, n.err
+	}
+	result, err := n.tick(keys)
+	if err != nil {
+		n.err = err
+	}
+	return result, err
+}
+
+func (n *Netplay) tick(keys [16]bool) (NetplayResult, error) {
+	if err := n.receive(); err != nil {
+		return NetplayResult{}, err
+	}
+
+	var result NetplayResult
+	result.RolledBack, result.ScreenUpdated = n.correctPredictions()
+	frame := len(n.movie.Frames)
+	if len(n.local) == frame+n.inputDelay {
+		n.local = append(n.local, keysToMask(keys)&n.localKeys)
+	}
+	if frame < len(n.remote)+n.rollbackFrames {
+		if n.runFrame() {
+			result.ScreenUpdated = true
+		}
+		result.Ran = true
+		n.verify()
+	}
+	result.SoundOn = n.chip.soundOn
+	if err := n.checkChecksums(); err != nil {
+		return result, err
+	}
+	return result, n.sendInput()
+}
+
+// Runs the next frame with the other player's keys, or a prediction of them if they haven't arrived
+func (n *Netplay) runFrame() bool {
+	frame := len(n.movie.Frames)
+	if n.states != nil {
+		// Saving can't fail, since netplay chips are always created with a seed
+		n.chip.saveState(&n.states[frame%len(n.states)])
+	}
+	var remote uint16
+	if frame < len(n.remote) {
+		remote = n.remote[frame]
+	} else if len(n.remote) > 0 {
+		remote = n.remote[len(n.remote)-1]
+	}
+	n.used = append(n.used[:frame], remote)
+	return n.movie.RunMovieFrame(n.chip, maskToKeys(n.local[frame]|remote), n.cyclesPerFrame, false)
+}
+
+/*
+Checks the keys that have arrived against the predictions frames were run
+with, and if one was wrong, rolls back to that frame and runs the frames
+since again. Returns the number of frames run again, and whether the
+screen was updated.
+*/
+func (n *Netplay) correctPredictions() (int, bool) {
+	present := len(n.movie.Frames)
+	for frame := n.verified; frame < present && frame < len(n.remote); frame++ {
+		if n.used[frame] == n.remote[frame] {
+			continue
+		}
+		n.chip.LoadState(&n.states[frame%len(n.states)])
+		n.movie.Frames = n.movie.Frames[:frame]
+		for len(n.movie.Frames) < present {
+			n.runFrame()
+		}
+		n.verify()
+		// Loading the state may have changed the display even if running the frames didn't
+		return present - frame, true
+	}
+	n.verify()
+	return 0, false
+}
+
+// Marks the frames whose keys have all arrived as verified, once correctPredictions has run
+func (n *Netplay) verify() {
+	n.verified = len(n.movie.Frames)
+	if n.verified > len(n.remote) {
+		n.verified = len(n.remote)
+	}
+}
+
+// Handles every message that has arrived since the last tick
+func (n *Netplay) receive() error {
+	for {
+		select {
+		case r := <-n.incoming:
+			if r.err != nil {
+				return fmt.Errorf("connection to the other player failed: %w", r.err)
+			}
+			n.lastHeard = time.Now()
+			if err := n.handle(r.message); err != nil {
+				return err
+			}
+		default:
+			if time.Since(n.lastHeard) > netplayTimeout {
+				return ErrNetplayTimeout
+			}
+			return nil
+		}
+	}
+}
+
+func (n *Netplay) handle(message []byte) error {
+	if len(message) == 0 {
+		return nil
+	}
+	switch message[0] {
+	case messageJoin:
+		// The guest didn't get the settings, and is still trying to join
+		if n.settings != nil {
+			return n.transport.Send(n.settings)
+		}
+	case messageQuit:
+		return ErrPeerLeft
+	case messageInput:
+		if len(message) < 10 {
+			return nil
+		}
+		acknowledged := int(binary.BigEndian.Uint32(message[1:]))
+		start := int(binary.BigEndian.Uint32(message[5:]))
+		count := int(message[9])
+		body := message[10:]
+		if len(body) != 2*count+8 {
+			return nil
+		}
+		// A corrupt or hostile message can't acknowledge keys that haven't been sent
+		if acknowledged > len(n.local) {
+			acknowledged = len(n.local)
+		}
+		if acknowledged > n.acknowledged {
+			n.acknowledged = acknowledged
+		}
+		for i := 0; i < count; i++ {
+			// Keys that arrive again or out of order are dropped, and sent again later
+			if start+i == len(n.remote) {
+				n.remote = append(n.remote, binary.BigEndian.Uint16(body[2*i:])&n.remoteKeys)
+			}
+		}
+		if frames := int(binary.BigEndian.Uint32(body[2*count:])); frames > 0 {
+			n.remoteChecksums[frames-1] = binary.BigEndian.Uint32(body[2*count+4:])
+		}
+		return n.checkChecksums()
+	}
+	return nil
+}
+
+// Compares the peer's checksums with the ones for frames that have been verified here
+func (n *Netplay) checkChecksums() error {
+	for frame, checksum := range n.remoteChecksums {
+		if frame >= n.verified {
+			continue
+		}
+		delete(n.remoteChecksums, frame)
+		if actual := n.movie.Frames[frame].Checksum; actual != checksum {
+			return &Desync{Frame: frame, Expected: checksum, Actual: actual}
+		}
+	}
+	return nil
+}
+
+// Sends the keys the peer hasn't acknowledged, the number of its frames received and the latest checksum
+func (n *Netplay) sendInput() error {
+	start := n.acknowledged
+	count := len(n.local) - start
+	if count > maxInputsPerMessage {
+		count = maxInputsPerMessage
+	}
+	message := make([]byte, 10+2*count+8)
+	message[0] = messageInput
+	binary.BigEndian.PutUint32(message[1:], uint32(len(n.remote)))
+	binary.BigEndian.PutUint32(message[5:], uint32(start))
+	message[9] = byte(count)
+	for i := 0; i < count; i++ {
+		binary.BigEndian.PutUint16(message[10+2*i:], n.local[start+i])
+	}
+	body := message[10+2*count:]
+	if frames := n.verified - n.verified%n.movie.ChecksumInterval; frames > 0 {
+		binary.BigEndian.PutUint32(body, uint32(frames))
+		binary.BigEndian.PutUint32(body[4:], n.movie.Frames[frames-1].Checksum)
+	}
+	return n.transport.Send(message)
+}
+
+// Tells the other player that this one is leaving, and closes the transport
+func (n *Netplay) Close() error {
+	select {
+	case <-n.closed:
+		return nil
+	default:
+	}
+	close(n.closed)
+	n.transport.Send([]byte{messageQuit})
+	return n.transport.Close()
+}
+
+func (n *Netplay) Chip() *Chip {
+	return n.chip
+}
+
+// Returns a movie of the frames run so far whose keys have all arrived
+func (n *Netplay) Movie() *Movie {
+	movie := *n.movie
+	movie.Frames = n.movie.Frames[:n.verified]
+	return &movie
+}
+
+// Number of frames run so far, including any run with predicted keys
+func (n *Netplay) Frame() int {
+	return len(n.movie.Frames)
+}
+
+// Number of frames run with both players' keys, which won't be run again
+func (n *Netplay) VerifiedFrame() int {
+	return n.verified
+}
+
+// Returns the keys this player controls
+func (n *Netplay) Keys() [16]bool {
+	return maskToKeys(n.localKeys)
+}
+
+// Frames between a key being pressed and the chip seeing it
+func (n *Netplay) InputDelay() int {
+	return n.inputDelay
+}
+
+// Lists the keys in a mask as hex digits
+func describeMask(mask uint16) string {
+	var digits []byte
+	for i := 0; i < 16; i++ {
+		if mask&(1<<i) != 0 {
+			digits = append(digits, "0123456789ABCDEF"[i])
+		}
+	}
+	return string(digits)
+}
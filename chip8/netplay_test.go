@@ -0,0 +1,386 @@
+package chip8
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Keys the host holds in a frame, of which it only controls key 0
+func hostKeys(frame int) [16]bool {
+	var keys [16]bool
+	keys[0] = frame%7 < 3
+	keys[5] = true
+	return keys
+}
+
+// The guest holds key 0 as well, but only controls key 5
+func guestKeys(frame int) [16]bool {
+	var keys [16]bool
+	keys[0] = true
+	keys[5] = frame%2 == 0
+	return keys
+}
+
+func startNetplay(t *testing.T, network string, host, guest NetplayConfig) (*Netplay, *Netplay, error, error) {
+	t.Helper()
+	listener, err := ListenNetplay(network, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	type hosted struct {
+		netplay *Netplay
+		err     error
+	}
+	done := make(chan hosted)
+	go func() {
+		transport, err := listener.Accept()
+		if err != nil {
+			done <- hosted{nil, err}
+			return
+		}
+		n, err := HostNetplay(transport, movieROM, 42, host, WithPlatform(HP48))
+		done <- hosted{n, err}
+	}()
+
+	transport, err := DialNetplay(network, listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined, joinErr := JoinNetplay(transport, movieROM, guest)
+	result := <-done
+	return result.netplay, joined, result.err, joinErr
+}
+
+func ownKeys(keys ...int) [16]bool {
+	var owned [16]bool
+	for _, key := range keys {
+		owned[key] = true
+	}
+	return owned
+}
+
+// Ticks both players until they've both run the frames, returning the first error
+func runNetplay(t *testing.T, host, guest *Netplay, frames int) error {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for host.Frame() < frames || guest.Frame() < frames {
+		if time.Now().After(deadline) {
+			t.Fatalf("Stuck with the host at frame %d and the guest at frame %d", host.Frame(), guest.Frame())
+		}
+		hostResult, err := host.Tick(hostKeys(host.Frame()))
+		if err != nil {
+			return err
+		}
+		guestResult, err := guest.Tick(guestKeys(guest.Frame()))
+		if err != nil {
+			return err
+		}
+		if !hostResult.Ran && !guestResult.Ran {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	return nil
+}
+
+func TestNetplayLockstep(t *testing.T) {
+	for _, network := range []string{"tcp", "udp"} {
+		t.Run(network, func(t *testing.T) {
+			host, guest, hostErr, guestErr := startNetplay(t, network,
+				NetplayConfig{InputDelay: 2, CyclesPerFrame: 9},
+				NetplayConfig{Keys: ownKeys(5)})
+			if hostErr != nil || guestErr != nil {
+				t.Fatalf("Starting returned %v and %v", hostErr, guestErr)
+			}
+			defer host.Close()
+			defer guest.Close()
+
+			if err := runNetplay(t, host, guest, 150); err != nil {
+				t.Fatal(err)
+			}
+			hostFrames, guestFrames := host.Movie().Frames[:150], guest.Movie().Frames[:150]
+			for i := range hostFrames {
+				if hostFrames[i] != guestFrames[i] {
+					t.Fatalf("Frame %d is %+v for the host and %+v for the guest", i, hostFrames[i], guestFrames[i])
+				}
+			}
+			if guest.InputDelay() != 2 || hostFrames[0].Cycles != 9 || guest.Keys() != ownKeys(5) {
+				t.Error("Guest didn't get the host's settings")
+			}
+
+			// Each player's keys are only the ones they control, and arrive after the input delay
+			for i := 2; i < 150; i++ {
+				expected := keysToMask(hostKeys(i-2))&1 | keysToMask(guestKeys(i-2))&(1<<5)
+				if hostFrames[i].Keys != expected {
+					t.Fatalf("Frame %d has keys %04X, expected %04X", i, hostFrames[i].Keys, expected)
+				}
+			}
+
+			replayed, err := host.Movie().NewChip(movieROM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 150; i++ {
+				host.Movie().RerecordFrame(replayed, i)
+			}
+			if host.Frame() == 150 && replayed.StateChecksum() != host.Chip().StateChecksum() {
+				t.Error("The recorded movie doesn't replay to the same state")
+			}
+		})
+	}
+}
+
+func TestNetplayWaitsForInput(t *testing.T) {
+	host, guest, hostErr, guestErr := startNetplay(t, "tcp",
+		NetplayConfig{InputDelay: 3},
+		NetplayConfig{Keys: ownKeys(5)})
+	if hostErr != nil || guestErr != nil {
+		t.Fatalf("Starting returned %v and %v", hostErr, guestErr)
+	}
+	defer host.Close()
+	defer guest.Close()
+
+	for i := 0; i < 10; i++ {
+		if _, err := host.Tick([16]bool{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if host.Frame() != 3 {
+		t.Errorf("Host ran %d frames without the guest's input", host.Frame())
+	}
+}
+
+func TestNetplayDesync(t *testing.T) {
+	host, guest, hostErr, guestErr := startNetplay(t, "tcp",
+		NetplayConfig{InputDelay: 1},
+		NetplayConfig{Keys: ownKeys(5)})
+	if hostErr != nil || guestErr != nil {
+		t.Fatalf("Starting returned %v and %v", hostErr, guestErr)
+	}
+	defer host.Close()
+	defer guest.Close()
+
+	if err := runNetplay(t, host, guest, 70); err != nil {
+		t.Fatal(err)
+	}
+	guest.Chip().WriteMemory(0x400, 0xAA)
+	var desync *Desync
+	if err := runNetplay(t, host, guest, 200); !errors.As(err, &desync) || desync.Frame != 119 {
+		t.Errorf("Running after the state was changed returned %v", err)
+	}
+}
+
+func TestNetplayKeyOwnership(t *testing.T) {
+	_, _, hostErr, guestErr := startNetplay(t, "udp",
+		NetplayConfig{Keys: ownKeys(0, 5)},
+		NetplayConfig{Keys: ownKeys(5, 6)})
+	if hostErr == nil || guestErr == nil {
+		t.Errorf("Both players controlling key 5 returned %v and %v", hostErr, guestErr)
+	}
+
+	_, _, hostErr, guestErr = startNetplay(t, "tcp", NetplayConfig{}, NetplayConfig{})
+	if hostErr == nil || guestErr == nil {
+		t.Errorf("A guest without any keys returned %v and %v", hostErr, guestErr)
+	}
+}
+
+func TestNetplayPeerLeaves(t *testing.T) {
+	host, guest, hostErr, guestErr := startNetplay(t, "udp", NetplayConfig{}, NetplayConfig{Keys: ownKeys(5)})
+	if hostErr != nil || guestErr != nil {
+		t.Fatalf("Starting returned %v and %v", hostErr, guestErr)
+	}
+	defer guest.Close()
+	if err := runNetplay(t, host, guest, 10); err != nil {
+		t.Fatal(err)
+	}
+	host.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := guest.Tick([16]bool{}); err != nil {
+			if !errors.Is(err, ErrPeerLeft) {
+				t.Errorf("Guest's tick returned %v", err)
+			}
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("Guest didn't notice the host leaving")
+}
+
+func TestNetplayAcknowledgesUnsentKeys(t *testing.T) {
+	host, guest := startLoopbackNetplay(t, 0, NetplayConfig{}, NetplayConfig{Keys: ownKeys(5)})
+	defer host.Close()
+	defer guest.Close()
+
+	// Acknowledges 1000 of the host's keys, with none of the guest's
+	message := make([]byte, 18)
+	message[0] = messageInput
+	message[3], message[4] = 0x03, 0xE8
+	if err := host.handle(message); err != nil {
+		t.Fatal(err)
+	}
+	if host.acknowledged > len(host.local) {
+		t.Errorf("%d of %d keys were acknowledged", host.acknowledged, len(host.local))
+	}
+	if err := host.sendInput(); err != nil {
+		t.Error(err)
+	}
+}
+
+// Starts a game over a loopback transport that delays every message by the latency, on an HP48 unless the options say otherwise
+func startLoopbackNetplay(t *testing.T, latency time.Duration, host, guest NetplayConfig, options ...Option) (*Netplay, *Netplay) {
+	t.Helper()
+	hostTransport, guestTransport := NewLoopback(latency)
+	done := make(chan error)
+	var hosted *Netplay
+	go func() {
+		var err error
+		hosted, err = HostNetplay(hostTransport, movieROM, 42, host, append([]Option{WithPlatform(HP48)}, options...)...)
+		done <- err
+	}()
+	joined, err := JoinNetplay(guestTransport, movieROM, guest)
+	if hostErr := <-done; hostErr != nil || err != nil {
+		t.Fatalf("Starting returned %v and %v", hostErr, err)
+	}
+	return hosted, joined
+}
+
+func TestNetplayRollback(t *testing.T) {
+	host, guest := startLoopbackNetplay(t, 30*time.Millisecond,
+		NetplayConfig{CyclesPerFrame: 9, RollbackFrames: 8},
+		NetplayConfig{Keys: ownKeys(5), RollbackFrames: 8})
+	defer host.Close()
+	defer guest.Close()
+
+	rolledBack, aheadOfInput := 0, 0
+	deadline := time.Now().Add(10 * time.Second)
+	for host.VerifiedFrame() < 200 || guest.VerifiedFrame() < 200 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Stuck with the host at frame %d and the guest at frame %d", host.VerifiedFrame(), guest.VerifiedFrame())
+		}
+		for _, player := range []struct {
+			netplay *Netplay
+			keys    func(int) [16]bool
+		}{{host, hostKeys}, {guest, guestKeys}} {
+			result, err := player.netplay.Tick(player.keys(player.netplay.Frame()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rolledBack += result.RolledBack
+			if ahead := player.netplay.Frame() - player.netplay.VerifiedFrame(); ahead > aheadOfInput {
+				aheadOfInput = ahead
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	if rolledBack == 0 || aheadOfInput == 0 || aheadOfInput > 8 {
+		t.Errorf("Rolled back %d frames, and ran up to %d frames ahead of the other player's keys", rolledBack, aheadOfInput)
+	}
+
+	hostFrames, guestFrames := host.Movie().Frames[:200], guest.Movie().Frames[:200]
+	for i := range hostFrames {
+		if hostFrames[i] != guestFrames[i] {
+			t.Fatalf("Frame %d is %+v for the host and %+v for the guest", i, hostFrames[i], guestFrames[i])
+		}
+	}
+	// Frames that were run again must have ended up as if they'd been run with the right keys
+	replayed, err := host.Movie().NewChip(movieROM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := host.Movie().Replay(replayed); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNetplayDisplayWait(t *testing.T) {
+	host, guest := startLoopbackNetplay(t, 0,
+		NetplayConfig{CyclesPerFrame: 20},
+		NetplayConfig{Keys: ownKeys(5)},
+		WithPlatform(COSMACVIP))
+	defer host.Close()
+	defer guest.Close()
+	if err := runNetplay(t, host, guest, 30); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ROM draws on every eighth instruction, which ends the frame
+	hostFrames, guestFrames := host.Movie().Frames[:30], guest.Movie().Frames[:30]
+	for i := range hostFrames {
+		if hostFrames[i].Cycles > 8 || hostFrames[i] != guestFrames[i] {
+			t.Fatalf("Frame %d is %+v for the host and %+v for the guest", i, hostFrames[i], guestFrames[i])
+		}
+	}
+	replayed, err := host.Movie().NewChip(movieROM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := host.Movie().Replay(replayed); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNetplayRollbackLimit(t *testing.T) {
+	host, guest := startLoopbackNetplay(t, time.Second,
+		NetplayConfig{RollbackFrames: 4},
+		NetplayConfig{Keys: ownKeys(5)})
+	defer host.Close()
+	defer guest.Close()
+
+	for i := 0; i < 10; i++ {
+		if _, err := host.Tick([16]bool{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if host.Frame() != 4 || host.VerifiedFrame() != 0 || len(host.Movie().Frames) != 0 {
+		t.Errorf("Host ran %d frames, of which %d are verified, without the guest's input", host.Frame(), host.VerifiedFrame())
+	}
+}
+
+func TestLoopbackLatency(t *testing.T) {
+	a, b := NewLoopback(20 * time.Millisecond)
+	start := time.Now()
+	a.Send([]byte{1})
+	a.Send([]byte{2})
+	for _, expected := range []byte{1, 2} {
+		message, err := b.Receive()
+		if err != nil || len(message) != 1 || message[0] != expected {
+			t.Fatalf("Received %v, %v", message, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Messages arrived after %v", elapsed)
+	}
+
+	a.Close()
+	if _, err := b.Receive(); err == nil {
+		t.Error("Received from a loopback whose other end was closed")
+	}
+}
+
+// Run with -race, which would also catch Send touching the channel as Close shuts it
+func TestLoopbackSendWhileClosing(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		a, b := NewLoopback(0)
+		var senders sync.WaitGroup
+		for j := 0; j < 4; j++ {
+			senders.Add(1)
+			go func() {
+				defer senders.Done()
+				for a.Send([]byte{1}) == nil {
+				}
+			}()
+		}
+		// Closes once the senders are sending
+		if _, err := b.Receive(); err != nil {
+			t.Fatal(err)
+		}
+		a.Close()
+		senders.Wait()
+		b.Close()
+	}
+}
@@ -0,0 +1,70 @@
+package chip8
+
+/*
+OpcodeHandler implements an opcode in Go, for extensions such as host calls
+through 0NNN or debug print instructions. It's called after the program
+counter has moved past the instruction, and can change any of the chip's
+state through its setters. It returns whether the screen was updated, and
+whether it handled the instruction. If it didn't, the instruction is passed
+on to the handler registered before it, and finally to the built-in
+behaviour.
+*/
+type OpcodeHandler func(chip *Chip, instruction uint16) (screenUpdated bool, handled bool)
+
+// Opcodes whose value, masked by mask, equals value
+type opcodePattern struct {
+	mask  uint16
+	value uint16
+}
+
+func (p opcodePattern) matches(instruction uint16) bool {
+	return instruction&p.mask == p.value
+}
+
+/*
+HandleOpcodes passes every instruction that equals value once masked by
+mask to the handler, so HandleOpcodes(0xF000, 0x0000, handler) claims
+0NNN. Handlers registered later take priority over earlier ones, and they
+are kept across resets. They should be registered before a BlockEngine is
+created for the chip, since it doesn't retranslate code it has cached.
+*/
+func (chip *Chip) HandleOpcodes(mask uint16, value uint16, handler OpcodeHandler) {
+	pattern := opcodePattern{mask: mask, value: value}
+	// The shared table is copied the first time, so that chips without
+	// custom handlers don't pay for them
+	if chip.instructions == &instructionTable {
+		instructions := instructionTable
+		chip.instructions = &instructions
+	}
+	for i := range chip.instructions {
+		if !pattern.matches(uint16(i)) {
+			continue
+		}
+		previous := chip.instructions[i]
+		chip.instructions[i] = func(chip *Chip, instruction uint16) bool {
+			if screenUpdated, handled := handler(chip, instruction); handled {
+				return screenUpdated
+			}
+			return previous(chip, instruction)
+		}
+	}
+	chip.opcodePatterns = append(chip.opcodePatterns, pattern)
+}
+
+// Translates an instruction claimed by HandleOpcodes for BlockEngine
+func (chip *Chip) customInstruction(instruction uint16) compiledInstruction {
+	handler := chip.instructions[instruction]
+	return func(chip *Chip) bool {
+		return handler(chip, instruction)
+	}
+}
+
+// Reports whether a handler registered with HandleOpcodes may handle the instruction
+func (chip *Chip) hasOpcodeHandler(instruction uint16) bool {
+	for _, pattern := range chip.opcodePatterns {
+		if pattern.matches(instruction) {
+			return true
+		}
+	}
+	return false
+}
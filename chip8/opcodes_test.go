@@ -0,0 +1,98 @@
+package chip8
+
+import "testing"
+
+// A host call through 0NNN that adds NNN to V0
+func addSyscall(chip *Chip, instruction uint16) (bool, bool) {
+	if instruction == 0x00E0 || instruction == 0x00EE {
+		return false, false
+	}
+	chip.SetRegister(0, chip.Registers()[0]+byte(instruction&0xFF))
+	return false, true
+}
+
+func TestHandleOpcodes(t *testing.T) {
+	chip := newTestChip([]byte{0x00, 0x05, 0x00, 0xE0, 0x00, 0x07}, WithUnknownOpcodes(FaultUnknownOpcodes))
+	chip.Pixels[0][0] = true
+	chip.HandleOpcodes(0xF000, 0x0000, addSyscall)
+	chip.Step()
+	if !chip.Step() || chip.Pixels[0][0] {
+		t.Error("00E0 did not fall back to the built-in behaviour")
+	}
+	chip.Step()
+
+	if chip.Registers()[0] != 12 || chip.Fault() != nil {
+		t.Errorf("V0 is %d, fault %v", chip.Registers()[0], chip.Fault())
+	}
+	if len(chip.UnknownOpcodes()) != 0 {
+		t.Error("Handled opcodes were reported as unknown")
+	}
+}
+
+func TestHandleOpcodesPriority(t *testing.T) {
+	var calls []string
+	chip := newTestChip([]byte{0x60, 0x01, 0x60, 0x02},
+		WithOpcodeHandler(0xF000, 0x6000, func(chip *Chip, instruction uint16) (bool, bool) {
+			calls = append(calls, "first")
+			return false, true
+		}),
+		WithOpcodeHandler(0xFFFF, 0x6001, func(chip *Chip, instruction uint16) (bool, bool) {
+			calls = append(calls, "second")
+			return false, false
+		}))
+	chip.Step()
+	chip.Step()
+
+	if len(calls) != 3 || calls[0] != "second" || calls[1] != "first" || calls[2] != "first" {
+		t.Errorf("Handlers were called in the order %v", calls)
+	}
+	if chip.Registers()[0] != 0 {
+		t.Error("Built-in 6XNN ran for a handled opcode")
+	}
+
+	chip.Reset()
+	chip.Step()
+	if len(calls) != 5 {
+		t.Error("Handlers were not kept across a reset")
+	}
+}
+
+func TestHandleOpcodesDoesNotAffectOtherChips(t *testing.T) {
+	chip := newTestChip([]byte{0x60, 0x01})
+	chip.HandleOpcodes(0xF000, 0x6000, func(*Chip, uint16) (bool, bool) {
+		return false, true
+	})
+	other := newTestChip([]byte{0x60, 0x01})
+	other.Step()
+	if other.Registers()[0] != 1 {
+		t.Error("Handler was registered for every chip")
+	}
+}
+
+func TestHandleOpcodesWithBlockEngine(t *testing.T) {
+	// Loops over a host call that jumps back to 0x200
+	rom := []byte{0x70, 0x01, 0x01, 0x23}
+	chip := newTestChip(rom, WithOpcodeHandler(0xF000, 0x0000, func(chip *Chip, instruction uint16) (bool, bool) {
+		chip.SetProgramCounter(0x200)
+		return false, true
+	}))
+	NewBlockEngine(chip).Run(10)
+	if chip.Registers()[0] != 5 || chip.ProgramCounter() != 0x200 {
+		t.Errorf("V0 is %d and PC is 0x%03X", chip.Registers()[0], chip.ProgramCounter())
+	}
+}
+
+func TestHandleOpcodesWithRecompiledCode(t *testing.T) {
+	chip := newTestChip([]byte{0x60, 0x05, 0x70, 0x01, 0x12, 0x02})
+	chip.UseRecompiledCode(recompiledCounter)
+	chip.HandleOpcodes(0xF000, 0x7000, func(chip *Chip, instruction uint16) (bool, bool) {
+		chip.SetRegister(0, chip.Registers()[0]*2)
+		return false, true
+	})
+	for i := 0; i < 5; i++ {
+		chip.Step()
+	}
+	if chip.Registers()[0] != 20 {
+		t.Errorf("V0 is %d", chip.Registers()[0])
+	}
+}
@@ -0,0 +1,196 @@
+package chip8
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const defaultExecutionRateHz = 700
+
+/*
+Quirks select between the behaviours that different CHIP-8 interpreters
+disagree on. Many ROMs were written for one particular interpreter and
+only run correctly with its quirks. The JSON names are the ones used by
+the ROM database.
+*/
+type Quirks struct {
+	// 8XY1, 8XY2 and 8XY3 reset VF to 0
+	VFReset bool `json:"vfReset"`
+	// FX55 and FX65 leave I pointing just past the last register
+	MemoryIncrementsIndex bool `json:"memoryIncrementsIndex"`
+	// 8XY6 and 8XYE shift VY into VX rather than shifting VX in place
+	ShiftUsesVY bool `json:"shiftUsesVY"`
+	// BNNN jumps to XNN plus VX rather than NNN plus V0
+	JumpUsesVX bool `json:"jumpUsesVX"`
+	// Sprites are cut off at the edges of the screen rather than wrapping around
+	ClipSprites bool `json:"clipSprites"`
+	// DXYN waits for the next 60Hz frame, so RunFrame draws at most one sprite per frame
+	DisplayWait bool `json:"displayWait"`
+}
+
+// JSON names of every quirk, in the order of the fields of Quirks
+var QuirkNames = []string{"vfReset", "memoryIncrementsIndex", "shiftUsesVY", "jumpUsesVX", "clipSprites", "displayWait"}
+
+// Returns the field for a quirk's JSON name, or nil if there isn't one
+func (q *Quirks) field(name string) *bool {
+	switch name {
+	case "vfReset":
+		return &q.VFReset
+	case "memoryIncrementsIndex":
+		return &q.MemoryIncrementsIndex
+	case "shiftUsesVY":
+		return &q.ShiftUsesVY
+	case "jumpUsesVX":
+		return &q.JumpUsesVX
+	case "clipSprites":
+		return &q.ClipSprites
+	case "displayWait":
+		return &q.DisplayWait
+	}
+	return nil
+}
+
+// Sets a quirk by its JSON name, such as "shiftUsesVY"
+func (q *Quirks) Set(name string, value bool) error {
+	field := q.field(name)
+	if field == nil {
+		last := len(QuirkNames) - 1
+		return fmt.Errorf("unknown quirk %q (expected %s or %s)", name, strings.Join(QuirkNames[:last], ", "), QuirkNames[last])
+	}
+	*field = value
+	return nil
+}
+
+// Reports whether a quirk is set by its JSON name, returning false for unknown names
+func (q Quirks) Get(name string) bool {
+	field := q.field(name)
+	return field != nil && *field
+}
+
+var (
+	// The behaviour of this emulator before quirks were configurable
+	DefaultQuirks = Quirks{VFReset: true, MemoryIncrementsIndex: true, ShiftUsesVY: true, ClipSprites: true}
+	// The original interpreter on the COSMAC VIP
+	VIPQuirks = Quirks{VFReset: true, MemoryIncrementsIndex: true, ShiftUsesVY: true, ClipSprites: true, DisplayWait: true}
+	// SUPER-CHIP 1.1 on the HP48
+	SCHIPQuirks = Quirks{JumpUsesVX: true, ClipSprites: true}
+	// XO-CHIP as implemented by Octo
+	XOCHIPQuirks = Quirks{MemoryIncrementsIndex: true, ShiftUsesVY: true}
+)
+
+// Clock is the source of time that ExecuteCycle decrements the timers by
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+/*
+Callbacks are called by Step, ExecuteCycle and RunFrame as the program
+runs. Any of them may be nil.
+*/
+type Callbacks struct {
+	// Called after an instruction changes the display
+	Draw func()
+	// Called when the sound timer starts or stops the buzzer
+	Sound func(on bool)
+	// Called when FX0A starts waiting for a key to be pressed and released
+	KeyWait func()
+	// Called when the chip faults
+	Fault func(fault *Fault)
+	// Called for every unknown opcode that's executed with HookUnknownOpcodes
+	UnknownOpcode func(address uint16, instruction uint16)
+}
+
+// Option configures a Chip created by NewChip
+type Option func(chip *Chip)
+
+func WithQuirks(quirks Quirks) Option {
+	return func(chip *Chip) {
+		chip.quirks = quirks
+	}
+}
+
+// Uses the given source for CXNN instead of a randomly seeded one, which makes runs reproducible
+func WithRandomSource(source rand.Source) Option {
+	return func(chip *Chip) {
+		chip.rng = rand.New(source)
+		chip.random = nil
+	}
+}
+
+// Seeds the random source for CXNN, which makes runs reproducible and, unlike WithRandomSource, works with save states
+func WithRandomSeed(seed int64) Option {
+	return func(chip *Chip) {
+		chip.random = newSeededSource(seed)
+		chip.rng = rand.New(chip.random)
+	}
+}
+
+func WithClock(clock Clock) Option {
+	return func(chip *Chip) {
+		chip.clock = clock
+	}
+}
+
+// Number of instructions RunFrame executes per second
+func WithExecutionRate(hz int) Option {
+	return func(chip *Chip) {
+		chip.executionRateHz = hz
+	}
+}
+
+// Sets the size of memory in bytes, up to the 64KB that 16-bit addresses can reach
+func WithMemorySize(size int) Option {
+	return func(chip *Chip) {
+		chip.platform.MemorySize = size
+	}
+}
+
+// Sets the memory layout, quirks and font of the platform. Options after it can override any of them.
+func WithPlatform(platform Platform) Option {
+	return func(chip *Chip) {
+		chip.platform = platform
+		chip.quirks = platform.Quirks
+		chip.font = platform.Font
+	}
+}
+
+func WithFont(font Font) Option {
+	return func(chip *Chip) {
+		chip.font = font
+	}
+}
+
+// Sets what happens when I-indexed instructions reach past the end of memory. The default is to wrap around.
+func WithMemoryAccess(policy MemoryAccessPolicy) Option {
+	return func(chip *Chip) {
+		chip.memoryAccess = policy
+	}
+}
+
+// Sets what happens when the chip executes an opcode it doesn't implement. The default is to ignore it.
+func WithUnknownOpcodes(policy UnknownOpcodePolicy) Option {
+	return func(chip *Chip) {
+		chip.unknownOpcodes = policy
+	}
+}
+
+// Registers a custom opcode handler as HandleOpcodes does
+func WithOpcodeHandler(mask uint16, value uint16, handler OpcodeHandler) Option {
+	return func(chip *Chip) {
+		chip.HandleOpcodes(mask, value, handler)
+	}
+}
+
+func WithCallbacks(callbacks Callbacks) Option {
+	return func(chip *Chip) {
+		chip.callbacks = callbacks
+	}
+}
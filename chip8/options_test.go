@@ -0,0 +1,187 @@
+package chip8
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestVFResetQuirk(t *testing.T) {
+	chip := newTestChip([]byte{0x80, 0x11}, WithQuirks(SCHIPQuirks))
+	chip.generalRegisters[flagRegisterIndex] = 1
+	chip.Step()
+
+	if chip.generalRegisters[flagRegisterIndex] != 1 {
+		t.Error("VF was reset without the quirk")
+	}
+}
+
+func TestShiftQuirk(t *testing.T) {
+	chip := newTestChip([]byte{0x80, 0x16}, WithQuirks(SCHIPQuirks))
+	chip.generalRegisters[0] = 0x05
+	chip.generalRegisters[1] = 0xF0
+	chip.Step()
+
+	if chip.generalRegisters[0] != 0x02 || chip.generalRegisters[flagRegisterIndex] != 1 {
+		t.Error("VX was not shifted in place")
+	}
+}
+
+func TestJumpQuirk(t *testing.T) {
+	chip := newTestChip([]byte{0xB3, 0x00}, WithQuirks(SCHIPQuirks))
+	chip.generalRegisters[0] = 0x10
+	chip.generalRegisters[3] = 0x04
+	chip.Step()
+
+	if chip.programCounter != 0x304 {
+		t.Errorf("Jumped to 0x%03X instead of using V3", chip.programCounter)
+	}
+}
+
+func TestMemoryIndexQuirk(t *testing.T) {
+	chip := newTestChip([]byte{0xF2, 0x55, 0xF2, 0x65}, WithQuirks(SCHIPQuirks))
+	chip.indexRegister = 0x300
+	chip.Step()
+	chip.Step()
+
+	if chip.indexRegister != 0x300 {
+		t.Error("Index register was incremented without the quirk")
+	}
+}
+
+func TestSpriteWrapQuirk(t *testing.T) {
+	chip := newTestChip([]byte{0xD0, 0x11}, WithQuirks(XOCHIPQuirks))
+	chip.indexRegister = 0x300
+	chip.memory[0x300] = 0xFF
+	chip.generalRegisters[0] = 60
+	chip.Step()
+
+	if !chip.Pixels[63][0] || !chip.Pixels[0][0] || !chip.Pixels[3][0] || chip.Pixels[4][0] {
+		t.Error("Sprite did not wrap around the edge of the screen")
+	}
+}
+
+func TestDisplayWaitQuirk(t *testing.T) {
+	// Draws on every other instruction
+	chip := newTestChip([]byte{0xD0, 0x11, 0x70, 0x01, 0x12, 0x00}, WithPlatform(COSMACVIP))
+	result := chip.RunFrame()
+
+	if !result.ScreenUpdated || result.Instructions != 1 {
+		t.Errorf("Frame ran %d instructions after drawing", result.Instructions)
+	}
+}
+
+func TestSetQuirk(t *testing.T) {
+	// Every JSON name can be set
+	data, _ := json.Marshal(Quirks{})
+	var names map[string]bool
+	json.Unmarshal(data, &names)
+	var quirks Quirks
+	for name := range names {
+		if err := quirks.Set(name, true); err != nil {
+			t.Error(err)
+		}
+	}
+	if quirks != (Quirks{true, true, true, true, true, true}) {
+		t.Errorf("Quirks are %+v", quirks)
+	}
+	if len(QuirkNames) != len(names) {
+		t.Errorf("QuirkNames has %d names for %d quirks", len(QuirkNames), len(names))
+	}
+	for _, name := range QuirkNames {
+		if _, ok := names[name]; !ok || !quirks.Get(name) {
+			t.Errorf("QuirkNames has an unknown quirk %q", name)
+		}
+	}
+	if (Quirks{ShiftUsesVY: true}).Get("shiftUsesVY") != true || DefaultQuirks.Get("jumpUsesVX") {
+		t.Error("Get returned the wrong value")
+	}
+	if err := quirks.Set("wrapSprites", true); err == nil {
+		t.Error("Set an unknown quirk")
+	}
+}
+
+func TestMemorySizeOption(t *testing.T) {
+	chip := newTestChip([]byte{0x00, 0xE0}, WithMemorySize(0x10000))
+	if len(chip.Memory()) != 0x10000 {
+		t.Error("Memory size was not applied")
+	}
+	// The last instruction in 64KB of memory runs, and the program counter wraps around
+	chip.WriteMemory(0xFFFE, 0x00)
+	chip.WriteMemory(0xFFFF, 0xE0)
+	chip.programCounter = 0xFFFE
+	if chip.CurrentInstruction() != 0x00E0 || !chip.Step() || chip.programCounter != 0 || chip.Fault() != nil {
+		t.Errorf("Last instruction in memory left PC at 0x%04X with fault %v", chip.programCounter, chip.Fault())
+	}
+	chip.programCounter = 0xFFFF
+	chip.Step()
+	if chip.Fault() == nil {
+		t.Error("Instruction past the end of memory did not fault")
+	}
+
+	small := newTestChip([]byte{0x19, 0x00}, WithMemorySize(0x800))
+	small.Step()
+	if small.CurrentInstruction() != 0 || small.Step() || small.Fault() == nil {
+		t.Error("Jump past the end of a small memory did not fault")
+	}
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestClockOption(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	chip := newTestChip([]byte{0x12, 0x00}, WithClock(clock))
+	chip.delayTimerValue = 10
+
+	chip.ExecuteCycle()
+	if chip.delayTimerValue != 10 {
+		t.Error("Timer was decremented before a tick passed")
+	}
+
+	clock.now = clock.now.Add(time.Second / 60)
+	chip.ExecuteCycle()
+	chip.ExecuteCycle()
+	if chip.delayTimerValue != 9 {
+		t.Errorf("Timer is %d after one tick", chip.delayTimerValue)
+	}
+}
+
+func TestRandomSourceOption(t *testing.T) {
+	a := newTestChip([]byte{0xC0, 0xFF}, WithRandomSource(rand.NewSource(7)))
+	b := newTestChip([]byte{0xC0, 0xFF}, WithRandomSource(rand.NewSource(7)))
+	a.Step()
+	b.Step()
+
+	if a.generalRegisters[0] != b.generalRegisters[0] {
+		t.Error("Chips with the same seed produced different numbers")
+	}
+}
+
+func TestCallbacks(t *testing.T) {
+	var draws, keyWaits int
+	var sound []bool
+	chip := newTestChip([]byte{0xD0, 0x11, 0xF0, 0x18, 0xF0, 0x0A}, WithCallbacks(Callbacks{
+		Draw:    func() { draws++ },
+		Sound:   func(on bool) { sound = append(sound, on) },
+		KeyWait: func() { keyWaits++ },
+	}))
+	chip.generalRegisters[0] = 1
+	chip.Step()
+	chip.Step()
+	chip.Step()
+	chip.DecrementTimers()
+
+	if draws != 1 || keyWaits != 1 {
+		t.Errorf("%d draws and %d key waits", draws, keyWaits)
+	}
+	if len(sound) != 2 || !sound[0] || sound[1] {
+		t.Errorf("Sound callbacks %v", sound)
+	}
+}
@@ -0,0 +1,150 @@
+package chip8
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+Platform describes the memory layout of the machine that a ROM was written
+for, along with the quirks of its interpreter. The original machines kept
+their fonts in ROM outside of the CHIP-8 address space, so the presets put
+the font in the reserved low memory like most emulators do.
+*/
+type Platform struct {
+	Name       string
+	MemorySize int
+	// Address the ROM is loaded at and execution starts from
+	ProgramStart uint16
+	FontAddress  uint16
+	// Memory the interpreter uses for itself, such as its stack and display
+	// buffer, which a ROM can't be loaded into
+	Reserved []Region
+	Quirks   Quirks
+	Font     Font
+}
+
+var (
+	// The layout this emulator has always used, with all memory past 0x200 available to the ROM
+	DefaultPlatform = Platform{
+		Name:         "CHIP-8",
+		MemorySize:   4096,
+		ProgramStart: 0x200,
+		FontAddress:  0x50,
+		Reserved:     []Region{{0x000, 0x200}},
+		Quirks:       DefaultQuirks,
+		Font:         DefaultFont,
+	}
+	// The top of memory holds the interpreter's stack, work area and display buffer
+	COSMACVIP = Platform{
+		Name:         "COSMAC VIP",
+		MemorySize:   4096,
+		ProgramStart: 0x200,
+		FontAddress:  0x50,
+		Reserved:     []Region{{0x000, 0x200}, {0xEA0, 0x1000}},
+		Quirks:       VIPQuirks,
+		Font:         VIPFont,
+	}
+	// Programs start at 0x600
+	ETI660 = Platform{
+		Name:         "ETI-660",
+		MemorySize:   4096,
+		ProgramStart: 0x600,
+		FontAddress:  0x50,
+		Reserved:     []Region{{0x000, 0x600}, {0xEA0, 0x1000}},
+		Quirks:       VIPQuirks,
+		Font:         ETI660Font,
+	}
+	// The default layout with the COSMAC VIP quirks and the font from CHIPOS, the DREAM 6800's interpreter
+	DREAM6800 = Platform{
+		Name:         "DREAM 6800",
+		MemorySize:   4096,
+		ProgramStart: 0x200,
+		FontAddress:  0x50,
+		Reserved:     []Region{{0x000, 0x200}},
+		Quirks:       VIPQuirks,
+		Font:         DREAM6800Font,
+	}
+	// SUPER-CHIP on the HP48 calculators
+	HP48 = Platform{
+		Name:         "HP48",
+		MemorySize:   4096,
+		ProgramStart: 0x200,
+		FontAddress:  0x50,
+		Reserved:     []Region{{0x000, 0x200}},
+		Quirks:       SCHIPQuirks,
+		Font:         SCHIPFont,
+	}
+	// XO-CHIP in Octo, with 64KB of memory
+	Octo = Platform{
+		Name:         "Octo",
+		MemorySize:   0x10000,
+		ProgramStart: 0x200,
+		FontAddress:  0x50,
+		Reserved:     []Region{{0x000, 0x200}},
+		Quirks:       XOCHIPQuirks,
+		Font:         OctoFont,
+	}
+)
+
+// Platforms by the names accepted by ParsePlatform
+var platforms = map[string]Platform{
+	"chip8":     DefaultPlatform,
+	"vip":       COSMACVIP,
+	"eti660":    ETI660,
+	"dream6800": DREAM6800,
+	"hp48":      HP48,
+	"octo":      Octo,
+}
+
+func ParsePlatform(name string) (Platform, error) {
+	if platform, ok := platforms[strings.ToLower(name)]; ok {
+		return platform, nil
+	}
+	names := make([]string, 0, len(platforms))
+	for name := range platforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return Platform{}, fmt.Errorf("unknown platform %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// Checks that the layout and font fit in memory, and that programs can be loaded without overwriting the font
+func (platform Platform) validate(font Font) error {
+	if platform.MemorySize <= int(platform.ProgramStart) || platform.MemorySize > 0x10000 {
+		return fmt.Errorf("memory size of %d bytes doesn't fit a program at 0x%03X", platform.MemorySize, platform.ProgramStart)
+	}
+	fontStart, fontEnd := int(platform.FontAddress), int(platform.FontAddress)+font.size()
+	if fontEnd > platform.MemorySize {
+		return fmt.Errorf("font at 0x%03X doesn't fit in memory", platform.FontAddress)
+	}
+	if fontStart < int(platform.ProgramStart) && fontEnd > int(platform.ProgramStart) {
+		return fmt.Errorf("font at 0x%03X-0x%03X overlaps the program start 0x%03X", fontStart, fontEnd-1, platform.ProgramStart)
+	}
+	fontReserved := false
+	for _, region := range platform.Reserved {
+		if platform.ProgramStart >= region.Start && platform.ProgramStart < region.End {
+			return fmt.Errorf("program start 0x%03X is in reserved memory", platform.ProgramStart)
+		}
+		if fontStart >= int(region.Start) && fontEnd <= int(region.End) {
+			fontReserved = true
+		}
+	}
+	// Otherwise loading a ROM would overwrite it
+	if !fontReserved {
+		return fmt.Errorf("font at 0x%03X-0x%03X isn't in reserved memory", fontStart, fontEnd-1)
+	}
+	return nil
+}
+
+// Returns the number of bytes from the program start to the end of memory or the next reserved region
+func (platform Platform) programSpace() int {
+	end := platform.MemorySize
+	for _, region := range platform.Reserved {
+		if region.Start >= platform.ProgramStart && int(region.Start) < end {
+			end = int(region.Start)
+		}
+	}
+	return end - int(platform.ProgramStart)
+}
@@ -0,0 +1,82 @@
+package chip8
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestETI660Layout(t *testing.T) {
+	chip := newTestChip([]byte{0xF0, 0x29}, WithPlatform(ETI660))
+	if chip.programCounter != 0x600 || chip.memory[0x600] != 0xF0 {
+		t.Fatal("ROM was not loaded at 0x600")
+	}
+	chip.generalRegisters[0] = 2
+	chip.Step()
+
+	if chip.indexRegister != ETI660.FontAddress+10 {
+		t.Errorf("Font character is at 0x%03X", chip.indexRegister)
+	}
+}
+
+func TestCustomFontAddress(t *testing.T) {
+	platform := DefaultPlatform
+	platform.FontAddress = 0x000
+	chip := newTestChip([]byte{0xF0, 0x29}, WithPlatform(platform))
+	chip.generalRegisters[0] = 0xF
+	chip.Step()
+
+	if chip.indexRegister != 75 || chip.memory[75] != DefaultFont.Small[75] {
+		t.Error("Font was not loaded at 0x000")
+	}
+}
+
+func TestReservedMemory(t *testing.T) {
+	var tooLarge *ROMTooLargeError
+	if _, err := NewChip(make([]byte, 0xEA0-0x200+1), WithPlatform(COSMACVIP)); !errors.As(err, &tooLarge) {
+		t.Errorf("ROM overlapping the VIP's reserved memory returned %v", err)
+	}
+	if _, err := NewChip(make([]byte, 0xEA0-0x200), WithPlatform(COSMACVIP)); err != nil {
+		t.Error(err)
+	}
+	if _, err := NewChip(make([]byte, 0x10000-0x200), WithPlatform(Octo)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInvalidPlatform(t *testing.T) {
+	platform := DefaultPlatform
+	platform.ProgramStart = 0x100
+	if _, err := NewChip([]byte{0x00, 0xE0}, WithPlatform(platform)); err == nil {
+		t.Error("Program start in reserved memory was accepted")
+	}
+
+	if _, err := NewChip([]byte{0x00, 0xE0}, WithMemorySize(0x100)); err == nil {
+		t.Error("Memory smaller than the program start was accepted")
+	}
+
+	platform = DefaultPlatform
+	platform.FontAddress = 0x1C0
+	if _, err := NewChip([]byte{0x00, 0xE0}, WithPlatform(platform)); err == nil {
+		t.Error("Font overlapping the program start was accepted")
+	}
+
+	platform = COSMACVIP
+	platform.FontAddress = 0x300
+	if _, err := NewChip([]byte{0x00, 0xE0}, WithPlatform(platform)); err == nil {
+		t.Error("Font outside reserved memory was accepted")
+	}
+	platform.FontAddress = 0xEA0
+	if _, err := NewChip([]byte{0x00, 0xE0}, WithPlatform(platform)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParsePlatform(t *testing.T) {
+	platform, err := ParsePlatform("ETI660")
+	if err != nil || platform.Name != "ETI-660" {
+		t.Errorf("Parsed %q, %v", platform.Name, err)
+	}
+	if _, err := ParsePlatform("c64"); err == nil {
+		t.Error("Unknown platform was parsed")
+	}
+}
@@ -0,0 +1,255 @@
+package chip8
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+type RecompileOptions struct {
+	// Name of the generated package. A main package also gets a main
+	// function that runs the game in the io frontend.
+	Package string
+	// Name of the ROM file, used in the generated header comment
+	Source          string
+	ExecutionRateHz int
+}
+
+/*
+Recompile translates every instruction that AnalyzeROM can reach into Go
+code, producing the source of a package with a NewChip function that runs
+the ROM with the recompiled code. Each basic block becomes straight-line
+Go that runs until the next branch, draw or write to memory, so batches of
+instructions run without going back to the chip in between. The generated
+NewChip takes the same options as chip8.NewChip, but returns an error for
+options that move the program or change the size of memory. Instructions
+that can't be reached statically (such as the targets of BNNN) and code
+that the ROM overwrites at runtime fall back to the interpreter.
+*/
+func Recompile(rom []byte, options RecompileOptions) ([]byte, error) {
+	if options.Package == "" {
+		options.Package = "main"
+	}
+	if options.ExecutionRateHz == 0 {
+		options.ExecutionRateHz = 700
+	}
+	if _, err := ValidateROM(rom, DefaultPlatform); err != nil {
+		return nil, err
+	}
+	flow := AnalyzeROM(rom)
+	memory := make([]byte, DefaultPlatform.MemorySize)
+	copy(memory[DefaultPlatform.ProgramStart:], rom)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by chip8 recompile from %s; DO NOT EDIT.\n\n", options.Source)
+	fmt.Fprintf(&b, "package %s\n\n", options.Package)
+	b.WriteString("import (\n\t\"errors\"\n\n\t\"github.com/rdhillon1016/chip8-emulator/chip8\"\n")
+	if options.Package == "main" {
+		b.WriteString("\t\"github.com/rdhillon1016/chip8-emulator/io\"\n")
+	}
+	b.WriteString(")\n\n")
+
+	b.WriteString("var rom = []byte{")
+	for i, v := range rom {
+		if i%16 == 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "0x%02X, ", v)
+	}
+	b.WriteString("\n}\n\n")
+
+	// The addresses in the generated code only match memory with the layout the ROM was recompiled for
+	b.WriteString("func NewChip(options ...chip8.Option) (*chip8.Chip, error) {\n\tchip, err := chip8.NewChip(rom, options...)\n\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+	fmt.Fprintf(&b, "\tif platform := chip.Platform(); platform.ProgramStart != 0x%03X || platform.MemorySize != %d {\n", DefaultPlatform.ProgramStart, DefaultPlatform.MemorySize)
+	fmt.Fprintf(&b, "\t\treturn nil, errors.New(\"recompiled code needs programs at 0x%03X and %d bytes of memory\")\n\t}\n", DefaultPlatform.ProgramStart, DefaultPlatform.MemorySize)
+	b.WriteString("\tchip.UseRecompiledCode(run)\n\treturn chip, nil\n}\n\n")
+	if options.Package == "main" {
+		b.WriteString("func main() {\n\tchip, err := NewChip()\n\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+		fmt.Fprintf(&b, "\tio.Run(chip, io.Config{ExecutionRateHz: %d})\n}\n\n", options.ExecutionRateHz)
+	}
+
+	// Every instruction is an entry point, which jumps into the middle of its block
+	b.WriteString("func run(rt *chip8.Runtime, cycles int) (executed int, screenUpdated bool) {\nfor executed < cycles {\nswitch *rt.PC {\n")
+	blocks := blocks(memory, flow.Regions())
+	for _, block := range blocks {
+		for address := block.Start; address < block.End; address += 2 {
+			fmt.Fprintf(&b, "case 0x%03X:\nif rt.Modified(0x%03X, 0x%03X) {\nreturn\n}\ngoto l%03X\n", address, address, block.End, address)
+		}
+	}
+	b.WriteString("default:\nreturn\n}\n\n")
+	for _, block := range blocks {
+		fmt.Fprintf(&b, "\n// Block 0x%03X-0x%03X\n", block.Start, block.End)
+		for address := block.Start; address < block.End; address += 2 {
+			instruction := uint16(memory[address])<<8 | uint16(memory[address+1])
+			fmt.Fprintf(&b, "l%03X: // %04X %s\nexecuted++\n", address, instruction, Disassemble(instruction))
+			if endsRecompiledBlock(instruction) {
+				b.WriteString(translateBranch(address, instruction))
+				continue
+			}
+			b.WriteString(translate(address, instruction))
+			fmt.Fprintf(&b, "if executed == cycles {\n*rt.PC = 0x%03X\nreturn\n}\n", address+2)
+			// Regions can end without a branch, where the code runs into data
+			if address+2 == block.End {
+				fmt.Fprintf(&b, "*rt.PC = 0x%03X\ncontinue\n", block.End)
+			}
+		}
+	}
+	b.WriteString("}\nreturn\n}\n")
+
+	return format.Source(b.Bytes())
+}
+
+/*
+Splits the regions into blocks of straight-line code, each of which ends
+with an instruction that endsRecompiledBlock or at the end of its region.
+*/
+func blocks(memory []byte, regions []Region) []Region {
+	var blocks []Region
+	for _, region := range regions {
+		start := region.Start
+		for address := region.Start; address < region.End; address += 2 {
+			instruction := uint16(memory[address])<<8 | uint16(memory[address+1])
+			if endsRecompiledBlock(instruction) || address+2 == region.End {
+				blocks = append(blocks, Region{Start: start, End: address + 2})
+				start = address + 2
+			}
+		}
+	}
+	return blocks
+}
+
+/*
+Reports whether recompiled code has to go back to the top of its loop
+after the instruction: because it can continue somewhere other than the
+next instruction, has updated the screen, or may have overwritten code.
+*/
+func endsRecompiledBlock(instruction uint16) bool {
+	if !implementedInstructions[instruction] || endsBlock(instruction) {
+		return true
+	}
+	switch {
+	case instruction == 0x00E0, instruction>>12 == 0xD:
+		return true
+	case instruction&0xF0FF == 0xF033, instruction&0xF0FF == 0xF055:
+		return true
+	}
+	return false
+}
+
+// Returns the Go statements for an instruction that doesn't end a block
+func translate(address, instruction uint16) string {
+	vx, vy, value := x(instruction), y(instruction), nn(instruction)
+	switch instruction >> 12 {
+	case 0x6:
+		return fmt.Sprintf("rt.V[0x%X] = 0x%02X\n", vx, value)
+	case 0x7:
+		return fmt.Sprintf("rt.V[0x%X] += 0x%02X\n", vx, value)
+	case 0x8:
+		switch instruction & 0xF {
+		case 0x0:
+			return fmt.Sprintf("rt.V[0x%X] = rt.V[0x%X]\n", vx, vy)
+		case 0x1, 0x2, 0x3:
+			operator := map[uint16]string{0x1: "|", 0x2: "&", 0x3: "^"}[instruction&0xF]
+			return fmt.Sprintf("rt.V[0x%X] %s= rt.V[0x%X]\nif rt.Quirks.VFReset {\nrt.V[0xF] = 0\n}\n", vx, operator, vy)
+		case 0x4:
+			return fmt.Sprintf("if v := rt.V[0x%X]; rt.V[0x%X] > 0xFF-v {\nrt.V[0x%X] += v\nrt.V[0xF] = 1\n} else {\nrt.V[0x%X] += v\nrt.V[0xF] = 0\n}\n", vy, vx, vx, vx)
+		case 0x5:
+			return fmt.Sprintf("if v := rt.V[0x%X]; rt.V[0x%X] >= v {\nrt.V[0x%X] -= v\nrt.V[0xF] = 1\n} else {\nrt.V[0x%X] -= v\nrt.V[0xF] = 0\n}\n", vy, vx, vx, vx)
+		case 0x7:
+			return fmt.Sprintf("if v := rt.V[0x%X]; rt.V[0x%X] >= v {\nrt.V[0x%X] = rt.V[0x%X] - v\nrt.V[0xF] = 1\n} else {\nrt.V[0x%X] = rt.V[0x%X] - v\nrt.V[0xF] = 0\n}\n", vx, vy, vx, vy, vx, vy)
+		case 0x6:
+			return fmt.Sprintf("{\nv := rt.V[0x%X]\nif rt.Quirks.ShiftUsesVY {\nv = rt.V[0x%X]\n}\nrt.V[0x%X] = v >> 1\nrt.V[0xF] = v & 0x1\n}\n", vx, vy, vx)
+		case 0xE:
+			return fmt.Sprintf("{\nv := rt.V[0x%X]\nif rt.Quirks.ShiftUsesVY {\nv = rt.V[0x%X]\n}\nrt.V[0x%X] = v << 1\nrt.V[0xF] = v >> 7\n}\n", vx, vy, vx)
+		}
+	case 0xA:
+		return fmt.Sprintf("*rt.I = 0x%03X\n", nnn(instruction))
+	case 0xC:
+		return fmt.Sprintf("rt.V[0x%X] = 0x%02X & rt.Random()\n", vx, value)
+	case 0xF:
+		switch instruction & 0xFF {
+		case 0x07:
+			return fmt.Sprintf("rt.V[0x%X] = *rt.DT\n", vx)
+		case 0x15:
+			return fmt.Sprintf("*rt.DT = rt.V[0x%X]\n", vx)
+		case 0x18:
+			return fmt.Sprintf("rt.SetSoundTimer(rt.V[0x%X])\n", vx)
+		case 0x1E:
+			return fmt.Sprintf("*rt.I += uint16(rt.V[0x%X])\n", vx)
+		case 0x29:
+			return fmt.Sprintf("*rt.I = rt.Glyph(rt.V[0x%X])\n", vx)
+		case 0x30:
+			return fmt.Sprintf("*rt.PC = 0x%03X\nif !rt.BigGlyph(0x%04X) {\nreturn\n}\n", address+2, instruction)
+		case 0x65:
+			var code strings.Builder
+			fmt.Fprintf(&code, "*rt.PC = 0x%03X\nif !rt.Access(0x%04X, %d) {\nreturn\n}\n", address+2, instruction, vx+1)
+			for r := uint16(0); r <= vx; r++ {
+				fmt.Fprintf(&code, "rt.V[0x%X] = rt.Load(%d)\n", r, r)
+			}
+			fmt.Fprintf(&code, "if rt.Quirks.MemoryIncrementsIndex {\n*rt.I += %d\n}\n", vx+1)
+			return code.String()
+		}
+	}
+	panic(fmt.Sprintf("no translation for %04X", instruction))
+}
+
+/*
+Returns the Go statements for an instruction that ends a block, which set
+the program counter and either go back to the top of the loop or return
+*/
+func translateBranch(address, instruction uint16) string {
+	vx, vy, value, target := x(instruction), y(instruction), nn(instruction), nnn(instruction)
+	next := address + 2
+	skip := func(condition string) string {
+		return fmt.Sprintf("if %s {\n*rt.PC = 0x%03X\n} else {\n*rt.PC = 0x%03X\n}\ncontinue\n", condition, address+4, next)
+	}
+	if !implementedInstructions[instruction] {
+		return fmt.Sprintf("*rt.PC = 0x%03X\nif !rt.Unknown(0x%04X) {\nreturn\n}\ncontinue\n", next, instruction)
+	}
+	switch instruction >> 12 {
+	case 0x0:
+		if instruction == 0x00E0 {
+			return fmt.Sprintf("rt.Clear()\n*rt.PC = 0x%03X\nscreenUpdated = true\nreturn\n", next)
+		}
+		return fmt.Sprintf("if *rt.SP == 0 {\n*rt.PC = 0x%03X\nrt.StackFault(0x00EE)\nreturn\n}\n*rt.SP--\n*rt.PC = rt.Stack[*rt.SP]\ncontinue\n", next)
+	case 0x1:
+		return fmt.Sprintf("*rt.PC = 0x%03X\ncontinue\n", target)
+	case 0x2:
+		return fmt.Sprintf("if *rt.SP == len(rt.Stack) {\n*rt.PC = 0x%03X\nrt.StackFault(0x%04X)\nreturn\n}\nrt.Stack[*rt.SP] = 0x%03X\n*rt.SP++\n*rt.PC = 0x%03X\ncontinue\n", next, instruction, next, target)
+	case 0x3:
+		return skip(fmt.Sprintf("rt.V[0x%X] == 0x%02X", vx, value))
+	case 0x4:
+		return skip(fmt.Sprintf("rt.V[0x%X] != 0x%02X", vx, value))
+	case 0x5:
+		return skip(fmt.Sprintf("rt.V[0x%X] == rt.V[0x%X]", vx, vy))
+	case 0x9:
+		return skip(fmt.Sprintf("rt.V[0x%X] != rt.V[0x%X]", vx, vy))
+	case 0xB:
+		// The quirk decides which register is added, and the targets weren't recompiled
+		return fmt.Sprintf("*rt.PC = 0x%03X\nrt.Exec(0x%04X)\ncontinue\n", next, instruction)
+	case 0xD:
+		height := instruction & 0xF
+		return fmt.Sprintf("*rt.PC = 0x%03X\nif !rt.Access(0x%04X, %d) {\nreturn\n}\nrt.Draw(rt.V[0x%X], rt.V[0x%X], %d)\nscreenUpdated = true\nreturn\n", next, instruction, height, vx, vy, height)
+	case 0xE:
+		if value == 0x9E {
+			return skip(fmt.Sprintf("rt.Keys[rt.V[0x%X]&0xF]", vx))
+		}
+		return skip(fmt.Sprintf("!rt.Keys[rt.V[0x%X]&0xF]", vx))
+	case 0xF:
+		switch value {
+		case 0x0A:
+			// Carries on from the same instruction until a key is released
+			return fmt.Sprintf("*rt.PC = 0x%03X\nrt.WaitForKey(0x%04X)\ncontinue\n", next, instruction)
+		case 0x33:
+			return fmt.Sprintf("*rt.PC = 0x%03X\nif !rt.Access(0x%04X, 3) {\nreturn\n}\nrt.Store(rt.V[0x%X]/100, rt.V[0x%X]/10%%10, rt.V[0x%X]%%10)\ncontinue\n", next, instruction, vx, vx, vx)
+		case 0x55:
+			registers := make([]string, vx+1)
+			for r := range registers {
+				registers[r] = fmt.Sprintf("rt.V[0x%X]", r)
+			}
+			return fmt.Sprintf("*rt.PC = 0x%03X\nif !rt.Access(0x%04X, %d) {\nreturn\n}\nrt.Store(%s)\nif rt.Quirks.MemoryIncrementsIndex {\n*rt.I += %d\n}\ncontinue\n", next, instruction, vx+1, strings.Join(registers, ", "), vx+1)
+		}
+	}
+	panic(fmt.Sprintf("no translation for %04X", instruction))
+}
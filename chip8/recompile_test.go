@@ -0,0 +1,241 @@
+package chip8
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecompile(t *testing.T) {
+	source, err := Recompile(memoryLoopROM, RecompileOptions{Package: "game", Source: "memory.ch8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "game.go", source, 0); err != nil {
+		t.Fatalf("Generated code doesn't parse: %v", err)
+	}
+
+	code := string(source)
+	for _, expected := range []string{
+		"package game",
+		"l206: // 7001 ADD V0, 0x01",
+		"rt.V[0x0] += 0x01",
+		"rt.Store(rt.V[0x0], rt.V[0x1], rt.V[0x2])",
+		"rt.Stack[*rt.SP] = 0x20E",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("Generated code is missing %q", expected)
+		}
+	}
+	if strings.Contains(code, "rt.Exec(") {
+		t.Error("Generated code passes instructions other than BNNN to the interpreter")
+	}
+	if strings.Contains(code, "func main()") {
+		t.Error("Library package has a main function")
+	}
+}
+
+// The instructions the other ROMs don't use, apart from key waits and computed jumps
+var miscLoopROM = []byte{
+	0x60, 0xF0, // 200: LD V0, 0xF0
+	0x80, 0x15, // 202: SUB V0, V1
+	0x82, 0x17, // 204: SUBN V2, V1
+	0x83, 0x0E, // 206: SHL V3, V0
+	0x84, 0x11, // 208: OR V4, V1
+	0x85, 0x02, // 20A: AND V5, V0
+	0x87, 0x34, // 20C: ADD V7, V3
+	0xF0, 0x15, // 20E: LD DT, V0
+	0xF6, 0x07, // 210: LD V6, DT
+	0xF1, 0x18, // 212: LD ST, V1
+	0xF1, 0x1E, // 214: ADD I, V1
+	0x71, 0x07, // 216: ADD V1, 0x07
+	0xE1, 0x9E, // 218: SKP V1
+	0x00, 0xE0, // 21A: CLS
+	0xE1, 0xA1, // 21C: SKNP V1
+	0x00, 0xE0, // 21E: CLS
+	0x12, 0x02, // 220: JP 0x202
+}
+
+// Checks the recompiled code against the interpreter after every instruction
+const recompiledHarness = `package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+	"github.com/rdhillon1016/chip8-emulator/chip8/%[1]s/game"
+)
+
+var rom = %#[2]v
+
+func main() {
+	if _, err := game.NewChip(chip8.WithPlatform(chip8.ETI660)); err == nil {
+		fmt.Println("NewChip accepted a different memory layout")
+		os.Exit(1)
+	}
+	recompiled, err := game.NewChip(chip8.WithRandomSource(rand.NewSource(1)))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	interpreted, err := chip8.NewChip(rom, chip8.WithRandomSource(rand.NewSource(1)))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for i := 0; i < 5000; i++ {
+		pc := interpreted.ProgramCounter()
+		if interpreted.Step() != recompiled.Step() || interpreted.StateChecksum() != recompiled.StateChecksum() {
+			fmt.Printf("Cycle %%d at %%03X: state differs\n", i, pc)
+			os.Exit(1)
+		}
+	}
+
+	// Whole frames run the recompiled code in batches, which have to stop in the same places
+	for _, platform := range []chip8.Platform{chip8.DefaultPlatform, chip8.COSMACVIP} {
+		recompiled, err := game.NewChip(chip8.WithPlatform(platform), chip8.WithRandomSource(rand.NewSource(1)), chip8.WithExecutionRate(1000))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		interpreted, err := chip8.NewChip(rom, chip8.WithPlatform(platform), chip8.WithRandomSource(rand.NewSource(1)), chip8.WithExecutionRate(1000))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for i := 0; i < 300; i++ {
+			expected, actual := interpreted.RunFrame(), recompiled.RunFrame()
+			if expected.Instructions != actual.Instructions || expected.ScreenUpdated != actual.ScreenUpdated || interpreted.StateChecksum() != recompiled.StateChecksum() {
+				fmt.Printf("%%s frame %%d: state differs\n", platform.Name, i)
+				os.Exit(1)
+			}
+		}
+	}
+}
+`
+
+func TestRecompiledROMsMatchInterpreter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Builds the generated code with the go command")
+	}
+	goCommand, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	roms := map[string][]byte{
+		"alu":            aluLoopROM,
+		"sprite":         spriteLoopROM,
+		"memory":         memoryLoopROM,
+		"self-modifying": selfModifyingROM,
+		"misc":           miscLoopROM,
+	}
+	for name, rom := range roms {
+		t.Run(name, func(t *testing.T) {
+			source, err := Recompile(rom, RecompileOptions{Package: "game", Source: name + ".ch8"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Inside the module so the generated code can import it, but hidden from ./...
+			dir, err := os.MkdirTemp(".", "_recompiled")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			if err := os.Mkdir(filepath.Join(dir, "game"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "game", "game.go"), source, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			harness := fmt.Sprintf(recompiledHarness, filepath.Base(dir), rom)
+			if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(harness), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			output, err := exec.Command(goCommand, "run", "./"+filepath.Base(dir)).CombinedOutput()
+			if err != nil {
+				t.Errorf("%v: %s", err, output)
+			}
+		})
+	}
+}
+
+// Behaves like the code Recompile generates for {0x60, 0x05, 0x70, 0x01, 0x12, 0x02}
+func recompiledCounter(rt *Runtime, cycles int) (executed int, screenUpdated bool) {
+	for executed < cycles {
+		switch *rt.PC {
+		case 0x200:
+			if rt.Modified(0x200, 0x206) {
+				return
+			}
+			goto l200
+		case 0x202:
+			if rt.Modified(0x202, 0x206) {
+				return
+			}
+			goto l202
+		case 0x204:
+			if rt.Modified(0x204, 0x206) {
+				return
+			}
+			goto l204
+		default:
+			return
+		}
+
+	l200:
+		executed++
+		rt.V[0x0] = 0x05
+		if executed == cycles {
+			*rt.PC = 0x202
+			return
+		}
+	l202:
+		executed++
+		rt.V[0x0] += 0x01
+		if executed == cycles {
+			*rt.PC = 0x204
+			return
+		}
+	l204:
+		executed++
+		*rt.PC = 0x202
+		continue
+	}
+	return
+}
+
+func TestRecompiledCode(t *testing.T) {
+	chip := newTestChip([]byte{0x60, 0x05, 0x70, 0x01, 0x12, 0x02})
+	chip.UseRecompiledCode(recompiledCounter)
+	for i := 0; i < 5; i++ {
+		chip.Step()
+	}
+
+	if chip.generalRegisters[0] != 0x07 || chip.programCounter != 0x202 {
+		t.Error("Recompiled code did not run correctly")
+	}
+}
+
+func TestRecompiledCodeFallsBackWhenModified(t *testing.T) {
+	chip := newTestChip([]byte{0x60, 0x05, 0x70, 0x01, 0x12, 0x02})
+	chip.UseRecompiledCode(recompiledCounter)
+	chip.Step()
+
+	// Turns ADD V0, 0x01 into ADD V0, 0x10
+	chip.WriteMemory(0x203, 0x10)
+	chip.Step()
+
+	if chip.generalRegisters[0] != 0x15 {
+		t.Error("Overwritten code was not interpreted")
+	}
+}
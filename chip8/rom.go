@@ -0,0 +1,110 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrEmptyROM = errors.New("ROM is empty")
+
+// ROMTooLargeError is returned for a ROM that doesn't fit between the program start address and the end of memory or reserved memory
+type ROMTooLargeError struct {
+	Size         int
+	Available    int
+	ProgramStart uint16
+	Platform     string
+}
+
+func (e *ROMTooLargeError) Error() string {
+	return fmt.Sprintf("ROM is %d bytes, but only %d bytes of memory are available from 0x%03X on %s", e.Size, e.Available, e.ProgramStart, e.Platform)
+}
+
+// ROMInfo describes a ROM that passed validation
+type ROMInfo struct {
+	Size int
+	// The instruction set the ROM appears to be written for: CHIP-8,
+	// SUPER-CHIP or XO-CHIP
+	InstructionSet string
+	// Problems that don't stop the ROM from loading but may stop it from running correctly
+	Warnings []string
+}
+
+/*
+ValidateROM checks that a ROM can be loaded on the platform, and looks
+through the instructions that can be reached from its entry point for any
+that belong to the SUPER-CHIP or XO-CHIP extensions. Only extension
+instructions that the interpreter doesn't implement are warned about.
+*/
+func ValidateROM(rom []byte, platform Platform) (ROMInfo, error) {
+	info := ROMInfo{Size: len(rom), InstructionSet: "CHIP-8"}
+	if len(rom) == 0 {
+		return info, ErrEmptyROM
+	}
+	if available := platform.programSpace(); len(rom) > available {
+		return info, &ROMTooLargeError{Size: len(rom), Available: available, ProgramStart: platform.ProgramStart, Platform: platform.Name}
+	}
+
+	if len(rom)%2 != 0 {
+		info.Warnings = append(info.Warnings, "ROM has an odd number of bytes, so its last byte isn't a whole instruction")
+	}
+	memory := make([]byte, platform.MemorySize)
+	copy(memory[platform.ProgramStart:], rom)
+	// The lowest unsupported instruction of each extension, so the warning doesn't depend on map order
+	unsupported := map[string]uint16{}
+	for address := range AnalyzeControlFlow(memory, platform.ProgramStart).Instructions {
+		instruction := uint16(memory[address])<<8 | uint16(memory[address+1])
+		set := ""
+		if isXOCHIPInstruction(instruction) {
+			set = "XO-CHIP"
+			info.InstructionSet = set
+		} else if isSCHIPInstruction(instruction) {
+			set = "SUPER-CHIP"
+			if info.InstructionSet == "CHIP-8" {
+				info.InstructionSet = set
+			}
+		}
+		if set == "" || implementedInstructions[instruction] {
+			continue
+		}
+		if first, ok := unsupported[set]; !ok || instruction < first {
+			unsupported[set] = instruction
+		}
+	}
+	for _, set := range []string{"SUPER-CHIP", "XO-CHIP"} {
+		if instruction, ok := unsupported[set]; ok {
+			info.Warnings = append(info.Warnings, fmt.Sprintf("ROM uses %s instructions that aren't supported, such as %04X (%s)", set, instruction, Disassemble(instruction)))
+		}
+	}
+	return info, nil
+}
+
+func isSCHIPInstruction(instruction uint16) bool {
+	switch {
+	case instruction&0xFFF0 == 0x00C0:
+		return true
+	case instruction >= 0x00FB && instruction <= 0x00FF:
+		return true
+	case instruction>>12 == 0xD && instruction&0xF == 0x0:
+		return true
+	case instruction>>12 == 0xF:
+		switch instruction & 0xFF {
+		case 0x30, 0x75, 0x85:
+			return true
+		}
+	}
+	return false
+}
+
+func isXOCHIPInstruction(instruction uint16) bool {
+	switch {
+	case instruction&0xFFF0 == 0x00D0:
+		return true
+	case instruction>>12 == 0x5:
+		return instruction&0xF == 0x2 || instruction&0xF == 0x3
+	case instruction == 0xF000 || instruction == 0xF002:
+		return true
+	case instruction>>12 == 0xF:
+		return instruction&0xFF == 0x01 || instruction&0xFF == 0x3A
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package chip8
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEmptyROM(t *testing.T) {
+	if _, err := NewChip(nil); !errors.Is(err, ErrEmptyROM) {
+		t.Errorf("Empty ROM returned %v", err)
+	}
+}
+
+func TestROMTooLarge(t *testing.T) {
+	_, err := NewChip(make([]byte, 3585))
+	var tooLarge *ROMTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Size != 3585 || tooLarge.Available != 3584 {
+		t.Errorf("Oversized ROM returned %v", err)
+	}
+
+	if _, err := NewChip(make([]byte, 3585), WithMemorySize(0x1000+2)); err != nil {
+		t.Errorf("ROM that fits in larger memory returned %v", err)
+	}
+}
+
+func TestOddLengthROM(t *testing.T) {
+	chip := newTestChip([]byte{0x12, 0x00, 0x00})
+	if warnings := chip.ROMInfo().Warnings; len(warnings) != 1 {
+		t.Errorf("Odd length ROM gave warnings %v", warnings)
+	}
+}
+
+func TestDetectInstructionSet(t *testing.T) {
+	roms := map[string][]byte{
+		"CHIP-8":     {0x60, 0x01, 0x12, 0x00},
+		"SUPER-CHIP": {0x00, 0xFF, 0x12, 0x02},
+		"XO-CHIP":    {0x00, 0xFF, 0x50, 0x12, 0x12, 0x04},
+	}
+	for expected, rom := range roms {
+		info, err := ValidateROM(rom, COSMACVIP)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.InstructionSet != expected {
+			t.Errorf("Detected %s instead of %s", info.InstructionSet, expected)
+		}
+	}
+
+	// Only instructions the interpreter lacks are warned about
+	info, _ := ValidateROM([]byte{0xF0, 0x30, 0x00, 0xFF, 0x00, 0xFE, 0x12, 0x06}, COSMACVIP)
+	if info.InstructionSet != "SUPER-CHIP" || len(info.Warnings) != 1 || !strings.Contains(info.Warnings[0], "00FE") {
+		t.Errorf("SUPER-CHIP ROM gave warnings %v", info.Warnings)
+	}
+	info, _ = ValidateROM([]byte{0xF0, 0x30, 0x12, 0x02}, COSMACVIP)
+	if info.InstructionSet != "SUPER-CHIP" || len(info.Warnings) != 0 {
+		t.Errorf("ROM with only FX30 gave warnings %v", info.Warnings)
+	}
+
+	// Data that is never executed doesn't count
+	info, _ = ValidateROM([]byte{0x12, 0x00, 0x00, 0xFF}, COSMACVIP)
+	if info.InstructionSet != "CHIP-8" || len(info.Warnings) != 0 {
+		t.Errorf("Unreachable data was detected as %s", info.InstructionSet)
+	}
+}
+
+func TestLoadInvalidROM(t *testing.T) {
+	chip := newTestChip([]byte{0x60, 0x01})
+	if err := chip.LoadROM(nil); err == nil {
+		t.Error("Empty ROM was loaded")
+	}
+	chip.Step()
+	if chip.generalRegisters[0] != 0x01 {
+		t.Error("Failed load changed the chip")
+	}
+}
@@ -0,0 +1,401 @@
+package chip8
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Frame is a snapshot of the display and of the runner's state, taken at the
+end of a 60Hz frame. Frames are never modified after they are published,
+so they can be read from any goroutine.
+*/
+type Frame struct {
+	// Indexed [x][y] like Chip.Pixels. Shared between consecutive frames
+	// when the display hasn't changed, so it must not be modified.
+	Pixels [][]bool
+	// Incremented whenever the display changes, so that frames that are
+	// identical to the last one drawn can be skipped
+	DisplayVersion uint64
+	// Total number of instructions executed, including before any resets
+	Instructions uint64
+	// Total number of 60Hz frames run, including before any resets
+	Frames  uint64
+	Paused  bool
+	SoundOn bool
+	// Address of the most recent breakpoint that stopped execution, and how
+	// many times execution has stopped at a breakpoint
+	Breakpoint      uint16
+	BreakpointCount uint64
+	// Set if the chip has faulted, which also pauses the runner
+	Fault *Fault
+	// Number of frames recorded or played back from a movie
+	MovieFrame int
+	// Set once a movie has been played to the end, which also pauses the runner
+	MovieFinished bool
+	// The first time the state during playback didn't match the movie
+	Desync *Desync
+}
+
+type runnerCommandKind int
+
+const (
+	commandPause runnerCommandKind = iota
+	commandResume
+	commandStepFrame
+	commandReset
+	commandSetSpeed
+	commandSetBreakpoints
+	commandInspect
+	commandRecord
+	commandPlay
+)
+
+type runnerCommand struct {
+	kind        runnerCommandKind
+	speed       float64
+	breakpoints map[uint16]bool
+	inspect     func(*Chip)
+	movie       *Movie
+	done        chan struct{}
+}
+
+/*
+Runner owns a Chip and runs it on its own goroutine, so that emulation
+doesn't share a thread with rendering. Everything else talks to it over
+channels: keypad state and commands go in, and finished frames come out.
+
+The chip draws into its own framebuffer, which acts as the back buffer.
+At the end of every frame the runner copies it into a new front buffer,
+if it changed, and publishes it atomically, so readers never see a
+partially drawn display.
+*/
+type Runner struct {
+	executionRateHz int
+
+	input    chan [16]bool
+	commands chan runnerCommand
+	// Closed when Run returns, so that commands don't block forever
+	stopped chan struct{}
+	frame   atomic.Pointer[Frame]
+
+	// Everything below is owned by the goroutine running Run
+	chip        *Chip
+	speed       float64
+	paused      bool
+	breakpoints map[uint16]bool
+	// Set when execution stopped at a breakpoint, so that resuming doesn't
+	// immediately stop at the same one again
+	resumingFromBreakpoint bool
+	// Fraction of an instruction carried over between frames when the
+	// execution rate isn't a multiple of 60Hz
+	cycleBudget     float64
+	displayChanged  bool
+	displayVersion  uint64
+	instructions    uint64
+	frames          uint64
+	breakpoint      uint16
+	breakpointCount uint64
+	// Movie that every frame is added to, and whether the chip was reset
+	// since the last frame
+	recording    *Movie
+	resetPending bool
+	// Movie whose frames are played back instead of running the chip with
+	// the runner's own timing and keys
+	playback      *Movie
+	playbackFrame int
+	desync        *Desync
+}
+
+// Creates a runner that takes ownership of the chip. The chip must not be used elsewhere afterwards.
+func NewRunner(chip *Chip, executionRateHz int) *Runner {
+	r := &Runner{
+		executionRateHz: executionRateHz,
+		input:           make(chan [16]bool, 1),
+		commands:        make(chan runnerCommand),
+		stopped:         make(chan struct{}),
+		chip:            chip,
+		speed:           1,
+		displayChanged:  true,
+	}
+	r.publish()
+	return r
+}
+
+/*
+Run executes the chip at the configured rate until the context is
+cancelled, and returns the context's error. It must only be called once.
+*/
+func (r *Runner) Run(ctx context.Context) error {
+	defer close(r.stopped)
+	ticker := time.NewTicker(time.Second / timerRateHz)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case keys := <-r.input:
+			if r.playback == nil {
+				r.chip.SetKeys(keys)
+			}
+		case command := <-r.commands:
+			r.handle(command)
+			r.publish()
+			close(command.done)
+		case <-ticker.C:
+			if !r.paused {
+				r.runFrame()
+			}
+			r.publish()
+		}
+	}
+}
+
+// Returns the most recently published frame
+func (r *Runner) Frame() *Frame {
+	return r.frame.Load()
+}
+
+// Replaces the keypad state. Only the latest state is kept if the runner hasn't picked up the previous one yet.
+func (r *Runner) SetKeys(keys [16]bool) {
+	select {
+	case <-r.input:
+	default:
+	}
+	select {
+	case r.input <- keys:
+	default:
+	}
+}
+
+func (r *Runner) Pause() {
+	r.send(runnerCommand{kind: commandPause})
+}
+
+func (r *Runner) Resume() {
+	r.send(runnerCommand{kind: commandResume})
+}
+
+// Runs a single frame while paused
+func (r *Runner) StepFrame() {
+	r.send(runnerCommand{kind: commandStepFrame})
+}
+
+// Restarts the ROM
+func (r *Runner) Reset() {
+	r.send(runnerCommand{kind: commandReset})
+}
+
+// Sets the execution rate as a multiple of the configured rate
+func (r *Runner) SetSpeed(speed float64) {
+	r.send(runnerCommand{kind: commandSetSpeed, speed: speed})
+}
+
+// Pauses execution before any instruction at one of the addresses runs
+func (r *Runner) SetBreakpoints(breakpoints map[uint16]bool) {
+	copied := make(map[uint16]bool, len(breakpoints))
+	for address, set := range breakpoints {
+		if set {
+			copied[address] = true
+		}
+	}
+	r.send(runnerCommand{kind: commandSetBreakpoints, breakpoints: copied})
+}
+
+/*
+Inspect calls fn with the chip on the runner's goroutine, between
+instructions, and waits for it to return. fn may read or modify the chip
+but must not keep a reference to it.
+*/
+func (r *Runner) Inspect(fn func(*Chip)) {
+	r.send(runnerCommand{kind: commandInspect, inspect: fn})
+}
+
+/*
+Record adds every frame the runner runs from now on to the movie, which
+must have been created along with the runner's chip by RecordMovie. The
+movie must not be used elsewhere until Run has returned.
+*/
+func (r *Runner) Record(movie *Movie) {
+	r.send(runnerCommand{kind: commandRecord, movie: movie})
+}
+
+/*
+Play plays the movie back on the runner's chip, which must have been
+created by the movie's NewChip, in place of the keys passed to SetKeys and
+the configured speed. The runner pauses at the end of the movie, and
+resetting it stops the playback.
+*/
+func (r *Runner) Play(movie *Movie) {
+	r.send(runnerCommand{kind: commandPlay, movie: movie})
+}
+
+// Sends a command and waits until it has been handled and the resulting frame published
+func (r *Runner) send(command runnerCommand) {
+	command.done = make(chan struct{})
+	select {
+	case r.commands <- command:
+		<-command.done
+	case <-r.stopped:
+	}
+}
+
+func (r *Runner) handle(command runnerCommand) {
+	switch command.kind {
+	case commandPause:
+		r.paused = true
+	case commandResume:
+		r.paused = false
+	case commandStepFrame:
+		if r.paused {
+			r.runFrame()
+		}
+	case commandReset:
+		r.chip.Reset()
+		r.resumingFromBreakpoint = false
+		r.displayChanged = true
+		r.resetPending = true
+		r.playback = nil
+	case commandSetSpeed:
+		r.speed = command.speed
+	case commandSetBreakpoints:
+		r.breakpoints = command.breakpoints
+	case commandInspect:
+		command.inspect(r.chip)
+		// fn may have drawn to the display as well
+		if !pixelsEqual(r.chip.Pixels, r.frame.Load().Pixels) {
+			r.displayChanged = true
+		}
+	case commandRecord:
+		r.recording = command.movie
+		r.resetPending = false
+	case commandPlay:
+		r.playback = command.movie
+		r.playbackFrame = 0
+		r.desync = nil
+	}
+}
+
+// Executes one 60Hz frame's worth of instructions, stopping early at a breakpoint or where the chip waits for the display
+func (r *Runner) runFrame() {
+	if r.playback != nil {
+		r.playMovieFrame()
+		return
+	}
+	r.frames++
+	keys := r.chip.Keys()
+	executed := 0
+	r.cycleBudget += float64(r.executionRateHz) * r.speed / timerRateHz
+	cycles := int(r.cycleBudget)
+	if cycles < 1 {
+		cycles = 1
+	}
+	r.cycleBudget -= float64(cycles)
+	if r.cycleBudget < 0 {
+		r.cycleBudget = 0
+	}
+
+	for executed < cycles {
+		if pc := r.chip.programCounter; r.breakpoints[pc] && !r.resumingFromBreakpoint {
+			r.paused = true
+			r.resumingFromBreakpoint = true
+			r.breakpoint = pc
+			r.breakpointCount++
+			break
+		}
+		r.resumingFromBreakpoint = false
+		// Breakpoints have to be checked before every instruction
+		batch := cycles - executed
+		if len(r.breakpoints) > 0 {
+			batch = 1
+		}
+		n, screenUpdated, frameOver := r.chip.frameStep(batch)
+		if screenUpdated {
+			r.displayChanged = true
+		}
+		r.instructions += uint64(n)
+		executed += n
+		if r.chip.Fault() != nil {
+			r.paused = true
+			break
+		}
+		if frameOver {
+			break
+		}
+	}
+	r.chip.DecrementTimers()
+	if r.recording != nil {
+		r.recording.addFrame(keys, executed, r.resetPending, r.chip)
+		r.resetPending = false
+	}
+}
+
+func (r *Runner) playMovieFrame() {
+	if r.playbackFrame >= len(r.playback.Frames) {
+		r.paused = true
+		return
+	}
+	r.frames++
+	screenUpdated, desync := r.playback.playFrame(r.chip, r.playbackFrame)
+	if screenUpdated {
+		r.displayChanged = true
+	}
+	if desync != nil && r.desync == nil {
+		r.desync = desync
+	}
+	r.instructions += uint64(r.playback.Frames[r.playbackFrame].Cycles)
+	r.playbackFrame++
+	if r.playbackFrame == len(r.playback.Frames) {
+		r.paused = true
+	}
+}
+
+func (r *Runner) publish() {
+	frame := &Frame{
+		Instructions:    r.instructions,
+		Frames:          r.frames,
+		Paused:          r.paused,
+		SoundOn:         r.chip.SoundTimerValue > 0,
+		Breakpoint:      r.breakpoint,
+		BreakpointCount: r.breakpointCount,
+		Fault:           r.chip.Fault(),
+		Desync:          r.desync,
+	}
+	if r.playback != nil {
+		frame.MovieFrame = r.playbackFrame
+		frame.MovieFinished = r.playbackFrame == len(r.playback.Frames)
+	} else if r.recording != nil {
+		frame.MovieFrame = len(r.recording.Frames)
+	}
+	if previous := r.frame.Load(); previous != nil && !r.displayChanged {
+		frame.Pixels = previous.Pixels
+	} else {
+		frame.Pixels = make([][]bool, len(r.chip.Pixels))
+		for x, column := range r.chip.Pixels {
+			frame.Pixels[x] = append([]bool(nil), column...)
+		}
+		r.displayVersion++
+		r.displayChanged = false
+	}
+	frame.DisplayVersion = r.displayVersion
+	r.frame.Store(frame)
+}
+
+func pixelsEqual(a, b [][]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for x := range a {
+		if len(a[x]) != len(b[x]) {
+			return false
+		}
+		for y := range a[x] {
+			if a[x][y] != b[x][y] {
+				return false
+			}
+		}
+	}
+	return true
+}
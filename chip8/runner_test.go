@@ -0,0 +1,192 @@
+package chip8
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Starts a runner and returns a function that stops it and waits for Run to return
+func startRunner(t *testing.T, rom []byte, executionRateHz int) (*Runner, func()) {
+	t.Helper()
+	return startRunnerWithChip(t, newSeededChip(rom), executionRateHz)
+}
+
+func startRunnerWithChip(t *testing.T, chip *Chip, executionRateHz int) (*Runner, func()) {
+	t.Helper()
+	runner := NewRunner(chip, executionRateHz)
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() {
+		result <- runner.Run(ctx)
+	}()
+	return runner, func() {
+		cancel()
+		if err := <-result; !errors.Is(err, context.Canceled) {
+			t.Errorf("Run returned %v", err)
+		}
+	}
+}
+
+// Polls the runner's frames until condition holds or a second passes
+func waitForFrame(t *testing.T, runner *Runner, condition func(*Frame) bool) *Frame {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if frame := runner.Frame(); condition(frame) {
+			return frame
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for a frame")
+	return nil
+}
+
+func TestRunnerPublishesFrames(t *testing.T) {
+	runner, stop := startRunner(t, spriteLoopROM, 600)
+	defer stop()
+
+	initial := runner.Frame()
+	if initial == nil || len(initial.Pixels) != pixelsWidth || len(initial.Pixels[0]) != pixelsHeight {
+		t.Fatal("No initial frame was published")
+	}
+	frame := waitForFrame(t, runner, func(f *Frame) bool {
+		return f.DisplayVersion > initial.DisplayVersion
+	})
+	if frame.Instructions == 0 {
+		t.Error("Frame doesn't count executed instructions")
+	}
+	for _, column := range initial.Pixels {
+		for _, pixel := range column {
+			if pixel {
+				t.Fatal("Published frame was modified")
+			}
+		}
+	}
+}
+
+func TestRunnerFramesAreImmutable(t *testing.T) {
+	runner, stop := startRunner(t, spriteLoopROM, 100000)
+	defer stop()
+
+	// Holds on to frames while the runner keeps drawing, which the race
+	// detector would also flag if frames were written after publication
+	deadline := time.Now().Add(100 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		frame := runner.Frame()
+		copied := make([][]bool, len(frame.Pixels))
+		for x, column := range frame.Pixels {
+			copied[x] = append([]bool(nil), column...)
+		}
+		time.Sleep(5 * time.Millisecond)
+		if !pixelsEqual(frame.Pixels, copied) {
+			t.Fatal("Published frame was modified")
+		}
+	}
+}
+
+func TestRunnerStepFrame(t *testing.T) {
+	runner, stop := startRunner(t, aluLoopROM, 600)
+	defer stop()
+
+	runner.Pause()
+	before := runner.Frame()
+	if !before.Paused {
+		t.Fatal("Runner did not pause")
+	}
+	runner.StepFrame()
+	after := runner.Frame()
+	if after.Instructions-before.Instructions != 10 {
+		t.Errorf("Frame ran %d instructions instead of 10", after.Instructions-before.Instructions)
+	}
+	if after.Frames != before.Frames+1 {
+		t.Errorf("Stepping counted %d frames", after.Frames-before.Frames)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if runner.Frame().Instructions != after.Instructions {
+		t.Error("Paused runner kept executing")
+	}
+}
+
+func TestRunnerDisplayWait(t *testing.T) {
+	// Draws on every other instruction
+	chip := newSeededChip([]byte{0xD0, 0x11, 0x70, 0x01, 0x12, 0x00}, WithPlatform(COSMACVIP))
+	runner := NewRunner(chip, 600)
+	runner.runFrame()
+	if runner.instructions != 1 {
+		t.Errorf("First frame ran %d instructions instead of stopping after drawing", runner.instructions)
+	}
+	runner.runFrame()
+	if runner.instructions != 4 {
+		t.Errorf("Second frame ran %d instructions instead of stopping after drawing", runner.instructions-1)
+	}
+}
+
+func TestRunnerBreakpoint(t *testing.T) {
+	runner, stop := startRunner(t, aluLoopROM, 600)
+	defer stop()
+
+	runner.SetBreakpoints(map[uint16]bool{0x20E: true})
+	frame := waitForFrame(t, runner, func(f *Frame) bool {
+		return f.BreakpointCount == 1
+	})
+	if !frame.Paused || frame.Breakpoint != 0x20E {
+		t.Errorf("Stopped at 0x%03X, paused %t", frame.Breakpoint, frame.Paused)
+	}
+	runner.Inspect(func(chip *Chip) {
+		if chip.ProgramCounter() != 0x20E {
+			t.Errorf("Program counter is 0x%03X", chip.ProgramCounter())
+		}
+	})
+
+	// Resuming runs the instruction at the breakpoint before stopping there again
+	runner.Resume()
+	waitForFrame(t, runner, func(f *Frame) bool {
+		return f.BreakpointCount == 2
+	})
+}
+
+func TestRunnerKeysAndReset(t *testing.T) {
+	runner, stop := startRunner(t, aluLoopROM, 600)
+	defer stop()
+
+	keys := [16]bool{0x5: true}
+	runner.SetKeys(keys)
+	deadline := time.Now().Add(time.Second)
+	received := false
+	for !received && time.Now().Before(deadline) {
+		runner.Inspect(func(chip *Chip) {
+			received = chip.Keys() == keys
+		})
+	}
+	if !received {
+		t.Error("Keys were not passed to the chip")
+	}
+
+	runner.Pause()
+	runner.Reset()
+	runner.Inspect(func(chip *Chip) {
+		if chip.ProgramCounter() != 0x200 || chip.Registers() != [16]byte{} {
+			t.Error("Reset did not restart the ROM")
+		}
+	})
+}
+
+func TestRunnerCommandsAfterStop(t *testing.T) {
+	runner, stop := startRunner(t, aluLoopROM, 600)
+	stop()
+
+	done := make(chan struct{})
+	go func() {
+		runner.Pause()
+		runner.Inspect(func(*Chip) {})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Commands blocked after the runner stopped")
+	}
+}
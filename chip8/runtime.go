@@ -0,0 +1,175 @@
+package chip8
+
+/*
+RecompiledCode is the entry point of a program produced by Recompile. It
+executes up to cycles instructions from the program counter as native Go
+code, stopping early after an instruction that updates the screen, and
+returns how many it executed. If the program counter isn't at an
+instruction that was recompiled, or the code from there to the end of its
+block has been overwritten, it returns 0 and the chip interprets the
+instruction instead.
+*/
+type RecompiledCode func(rt *Runtime, cycles int) (executed int, screenUpdated bool)
+
+/*
+Runtime gives recompiled code direct access to the chip's registers, along
+with the parts of the interpreter that have to respect the chip's options,
+such as memory accesses and faults. It also keeps track of which bytes of
+memory have been written to, since any recompiled code at those addresses
+no longer matches memory and has to be interpreted from then on.
+
+The program counter is only kept up to date where it matters, so recompiled
+code sets it before anything that can fault or reads it.
+*/
+type Runtime struct {
+	V      *[16]byte
+	I      *uint16
+	PC     *uint16
+	Stack  *[16]uint16
+	SP     *int
+	DT     *byte
+	Keys   *[16]bool
+	Quirks *Quirks
+
+	chip     *Chip
+	code     RecompiledCode
+	modified []bool
+	// Whether anything in modified is set, so that most checks are a single comparison
+	anyModified bool
+}
+
+// Executes the instructions at the program counter with recompiled code wherever possible
+func (chip *Chip) UseRecompiledCode(code RecompiledCode) {
+	rt := &Runtime{
+		V:        &chip.generalRegisters,
+		I:        &chip.indexRegister,
+		PC:       &chip.programCounter,
+		Stack:    &chip.stack,
+		SP:       &chip.stackPointer,
+		DT:       &chip.delayTimerValue,
+		Keys:     &chip.keys,
+		Quirks:   &chip.quirks,
+		chip:     chip,
+		code:     code,
+		modified: make([]bool, len(chip.memory)),
+	}
+	chip.memoryWriteHooks = append(chip.memoryWriteHooks, rt.memoryWritten)
+	chip.runtime = rt
+}
+
+func (rt *Runtime) memoryWritten(address uint16, length int) {
+	for i := 0; i < length; i++ {
+		rt.modified[(int(address)+i)%len(rt.modified)] = true
+	}
+	rt.anyModified = true
+}
+
+// Executes up to cycles instructions, returning 0 if the one at the program counter needs to be interpreted
+func (rt *Runtime) run(cycles int) (int, bool) {
+	// Recompiled code doesn't know about custom handlers, which could be for any instruction in a block
+	if rt.chip.opcodePatterns != nil {
+		return 0, false
+	}
+	return rt.code(rt, cycles)
+}
+
+// Reports whether any of the code from start up to end has been overwritten since it was recompiled
+func (rt *Runtime) Modified(start, end uint16) bool {
+	if !rt.anyModified {
+		return false
+	}
+	for address := int(start); address < int(end) && address < len(rt.modified); address++ {
+		if rt.modified[address] {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+StackFault raises the fault for a call with all of the stack in use, or a
+return with none of it in use, whichever the instruction is. Recompiled
+code handles the stack itself otherwise.
+*/
+func (rt *Runtime) StackFault(instruction uint16) {
+	rt.chip.executeInstruction(instruction)
+}
+
+/*
+Access reports whether the instruction may access length bytes of memory
+from I, raising a fault if the memory access policy says so. Load and Store
+wrap or clamp the accesses that it lets through.
+*/
+func (rt *Runtime) Access(instruction uint16, length int) bool {
+	return rt.chip.canAccessMemory(instruction, length)
+}
+
+// Returns the byte offset bytes past I
+func (rt *Runtime) Load(offset int) byte {
+	return rt.chip.memory[rt.chip.indexedAddress(offset)]
+}
+
+// Writes the values to memory from I, without changing I
+func (rt *Runtime) Store(values ...byte) {
+	rt.chip.indexedMemoryWritten(len(values))
+	for i, value := range values {
+		rt.chip.memory[rt.chip.indexedAddress(i)] = value
+	}
+}
+
+// Clears the screen
+func (rt *Runtime) Clear() {
+	op00E0(rt.chip, 0x00E0)
+}
+
+// Draws the height bytes at I as a sprite at x, y and sets VF if any pixels were erased
+func (rt *Runtime) Draw(x, y byte, height int) {
+	rt.chip.drawSprite(x, y, height)
+}
+
+// Returns a random byte from the chip's random source
+func (rt *Runtime) Random() byte {
+	return byte(rt.chip.rng.Intn(256))
+}
+
+// Sets the sound timer, starting or stopping the buzzer
+func (rt *Runtime) SetSoundTimer(value byte) {
+	rt.chip.SoundTimerValue = value
+	rt.chip.updateSound()
+}
+
+// Returns the address of the small font's glyph for the low nibble of digit
+func (rt *Runtime) Glyph(digit byte) uint16 {
+	return rt.chip.platform.FontAddress + uint16(digit&0xF)*smallGlyphSize
+}
+
+// Points I at a big font glyph like FX30, returning false if the font doesn't have it
+func (rt *Runtime) BigGlyph(instruction uint16) bool {
+	opFX30(rt.chip, instruction)
+	return rt.chip.fault == nil
+}
+
+/*
+WaitForKey behaves like FX0A. The program counter only moves past the
+instruction once a key has been pressed and released, so recompiled code
+has to carry on from wherever it is afterwards.
+*/
+func (rt *Runtime) WaitForKey(instruction uint16) {
+	opFX0A(rt.chip, instruction)
+}
+
+// Handles an instruction that isn't implemented with the chip's policy, returning false if it faulted
+func (rt *Runtime) Unknown(instruction uint16) bool {
+	rt.chip.unknownOpcode(instruction)
+	return rt.chip.fault == nil
+}
+
+/*
+Exec runs a single instruction with the interpreter, after the program
+counter has already been advanced. Recompiled code only uses it for BNNN,
+whose behaviour depends on a quirk and whose targets can't be recompiled
+ahead of time anyway.
+*/
+func (rt *Runtime) Exec(instruction uint16) bool {
+	return rt.chip.executeInstruction(instruction)
+}
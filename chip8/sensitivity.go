@@ -0,0 +1,396 @@
+package chip8
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Number of instructions followed after FX55, FX65 or 8XY1-8XY3 to see what the code does next
+const lookahead = 32
+
+// QuirkFinding is a reachable instruction whose behaviour differs between interpreters
+type QuirkFinding struct {
+	Address     uint16
+	Instruction uint16
+	// JSON name of the quirk that changes its behaviour, or empty if none of them cover it
+	Quirk  string
+	Reason string
+}
+
+/*
+QuirkAnalysis is the result of statically checking which of the behaviours
+that interpreters disagree on a ROM could depend on, along with the
+platform and quirks that the evidence points to.
+*/
+type QuirkAnalysis struct {
+	InstructionSet string
+	// Ordered by address
+	Findings []QuirkFinding
+	// Name of the recommended platform, as accepted by ParsePlatform
+	Platform string
+	Quirks   Quirks
+	// Why the platform and quirks were chosen
+	Reasons []string
+}
+
+// Reports whether the ROM has an instruction that depends on the quirk
+func (analysis *QuirkAnalysis) DependsOn(quirk string) bool {
+	for _, finding := range analysis.Findings {
+		if finding.Quirk == quirk {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+AnalyzeQuirks looks through the code that can be reached from the start of
+a ROM, loaded as the platform would load it, for instructions whose
+behaviour depends on a quirk, and recommends a platform and quirks. The
+ROM must be valid for the platform. Code only reachable through BNNN isn't
+checked, and since register values are only known where they're loaded
+with constants just before use, the findings are hints rather than proof.
+*/
+func AnalyzeQuirks(rom []byte, platform Platform) (*QuirkAnalysis, error) {
+	if _, err := ValidateROM(rom, platform); err != nil {
+		return nil, err
+	}
+	memory := make([]byte, platform.MemorySize)
+	copy(memory[platform.ProgramStart:], rom)
+	flow := AnalyzeControlFlow(memory, platform.ProgramStart)
+	a := &quirkAnalyzer{platform: platform, memory: memory, flow: flow, analysis: &QuirkAnalysis{InstructionSet: "CHIP-8"}}
+	for _, address := range flow.Addresses() {
+		instruction := a.instruction(address)
+		for r := 0; r < 16; r++ {
+			if writesRegister(instruction, r) {
+				a.written[r] = true
+			}
+		}
+	}
+
+	for _, address := range flow.Addresses() {
+		a.check(address)
+	}
+	a.checkSpriteEdges()
+	sort.SliceStable(a.analysis.Findings, func(i, j int) bool {
+		return a.analysis.Findings[i].Address < a.analysis.Findings[j].Address
+	})
+	a.recommend()
+	return a.analysis, nil
+}
+
+type quirkAnalyzer struct {
+	platform Platform
+	memory   []byte
+	flow     *ControlFlow
+	analysis *QuirkAnalysis
+	// Registers written by any reachable instruction
+	written [16]bool
+	// Shifts and computed jumps that do and don't point to a behaviour
+	shiftsInPlace, shifts             int
+	jumpsUsingVX, jumpsUsingV0, jumps int
+	// Whether there is any BNNN, whose targets aren't analysed
+	computedJumps bool
+}
+
+func (a *quirkAnalyzer) instruction(address uint16) uint16 {
+	return uint16(a.memory[address])<<8 | uint16(a.memory[address+1])
+}
+
+func (a *quirkAnalyzer) report(address uint16, quirk string, format string, args ...any) {
+	a.analysis.Findings = append(a.analysis.Findings, QuirkFinding{
+		Address:     address,
+		Instruction: a.instruction(address),
+		Quirk:       quirk,
+		Reason:      fmt.Sprintf(format, args...),
+	})
+}
+
+func (a *quirkAnalyzer) check(address uint16) {
+	instruction := a.instruction(address)
+	vx, vy := int(x(instruction)), int(y(instruction))
+
+	if isXOCHIPInstruction(instruction) {
+		a.analysis.InstructionSet = "XO-CHIP"
+		a.report(address, "", "XO-CHIP instruction")
+		return
+	}
+	if isSCHIPInstruction(instruction) {
+		if a.analysis.InstructionSet == "CHIP-8" {
+			a.analysis.InstructionSet = "SUPER-CHIP"
+		}
+		a.report(address, "", "SUPER-CHIP instruction")
+		return
+	}
+
+	switch instruction >> 12 {
+	case 0x8:
+		switch instruction & 0xF {
+		case 0x1, 0x2, 0x3:
+			if reader, ok := a.readsBeforeWrite(address, 0xF); ok {
+				a.report(address, "vfReset", "VF is read at 0x%03X, and is only reset to 0 with the quirk", reader)
+			}
+		case 0x6, 0xE:
+			if vx != vy {
+				a.shifts++
+				if !a.written[vy] {
+					a.shiftsInPlace++
+					a.report(address, "shiftUsesVY", "shifts V%X in place without the quirk, and V%X is never set, so the ROM probably expects that", vx, vy)
+				} else {
+					a.report(address, "shiftUsesVY", "shifts V%X into V%X with the quirk, and V%X in place without it", vy, vx, vx)
+				}
+			}
+		}
+		if n := instruction & 0xF; (n >= 0x4 && n <= 0x7 || n == 0xE) && (vx == 0xF || vy == 0xF) {
+			a.report(address, "", "VF is an operand of an instruction that also sets VF, and interpreters disagree on which is written last")
+		}
+	case 0xB:
+		a.computedJumps = true
+		if vx == 0 {
+			// XNN plus VX and NNN plus V0 are the same jump
+			a.report(address, "", "computed jump, whose targets weren't analysed")
+			break
+		}
+		a.jumps++
+		switch {
+		case a.written[vx] && !a.written[0]:
+			a.jumpsUsingVX++
+			a.report(address, "jumpUsesVX", "adds V%X to the target with the quirk and V0 without it, and only V%X is ever set; the targets weren't analysed", vx, vx)
+		case a.written[0] && !a.written[vx]:
+			a.jumpsUsingV0++
+			a.report(address, "jumpUsesVX", "adds V%X to the target with the quirk and V0 without it, and only V0 is ever set; the targets weren't analysed", vx)
+		default:
+			a.report(address, "jumpUsesVX", "adds V%X to the target with the quirk and V0 without it; the targets weren't analysed", vx)
+		}
+	case 0xF:
+		switch instruction & 0xFF {
+		case 0x55, 0x65:
+			if user, ok := a.usesIBeforeSet(address); ok {
+				a.report(address, "memoryIncrementsIndex", "I is used again at 0x%03X, and only moves past the registers with the quirk", user)
+			}
+		}
+	}
+}
+
+/*
+Returns the addresses of the instructions that straight-line code runs
+after the one at address, stopping at the first instruction that can go
+anywhere but the next one or the one after it.
+*/
+func (a *quirkAnalyzer) following(address uint16) []uint16 {
+	var addresses []uint16
+	for next := address + 2; len(addresses) < lookahead && a.flow.Instructions[next]; next += 2 {
+		addresses = append(addresses, next)
+		instruction := a.instruction(next)
+		if instruction == 0x00EE || instruction>>12 == 0x1 || instruction>>12 == 0x2 || instruction>>12 == 0xB {
+			break
+		}
+	}
+	return addresses
+}
+
+// Returns the first instruction after address that reads register r before anything writes it
+func (a *quirkAnalyzer) readsBeforeWrite(address uint16, r int) (uint16, bool) {
+	for _, next := range a.following(address) {
+		instruction := a.instruction(next)
+		if readsRegister(instruction, r) {
+			return next, true
+		}
+		if writesRegister(instruction, r) && !isSkip(a.instruction(next-2)) {
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// Returns the first instruction after address that uses I before anything sets it
+func (a *quirkAnalyzer) usesIBeforeSet(address uint16) (uint16, bool) {
+	for _, next := range a.following(address) {
+		instruction := a.instruction(next)
+		if usesIndex(instruction) {
+			return next, true
+		}
+		if setsIndex(instruction) && !isSkip(a.instruction(next-2)) {
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+/*
+Finds sprites drawn across the edge of the screen by following register
+loads through each run of straight-line code, forgetting everything at
+jump and call targets.
+*/
+func (a *quirkAnalyzer) checkSpriteEdges() {
+	var known [16]bool
+	var values [16]byte
+	previous := -2
+	for _, address := range a.flow.Addresses() {
+		if int(address) != previous+2 || a.flow.JumpTargets[address] || a.flow.Subroutines[address] {
+			known = [16]bool{}
+		}
+		previous = int(address)
+		instruction := a.instruction(address)
+		vx, vy := x(instruction), y(instruction)
+
+		if instruction>>12 == 0xD && instruction&0xF != 0 && known[vx] && known[vy] {
+			column, row := int(values[vx])%pixelsWidth, int(values[vy])%pixelsHeight
+			if column+8 > pixelsWidth || row+int(instruction&0xF) > pixelsHeight {
+				a.report(address, "clipSprites", "draws a sprite at (%d, %d), across the edge of the screen, which is cut off with the quirk and wraps around without it", column, row)
+			}
+		}
+
+		// A write after a skip may not happen, so its result isn't known
+		conditional := address >= 2 && a.flow.Instructions[address-2] && isSkip(a.instruction(address-2))
+		for r := 0; r < 16; r++ {
+			if !writesRegister(instruction, r) {
+				continue
+			}
+			switch {
+			case conditional:
+				known[r] = false
+			case instruction>>12 == 0x6:
+				known[r], values[r] = true, nn(instruction)
+			case instruction>>12 == 0x7 && known[r]:
+				values[r] += nn(instruction)
+			default:
+				known[r] = false
+			}
+		}
+	}
+}
+
+// The name ParsePlatform accepts for one of the presets
+func platformName(platform Platform) string {
+	for name, preset := range platforms {
+		if preset.Name == platform.Name {
+			return name
+		}
+	}
+	return "chip8"
+}
+
+// Chooses a platform from the instruction set and evidence, and its quirks with any the evidence overrides
+func (a *quirkAnalyzer) recommend() {
+	analysis := a.analysis
+	inPlace := a.shifts > 0 && a.shiftsInPlace == a.shifts
+	usesVX := a.jumpsUsingVX > 0 && a.jumpsUsingV0 == 0
+	usesV0 := a.jumpsUsingV0 > 0 && a.jumpsUsingVX == 0
+
+	switch {
+	case analysis.InstructionSet == "XO-CHIP":
+		analysis.Platform = "octo"
+		analysis.Reasons = append(analysis.Reasons, "The ROM uses XO-CHIP instructions")
+	case analysis.InstructionSet == "SUPER-CHIP":
+		analysis.Platform = "hp48"
+		analysis.Reasons = append(analysis.Reasons, "The ROM uses SUPER-CHIP instructions")
+	case a.platform.ProgramStart != DefaultPlatform.ProgramStart:
+		// Any other platform would load it at the wrong address
+		analysis.Platform = platformName(a.platform)
+		analysis.Reasons = append(analysis.Reasons, fmt.Sprintf("The ROM only uses CHIP-8 instructions, and is loaded at 0x%03X like on the %s", a.platform.ProgramStart, a.platform.Name))
+	case inPlace || usesVX:
+		analysis.Platform = "hp48"
+		analysis.Reasons = append(analysis.Reasons, "The ROM only uses CHIP-8 instructions, but relies on SUPER-CHIP behaviour")
+	default:
+		analysis.Platform = "chip8"
+		analysis.Reasons = append(analysis.Reasons, "The ROM only uses CHIP-8 instructions")
+	}
+	platform, _ := ParsePlatform(analysis.Platform)
+	analysis.Quirks = platform.Quirks
+
+	if inPlace {
+		analysis.Quirks.ShiftUsesVY = false
+		analysis.Reasons = append(analysis.Reasons, "Shifts use registers that are never set, so they must shift in place")
+	}
+	if usesVX {
+		analysis.Quirks.JumpUsesVX = true
+		analysis.Reasons = append(analysis.Reasons, "Computed jumps only make sense if they add VX")
+	} else if usesV0 {
+		analysis.Quirks.JumpUsesVX = false
+		analysis.Reasons = append(analysis.Reasons, "Computed jumps only make sense if they add V0")
+	}
+	if a.computedJumps {
+		analysis.Reasons = append(analysis.Reasons, "Code only reachable through computed jumps (BNNN) wasn't analysed, and may change the recommendation")
+	}
+}
+
+// Reports whether an instruction reads register r, under any quirks
+func readsRegister(instruction uint16, r int) bool {
+	vx, vy := int(x(instruction)), int(y(instruction))
+	switch instruction >> 12 {
+	case 0x3, 0x4, 0x7:
+		return vx == r
+	case 0x5, 0x9, 0xD:
+		return vx == r || vy == r
+	case 0x8:
+		if instruction&0xF == 0x0 {
+			return vy == r
+		}
+		return vx == r || vy == r
+	case 0xB:
+		return r == 0 || vx == r
+	case 0xE:
+		return vx == r
+	case 0xF:
+		switch instruction & 0xFF {
+		case 0x15, 0x18, 0x1E, 0x29, 0x30, 0x33:
+			return vx == r
+		case 0x55:
+			return r <= vx
+		}
+	}
+	return false
+}
+
+// Reports whether an instruction writes register r, under any quirks
+func writesRegister(instruction uint16, r int) bool {
+	vx := int(x(instruction))
+	switch instruction >> 12 {
+	case 0x6, 0x7, 0xC:
+		return vx == r
+	case 0x8:
+		switch instruction & 0xF {
+		case 0x0:
+			return vx == r
+		case 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0xE:
+			return vx == r || r == 0xF
+		}
+	case 0xF:
+		switch instruction & 0xFF {
+		case 0x07, 0x0A:
+			return vx == r
+		case 0x65:
+			return r <= vx
+		}
+	}
+	return false
+}
+
+// Reports whether an instruction reads memory or I through I
+func usesIndex(instruction uint16) bool {
+	if instruction>>12 == 0xD {
+		return true
+	}
+	if instruction>>12 == 0xF {
+		switch instruction & 0xFF {
+		case 0x1E, 0x33, 0x55, 0x65:
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether an instruction sets I without reading it
+func setsIndex(instruction uint16) bool {
+	if instruction>>12 == 0xA {
+		return true
+	}
+	if instruction>>12 == 0xF {
+		switch instruction & 0xFF {
+		case 0x29, 0x30:
+			return true
+		}
+	}
+	return false
+}
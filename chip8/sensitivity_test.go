@@ -0,0 +1,162 @@
+package chip8
+
+import (
+	"errors"
+	"testing"
+)
+
+func analyzeQuirks(t *testing.T, rom []byte) *QuirkAnalysis {
+	t.Helper()
+	analysis, err := AnalyzeQuirks(rom, DefaultPlatform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return analysis
+}
+
+func findingsFor(analysis *QuirkAnalysis, quirk string) []QuirkFinding {
+	var findings []QuirkFinding
+	for _, finding := range analysis.Findings {
+		if finding.Quirk == quirk {
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+func TestAnalyzeQuirksShifts(t *testing.T) {
+	analysis := analyzeQuirks(t, []byte{
+		0x60, 0x81, // 200: LD V0, 0x81
+		0x80, 0x16, // 202: SHR V0, V1
+		0x80, 0x06, // 204: SHR V0, V0
+		0x12, 0x04, // 206: JP 0x204
+	})
+
+	findings := findingsFor(analysis, "shiftUsesVY")
+	if len(findings) != 1 || findings[0].Address != 0x202 {
+		t.Fatalf("Findings are %+v", analysis.Findings)
+	}
+	if analysis.Platform != "hp48" || analysis.Quirks.ShiftUsesVY {
+		t.Errorf("Recommended %s with %+v", analysis.Platform, analysis.Quirks)
+	}
+
+	// Once VY is set, either behaviour is plausible
+	analysis = analyzeQuirks(t, []byte{0x61, 0x02, 0x80, 0x16, 0x12, 0x04})
+	if len(findingsFor(analysis, "shiftUsesVY")) != 1 || analysis.Platform != "chip8" || analysis.Quirks != DefaultQuirks {
+		t.Errorf("Recommended %s with %+v", analysis.Platform, analysis.Quirks)
+	}
+}
+
+func TestAnalyzeQuirksMemory(t *testing.T) {
+	analysis := analyzeQuirks(t, []byte{
+		0xA3, 0x00, // 200: LD I, 0x300
+		0xF1, 0x55, // 202: LD [I], V1
+		0xF1, 0x65, // 204: LD V1, [I]
+		0xA3, 0x00, // 206: LD I, 0x300
+		0xD0, 0x15, // 208: DRW V0, V1, 5
+		0x12, 0x0A, // 20A: JP 0x20A
+	})
+
+	findings := findingsFor(analysis, "memoryIncrementsIndex")
+	if len(findings) != 1 || findings[0].Address != 0x202 {
+		t.Errorf("Findings are %+v", analysis.Findings)
+	}
+	if analysis.DependsOn("vfReset") || analysis.DependsOn("jumpUsesVX") {
+		t.Errorf("Findings are %+v", analysis.Findings)
+	}
+}
+
+func TestAnalyzeQuirksVFAndJumps(t *testing.T) {
+	analysis := analyzeQuirks(t, []byte{
+		0x62, 0x04, // 200: LD V2, 0x04
+		0x81, 0x11, // 202: OR V1, V1
+		0x3F, 0x00, // 204: SE VF, 0x00
+		0x8F, 0x14, // 206: ADD VF, V1
+		0xB2, 0x10, // 208: JP V2, 0x210
+		0x00, 0x00,
+		0x00, 0x00,
+		0x00, 0x00,
+		0x12, 0x10, // 210: JP 0x210
+	})
+
+	if findings := findingsFor(analysis, "vfReset"); len(findings) != 1 || findings[0].Address != 0x202 {
+		t.Errorf("Findings are %+v", analysis.Findings)
+	}
+	if findings := findingsFor(analysis, ""); len(findings) != 1 || findings[0].Address != 0x206 {
+		t.Errorf("Findings are %+v", analysis.Findings)
+	}
+	if findings := findingsFor(analysis, "jumpUsesVX"); len(findings) != 1 || findings[0].Address != 0x208 {
+		t.Errorf("Findings are %+v", analysis.Findings)
+	}
+	if analysis.Platform != "hp48" || !analysis.Quirks.JumpUsesVX {
+		t.Errorf("Recommended %s with %+v", analysis.Platform, analysis.Quirks)
+	}
+}
+
+func TestAnalyzeQuirksSpriteEdges(t *testing.T) {
+	analysis := analyzeQuirks(t, []byte{
+		0x60, 0x3C, // 200: LD V0, 60
+		0x61, 0x00, // 202: LD V1, 0
+		0xD0, 0x15, // 204: DRW V0, V1, 5
+		0x70, 0xC4, // 206: ADD V0, 0xC4 (wraps to 0)
+		0xD0, 0x15, // 208: DRW V0, V1, 5
+		0x3F, 0x01, // 20A: SE VF, 1
+		0x61, 0x1E, // 20C: LD V1, 30
+		0xD0, 0x15, // 20E: DRW V0, V1, 5
+		0x12, 0x10, // 210: JP 0x210
+	})
+
+	findings := findingsFor(analysis, "clipSprites")
+	if len(findings) != 1 || findings[0].Address != 0x204 {
+		t.Errorf("Findings are %+v", analysis.Findings)
+	}
+}
+
+func TestAnalyzeQuirksExtensions(t *testing.T) {
+	analysis := analyzeQuirks(t, []byte{0x00, 0xFF, 0x12, 0x02})
+	if analysis.InstructionSet != "SUPER-CHIP" || analysis.Platform != "hp48" || analysis.Quirks != SCHIPQuirks {
+		t.Errorf("Recommended %s with %+v for %s", analysis.Platform, analysis.Quirks, analysis.InstructionSet)
+	}
+
+	analysis = analyzeQuirks(t, []byte{0x00, 0xFF, 0xF0, 0x02, 0x12, 0x04})
+	if analysis.InstructionSet != "XO-CHIP" || analysis.Platform != "octo" || len(analysis.Findings) != 2 {
+		t.Errorf("Recommended %s for %s with %+v", analysis.Platform, analysis.InstructionSet, analysis.Findings)
+	}
+}
+
+func TestAnalyzeQuirksComputedJumps(t *testing.T) {
+	analysis := analyzeQuirks(t, []byte{
+		0x60, 0x02, // 200: LD V0, 0x02
+		0xB0, 0x04, // 202: JP V0, 0x004
+		0x12, 0x04, // 204: JP 0x204
+	})
+	findings := findingsFor(analysis, "")
+	if len(findings) != 1 || findings[0].Address != 0x202 || analysis.DependsOn("jumpUsesVX") {
+		t.Errorf("Findings are %+v", analysis.Findings)
+	}
+	if len(analysis.Reasons) != 2 {
+		t.Errorf("Reasons are %q", analysis.Reasons)
+	}
+}
+
+func TestAnalyzeQuirksPlatform(t *testing.T) {
+	if _, err := AnalyzeQuirks(nil, DefaultPlatform); !errors.Is(err, ErrEmptyROM) {
+		t.Errorf("Analysing an empty ROM returned %v", err)
+	}
+	var tooLarge *ROMTooLargeError
+	if _, err := AnalyzeQuirks(make([]byte, 0x1000), DefaultPlatform); !errors.As(err, &tooLarge) {
+		t.Errorf("Analysing a ROM larger than memory returned %v", err)
+	}
+
+	// Loaded at 0x600, where the jump lands on the shift
+	analysis, err := AnalyzeQuirks([]byte{0x16, 0x04, 0x00, 0x00, 0x80, 0x16, 0x16, 0x04}, ETI660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findings := findingsFor(analysis, "shiftUsesVY"); len(findings) != 1 || findings[0].Address != 0x604 {
+		t.Errorf("Findings are %+v", analysis.Findings)
+	}
+	if analysis.Platform != "eti660" {
+		t.Errorf("Recommended platform is %s", analysis.Platform)
+	}
+}
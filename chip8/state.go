@@ -0,0 +1,153 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrRandomSourceNotSeeded is returned by SaveState for chips created with WithRandomSource
+var ErrRandomSourceNotSeeded = errors.New("save states need a chip whose random source was created from a seed")
+
+/*
+seededSource is the random source of chips created with a seed. It's a
+SplitMix64 generator, whose whole state is one number, so save states can
+copy it rather than having to replay every number drawn since the seed.
+*/
+type seededSource struct {
+	state uint64
+}
+
+func newSeededSource(seed int64) *seededSource {
+	return &seededSource{state: uint64(seed)}
+}
+
+func (s *seededSource) Uint64() uint64 {
+	s.state += 0x9E3779B97F4A7C15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+func (s *seededSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *seededSource) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+/*
+State is a save state: a copy of everything that changes as a chip runs,
+which LoadState can put back later. Settings such as the platform, quirks
+and ROM aren't part of it, so a state can only be loaded into the chip it
+was saved from, or one created with the same settings.
+*/
+type State struct {
+	memory           []byte
+	programCounter   uint16
+	indexRegister    uint16
+	stack            [16]uint16
+	stackPointer     int
+	delayTimerValue  uint8
+	soundTimerValue  uint8
+	generalRegisters [16]byte
+	// Columns of the display one after another
+	pixels              []bool
+	waitingOnKeyRelease bool
+	previousKeys        [16]bool
+	keys                [16]bool
+	lastTimerTick       time.Time
+	randomState         uint64
+	cycleBudget         float64
+	fault               *Fault
+}
+
+/*
+SaveState copies the chip's current state. It returns
+ErrRandomSourceNotSeeded if the chip was created with WithRandomSource,
+since there's no way to put an arbitrary source back where it was.
+*/
+func (chip *Chip) SaveState() (*State, error) {
+	state := &State{}
+	if err := chip.saveState(state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Saves the state into an existing one, reusing its buffers so that saving every frame doesn't allocate
+func (chip *Chip) saveState(state *State) error {
+	if chip.random == nil {
+		return ErrRandomSourceNotSeeded
+	}
+	if len(state.memory) != len(chip.memory) {
+		state.memory = make([]byte, len(chip.memory))
+	}
+	copy(state.memory, chip.memory)
+	if size := len(chip.Pixels) * len(chip.Pixels[0]); len(state.pixels) != size {
+		state.pixels = make([]bool, size)
+	}
+	height := len(chip.Pixels[0])
+	for i, column := range chip.Pixels {
+		copy(state.pixels[i*height:], column)
+	}
+	state.programCounter = chip.programCounter
+	state.indexRegister = chip.indexRegister
+	state.stack = chip.stack
+	state.stackPointer = chip.stackPointer
+	state.delayTimerValue = chip.delayTimerValue
+	state.soundTimerValue = chip.SoundTimerValue
+	state.generalRegisters = chip.generalRegisters
+	state.waitingOnKeyRelease = chip.waitingOnKeyRelease
+	state.previousKeys = chip.previousKeys
+	state.keys = chip.keys
+	state.lastTimerTick = chip.lastTimerTick
+	state.randomState = chip.random.state
+	state.cycleBudget = chip.cycleBudget
+	state.fault = chip.fault
+	return nil
+}
+
+/*
+LoadState puts the chip back in a state saved by SaveState. It returns an
+error, leaving the chip unchanged, if the state was saved from a chip with
+a different memory size. The Sound callback is called if the buzzer
+changes.
+*/
+func (chip *Chip) LoadState(state *State) error {
+	if len(state.memory) != len(chip.memory) {
+		return fmt.Errorf("state has %d bytes of memory, but the chip has %d", len(state.memory), len(chip.memory))
+	}
+	if chip.random == nil {
+		return ErrRandomSourceNotSeeded
+	}
+	// Only the bytes that actually change invalidate translated code
+	for address, value := range state.memory {
+		if chip.memory[address] != value {
+			chip.memoryWritten(uint16(address), 1)
+			chip.memory[address] = value
+		}
+	}
+	chip.programCounter = state.programCounter
+	chip.indexRegister = state.indexRegister
+	chip.stack = state.stack
+	chip.stackPointer = state.stackPointer
+	chip.delayTimerValue = state.delayTimerValue
+	chip.SoundTimerValue = state.soundTimerValue
+	chip.generalRegisters = state.generalRegisters
+	height := len(chip.Pixels[0])
+	for i, column := range chip.Pixels {
+		copy(column, state.pixels[i*height:])
+	}
+	chip.waitingOnKeyRelease = state.waitingOnKeyRelease
+	chip.previousKeys = state.previousKeys
+	chip.keys = state.keys
+	chip.lastTimerTick = state.lastTimerTick
+	chip.random.state = state.randomState
+	chip.cycleBudget = state.cycleBudget
+	chip.fault = state.fault
+	chip.updateSound()
+	return nil
+}
@@ -0,0 +1,95 @@
+package chip8
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestLoadStateRewinds(t *testing.T) {
+	chip, movie := recordTestMovie(t, 40)
+	state, err := chip.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 60; i++ {
+		var keys [16]bool
+		keys[0] = i%5 == 0
+		movie.RunMovieFrame(chip, keys, 7, false)
+	}
+	first := chip.StateChecksum()
+
+	if err := chip.LoadState(state); err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := movie.NewChip(movieROM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 40; i++ {
+		movie.RerecordFrame(replayed, i)
+	}
+	if diff := diffChips(chip, replayed); diff != "" {
+		t.Fatalf("Loaded state differs from frame 40: %s", diff)
+	}
+
+	// The random numbers drawn after the state was saved must be drawn again
+	for i := 40; i < 100; i++ {
+		movie.RerecordFrame(chip, i)
+	}
+	if checksum := chip.StateChecksum(); checksum != first {
+		t.Errorf("Checksum after running from the state is %08X, expected %08X", checksum, first)
+	}
+}
+
+func TestLoadStateInvalidatesTranslatedCode(t *testing.T) {
+	chip := newTestChip(selfModifyingROM, WithRandomSeed(1))
+	engine := NewBlockEngine(chip)
+	state, err := chip.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.Run(30)
+	if err := chip.LoadState(state); err != nil {
+		t.Fatal(err)
+	}
+
+	interpreted := newTestChip(selfModifyingROM, WithRandomSeed(1))
+	for i := 0; i < 30; i++ {
+		engine.Run(1)
+		interpreted.Step()
+		if diff := diffChips(chip, interpreted); diff != "" {
+			t.Fatalf("Cycle %d after loading the state: %s", i, diff)
+		}
+	}
+}
+
+func TestSaveStateErrors(t *testing.T) {
+	chip := newTestChip(movieROM, WithRandomSource(rand.NewSource(1)))
+	if _, err := chip.SaveState(); !errors.Is(err, ErrRandomSourceNotSeeded) {
+		t.Errorf("Saving a state with an unseeded source returned %v", err)
+	}
+
+	small := newTestChip(movieROM, WithRandomSeed(1), WithMemorySize(0x1000))
+	big := newTestChip(movieROM, WithRandomSeed(1), WithMemorySize(0x2000))
+	state, err := small.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	big.WriteMemory(0x300, 0xAA)
+	if err := big.LoadState(state); err == nil {
+		t.Error("Loaded a state with a different memory size")
+	}
+	if big.Memory()[0x300] != 0xAA {
+		t.Error("Failed load changed memory")
+	}
+}
+
+func BenchmarkSaveAndLoadState(b *testing.B) {
+	chip := newTestChip(movieROM, WithRandomSeed(1))
+	var state State
+	for i := 0; i < b.N; i++ {
+		chip.saveState(&state)
+		chip.LoadState(&state)
+	}
+}
@@ -0,0 +1,294 @@
+package chip8
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Largest message a transport carries, which fits in a UDP datagram
+const maxMessageSize = 0xFFFF
+
+/*
+Transport carries messages between two netplay peers. Messages may be lost
+or arrive twice, as they can over UDP, so netplay keeps resending anything
+that hasn't been acknowledged.
+*/
+type Transport interface {
+	Send(message []byte) error
+	// Blocks until a message arrives, or returns an error once the transport is closed
+	Receive() ([]byte, error)
+	Close() error
+}
+
+// Frames messages over a stream connection such as TCP with a 16-bit length
+type streamTransport struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newStreamTransport(conn net.Conn) *streamTransport {
+	return &streamTransport{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func (t *streamTransport) Send(message []byte) error {
+	if len(message) > maxMessageSize {
+		return fmt.Errorf("message of %d bytes is too long", len(message))
+	}
+	frame := make([]byte, 2+len(message))
+	binary.BigEndian.PutUint16(frame, uint16(len(message)))
+	copy(frame[2:], message)
+	_, err := t.conn.Write(frame)
+	return err
+}
+
+func (t *streamTransport) Receive() ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(t.reader, length[:]); err != nil {
+		return nil, err
+	}
+	message := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(t.reader, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (t *streamTransport) Close() error {
+	return t.conn.Close()
+}
+
+// Sends each message as a datagram to the peer, ignoring datagrams from anywhere else
+type packetTransport struct {
+	conn net.PacketConn
+	peer net.Addr
+	// The datagram that Accept found the peer with, which the first Receive returns
+	first []byte
+}
+
+func (t *packetTransport) Send(message []byte) error {
+	if len(message) > maxMessageSize {
+		return fmt.Errorf("message of %d bytes is too long", len(message))
+	}
+	_, err := t.conn.WriteTo(message, t.peer)
+	return err
+}
+
+func (t *packetTransport) Receive() ([]byte, error) {
+	if t.first != nil {
+		message := t.first
+		t.first = nil
+		return message, nil
+	}
+	buffer := make([]byte, maxMessageSize)
+	for {
+		n, from, err := t.conn.ReadFrom(buffer)
+		if err != nil {
+			return nil, err
+		}
+		if from.String() == t.peer.String() {
+			return append([]byte(nil), buffer[:n]...), nil
+		}
+	}
+}
+
+func (t *packetTransport) Close() error {
+	return t.conn.Close()
+}
+
+// NetplayListener waits for a peer to connect over TCP or UDP
+type NetplayListener struct {
+	listener net.Listener
+	packets  net.PacketConn
+	accepted bool
+}
+
+// Listens on the address, with network "tcp" or "udp"
+func ListenNetplay(network, address string) (*NetplayListener, error) {
+	switch network {
+	case "tcp":
+		listener, err := net.Listen(network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &NetplayListener{listener: listener}, nil
+	case "udp":
+		packets, err := net.ListenPacket(network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &NetplayListener{packets: packets}, nil
+	}
+	return nil, fmt.Errorf("unknown network %q (expected tcp or udp)", network)
+}
+
+func (l *NetplayListener) Addr() net.Addr {
+	if l.listener != nil {
+		return l.listener.Addr()
+	}
+	return l.packets.LocalAddr()
+}
+
+/*
+Accept waits for a peer and returns a transport connected to it. Over UDP
+the peer is whoever sends the first datagram, and the transport takes over
+the listener's socket, so Accept can only be called once.
+*/
+func (l *NetplayListener) Accept() (Transport, error) {
+	if l.listener != nil {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		return newStreamTransport(conn), nil
+	}
+	buffer := make([]byte, maxMessageSize)
+	n, peer, err := l.packets.ReadFrom(buffer)
+	if err != nil {
+		return nil, err
+	}
+	l.accepted = true
+	return &packetTransport{conn: l.packets, peer: peer, first: buffer[:n]}, nil
+}
+
+// Stops listening. A UDP listener's socket belongs to its transport once a peer has been accepted, and isn't closed.
+func (l *NetplayListener) Close() error {
+	if l.listener != nil {
+		return l.listener.Close()
+	}
+	if l.accepted {
+		return nil
+	}
+	return l.packets.Close()
+}
+
+// Connects to a peer listening with ListenNetplay, with network "tcp" or "udp"
+func DialNetplay(network, address string) (Transport, error) {
+	switch network {
+	case "tcp":
+		conn, err := net.Dial(network, address)
+		if err != nil {
+			return nil, err
+		}
+		return newStreamTransport(conn), nil
+	case "udp":
+		peer, err := net.ResolveUDPAddr(network, address)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := net.ListenPacket(network, ":0")
+		if err != nil {
+			return nil, err
+		}
+		return &packetTransport{conn: conn, peer: peer}, nil
+	}
+	return nil, fmt.Errorf("unknown network %q (expected tcp or udp)", network)
+}
+
+// Messages waiting to be delivered by a loopback transport
+type delayedMessage struct {
+	message   []byte
+	deliverAt time.Time
+}
+
+// One end of a pair of transports created by NewLoopback
+type loopbackTransport struct {
+	latency  time.Duration
+	outgoing chan delayedMessage
+	// Closed once the other end has been closed and its messages delivered
+	inbox     chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+/*
+NewLoopback returns two transports connected to each other in memory, which
+deliver every message in order after the latency. It lets netplay be tried
+out, and tested, on one machine as if the players were far apart.
+*/
+func NewLoopback(latency time.Duration) (Transport, Transport) {
+	a := newLoopbackTransport(latency)
+	b := newLoopbackTransport(latency)
+	go deliverMessages(a, b)
+	go deliverMessages(b, a)
+	return a, b
+}
+
+func newLoopbackTransport(latency time.Duration) *loopbackTransport {
+	return &loopbackTransport{
+		latency:  latency,
+		outgoing: make(chan delayedMessage, 1024),
+		inbox:    make(chan []byte, 1024),
+		closed:   make(chan struct{}),
+	}
+}
+
+/*
+Delivers each message to the other end once its time comes, and closes
+its inbox once the sending end has been closed and the messages it had
+already sent are delivered. Messages for an end that has been closed are
+dropped. The outgoing channel is never closed, so that a Send racing with
+Close can't send on a closed channel.
+*/
+func deliverMessages(from, to *loopbackTransport) {
+	defer close(to.inbox)
+	deliver := func(delayed delayedMessage) {
+		time.Sleep(time.Until(delayed.deliverAt))
+		select {
+		case to.inbox <- delayed.message:
+		case <-to.closed:
+		}
+	}
+	for {
+		select {
+		case delayed := <-from.outgoing:
+			deliver(delayed)
+		case <-from.closed:
+			for {
+				select {
+				case delayed := <-from.outgoing:
+					deliver(delayed)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (t *loopbackTransport) Send(message []byte) error {
+	select {
+	case <-t.closed:
+		return net.ErrClosed
+	default:
+	}
+	select {
+	case t.outgoing <- delayedMessage{append([]byte(nil), message...), time.Now().Add(t.latency)}:
+		return nil
+	case <-t.closed:
+		return net.ErrClosed
+	}
+}
+
+func (t *loopbackTransport) Receive() ([]byte, error) {
+	select {
+	case message, ok := <-t.inbox:
+		if !ok {
+			return nil, io.EOF
+		}
+		return message, nil
+	case <-t.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (t *loopbackTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.closed)
+	})
+	return nil
+}
@@ -0,0 +1,100 @@
+package chip8
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// UnknownOpcodePolicy decides what happens when the chip executes an instruction it doesn't implement, such as 0NNN, 8XY8 or EX00
+type UnknownOpcodePolicy int
+
+const (
+	// The instruction does nothing
+	IgnoreUnknownOpcodes UnknownOpcodePolicy = iota
+	// The instruction does nothing, and is logged with the standard logger
+	// the first time it's executed at each address
+	LogUnknownOpcodes
+	// The instruction faults
+	FaultUnknownOpcodes
+	// The instruction is passed to the UnknownOpcode callback
+	HookUnknownOpcodes
+)
+
+// UnknownOpcode is an instruction the chip doesn't implement, and how many times it was executed at an address
+type UnknownOpcode struct {
+	Address     uint16
+	Instruction uint16
+	Count       int
+}
+
+func (u UnknownOpcode) String() string {
+	return fmt.Sprintf("unknown opcode %04X (%s) at 0x%03X", u.Instruction, Disassemble(u.Instruction), u.Address)
+}
+
+/*
+Returns every unknown opcode the chip has executed since the ROM was
+loaded, ordered by address, whatever the policy. Resetting the chip
+doesn't clear them.
+*/
+func (chip *Chip) UnknownOpcodes() []UnknownOpcode {
+	opcodes := make([]UnknownOpcode, 0, len(chip.unknownOpcodeCounts))
+	for key, count := range chip.unknownOpcodeCounts {
+		opcodes = append(opcodes, UnknownOpcode{Address: uint16(key >> 16), Instruction: uint16(key), Count: count})
+	}
+	sort.Slice(opcodes, func(i, j int) bool {
+		if opcodes[i].Address != opcodes[j].Address {
+			return opcodes[i].Address < opcodes[j].Address
+		}
+		return opcodes[i].Instruction < opcodes[j].Instruction
+	})
+	return opcodes
+}
+
+// Records an unknown opcode that was just fetched and applies the policy to it
+func (chip *Chip) unknownOpcode(instruction uint16) {
+	address := chip.programCounter - 2
+	if chip.unknownOpcodeCounts == nil {
+		chip.unknownOpcodeCounts = make(map[uint32]int)
+	}
+	key := uint32(address)<<16 | uint32(instruction)
+	chip.unknownOpcodeCounts[key]++
+
+	switch chip.unknownOpcodes {
+	case LogUnknownOpcodes:
+		if chip.unknownOpcodeCounts[key] == 1 {
+			log.Print(UnknownOpcode{Address: address, Instruction: instruction})
+		}
+	case FaultUnknownOpcodes:
+		chip.raiseFault(instruction, "unknown opcode")
+	case HookUnknownOpcodes:
+		if chip.callbacks.UnknownOpcode != nil {
+			chip.callbacks.UnknownOpcode(address, instruction)
+		}
+	}
+}
+
+func (p UnknownOpcodePolicy) String() string {
+	switch p {
+	case LogUnknownOpcodes:
+		return "log"
+	case FaultUnknownOpcodes:
+		return "fault"
+	case HookUnknownOpcodes:
+		return "hook"
+	}
+	return "ignore"
+}
+
+// Parses the policies that make sense from the command line. HookUnknownOpcodes needs a callback, so it isn't one of them.
+func ParseUnknownOpcodePolicy(name string) (UnknownOpcodePolicy, error) {
+	switch name {
+	case "ignore":
+		return IgnoreUnknownOpcodes, nil
+	case "log":
+		return LogUnknownOpcodes, nil
+	case "fault":
+		return FaultUnknownOpcodes, nil
+	}
+	return IgnoreUnknownOpcodes, fmt.Errorf("unknown opcode policy %q (expected ignore, log or fault)", name)
+}
@@ -0,0 +1,115 @@
+package chip8
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+// 0NNN, 8XY8 and EX00 in a loop
+var unknownOpcodesROM = []byte{0x01, 0x23, 0x80, 0x18, 0xE1, 0x00, 0x12, 0x00}
+
+func TestIgnoreUnknownOpcodes(t *testing.T) {
+	chip := newTestChip(unknownOpcodesROM)
+	for i := 0; i < 8; i++ {
+		chip.Step()
+	}
+
+	expected := []UnknownOpcode{
+		{Address: 0x200, Instruction: 0x0123, Count: 2},
+		{Address: 0x202, Instruction: 0x8018, Count: 2},
+		{Address: 0x204, Instruction: 0xE100, Count: 2},
+	}
+	opcodes := chip.UnknownOpcodes()
+	if len(opcodes) != len(expected) {
+		t.Fatalf("Reported %v", opcodes)
+	}
+	for i := range expected {
+		if opcodes[i] != expected[i] {
+			t.Errorf("Reported %v instead of %v", opcodes[i], expected[i])
+		}
+	}
+	if chip.Fault() != nil {
+		t.Error(chip.Fault())
+	}
+}
+
+func TestUnknownOpcodesReport(t *testing.T) {
+	chip := newTestChip(unknownOpcodesROM)
+	chip.Step()
+	chip.Reset()
+	if len(chip.UnknownOpcodes()) != 1 {
+		t.Error("Reset cleared the unknown opcodes")
+	}
+	if err := chip.LoadROM(unknownOpcodesROM); err != nil {
+		t.Fatal(err)
+	}
+	if len(chip.UnknownOpcodes()) != 0 {
+		t.Error("LoadROM did not clear the unknown opcodes")
+	}
+}
+
+func TestLogUnknownOpcodes(t *testing.T) {
+	var output bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&output)
+
+	chip := newTestChip(unknownOpcodesROM, WithUnknownOpcodes(LogUnknownOpcodes))
+	for i := 0; i < 8; i++ {
+		chip.Step()
+	}
+	if lines := strings.Count(output.String(), "\n"); lines != 3 {
+		t.Errorf("Logged %d lines instead of once per address:\n%s", lines, output.String())
+	}
+	if !strings.Contains(output.String(), "unknown opcode 8018 (DW 0x8018) at 0x202") {
+		t.Errorf("Log doesn't describe 8018:\n%s", output.String())
+	}
+}
+
+func TestFaultUnknownOpcodes(t *testing.T) {
+	chip := newTestChip(unknownOpcodesROM[2:], WithUnknownOpcodes(FaultUnknownOpcodes))
+	if chip.Step() || chip.Step() {
+		t.Error("Faulted chip executed an instruction")
+	}
+	fault := chip.Fault()
+	if fault == nil || fault.Address != 0x200 || fault.Instruction != 0x8018 {
+		t.Fatalf("Fault is %v", fault)
+	}
+	if chip.ProgramCounter() != 0x200 {
+		t.Errorf("Program counter is 0x%03X", chip.ProgramCounter())
+	}
+}
+
+func TestHookUnknownOpcodes(t *testing.T) {
+	var hooked []uint16
+	chip := newTestChip(unknownOpcodesROM, WithUnknownOpcodes(HookUnknownOpcodes), WithCallbacks(Callbacks{
+		UnknownOpcode: func(address uint16, instruction uint16) {
+			hooked = append(hooked, address, instruction)
+		},
+	}))
+	chip.Step()
+	chip.Step()
+	if len(hooked) != 4 || hooked[0] != 0x200 || hooked[1] != 0x0123 || hooked[2] != 0x202 || hooked[3] != 0x8018 {
+		t.Errorf("Hook was called with %04X", hooked)
+	}
+}
+
+func TestUnknownOpcodesWithBlockEngine(t *testing.T) {
+	chip := newTestChip(unknownOpcodesROM, WithUnknownOpcodes(FaultUnknownOpcodes))
+	executed, _ := NewBlockEngine(chip).Run(10)
+	if executed != 1 || chip.Fault() == nil || chip.ProgramCounter() != 0x200 {
+		t.Errorf("Executed %d instructions, fault %v", executed, chip.Fault())
+	}
+}
+
+func TestParseUnknownOpcodePolicy(t *testing.T) {
+	for name, expected := range map[string]UnknownOpcodePolicy{"ignore": IgnoreUnknownOpcodes, "log": LogUnknownOpcodes, "fault": FaultUnknownOpcodes} {
+		if policy, err := ParseUnknownOpcodePolicy(name); err != nil || policy != expected {
+			t.Errorf("Parsed %q as %d, %v", name, policy, err)
+		}
+	}
+	if _, err := ParseUnknownOpcodePolicy("hook"); err == nil {
+		t.Error("Parsed hook, which needs a callback")
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+	"github.com/rdhillon1016/chip8-emulator/io"
+)
+
+// Usage: chip8 diff rom.ch8 -a chip8 -b chip8,shiftUsesVY=false
+func diff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	configA := flags.String("a", "chip8", "First configuration: a platform, optionally followed by quirks such as ,shiftUsesVY=false (default is chip8)")
+	configB := flags.String("b", "chip8", "Second configuration, in the same form as -a (default is chip8)")
+	seed := flags.Int64("seed", 1, "Seed of the random number generator both chips use (default is 1)")
+	executionRateHz := flags.Int("executionRate", 700, "Execution rate of both chips in Hz (default is 700)")
+	maxFrames := flags.Int("frames", 3600, "Number of 60Hz frames to run before giving up (default is 3600)")
+	context := flags.Int("context", 8, "Number of instructions shown on each side of the divergence (default is 8)")
+	keySchedule := flags.String("keys", "", "Keys held from each frame on, such as 60:5,90:,120:46 (default is none)")
+	show := flags.Bool("show", false, "Show both screens side by side, with input from the keyboard instead of -keys")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		fail("usage: chip8 diff rom.ch8 [-a configuration] [-b configuration] [-seed n] [-keys schedule] [-show]")
+	}
+
+	rom, err := os.ReadFile(positional[0])
+	if err != nil {
+		fail("Unable to read ROM file: %v", err)
+	}
+	schedule, err := parseKeySchedule(*keySchedule)
+	if err != nil {
+		fail("%v", err)
+	}
+	a := newDiffChip(rom, *configA, *seed)
+	b := newDiffChip(rom, *configB, *seed)
+	differ := chip8.NewDiffer(a, b, *executionRateHz/60)
+	differ.Context = *context
+
+	if *show {
+		metadata, _ := chip8.DefaultROMDatabase().Lookup(rom)
+		io.RunDiff(differ, *configA, *configB, io.Config{KeyHints: metadata.Keys})
+		return
+	}
+
+	for differ.Frame() < *maxFrames && !differ.Done() {
+		differ.RunFrame(schedule.keysAt(differ.Frame()))
+	}
+	divergence := differ.Divergence()
+	if divergence == nil {
+		fmt.Printf("No divergence in %d frames (%d instructions)\n", differ.Frame(), differ.Cycle())
+		return
+	}
+	printDivergence(divergence, *configA, *configB)
+	os.Exit(1)
+}
+
+// Creates a chip from a configuration such as "vip,shiftUsesVY=false"
+func newDiffChip(rom []byte, configuration string, seed int64) *chip8.Chip {
+	fields := strings.Split(configuration, ",")
+	platform, err := chip8.ParsePlatform(fields[0])
+	if err != nil {
+		fail("%v", err)
+	}
+	quirks := platform.Quirks
+	for _, field := range fields[1:] {
+		name, value, _ := strings.Cut(field, "=")
+		set, err := strconv.ParseBool(value)
+		if err != nil {
+			fail("Invalid quirk setting %q in %q (expected name=true or name=false)", field, configuration)
+		}
+		if err := quirks.Set(name, set); err != nil {
+			fail("%v", err)
+		}
+	}
+
+	chip, err := chip8.NewChip(rom, chip8.WithPlatform(platform), chip8.WithQuirks(quirks), chip8.WithRandomSeed(seed))
+	if err != nil {
+		fail("Unable to load ROM with %s: %v", configuration, err)
+	}
+	return chip
+}
+
+// The keys held from each frame listed until the next one
+type keySchedule []keyScheduleEntry
+
+type keyScheduleEntry struct {
+	frame int
+	keys  [16]bool
+}
+
+// Parses a schedule such as "60:5,90:,120:46", where keys are hex digits
+func parseKeySchedule(s string) (keySchedule, error) {
+	var schedule keySchedule
+	if s == "" {
+		return schedule, nil
+	}
+	for _, entry := range strings.Split(s, ",") {
+		frameText, keysText, ok := strings.Cut(entry, ":")
+		frame, err := strconv.Atoi(frameText)
+		if !ok || err != nil || (len(schedule) > 0 && frame <= schedule[len(schedule)-1].frame) {
+			return nil, fmt.Errorf("invalid key schedule entry %q (expected increasing frame:keys, such as 60:5)", entry)
+		}
+		keys, err := parseKeys(keysText)
+		if err != nil {
+			return nil, fmt.Errorf("%v in key schedule entry %q", err, entry)
+		}
+		schedule = append(schedule, keyScheduleEntry{frame: frame, keys: keys})
+	}
+	return schedule, nil
+}
+
+// Parses keys written as hex digits, such as "46"
+func parseKeys(s string) ([16]bool, error) {
+	var keys [16]bool
+	for _, key := range s {
+		index, err := strconv.ParseUint(string(key), 16, 4)
+		if err != nil {
+			return keys, fmt.Errorf("invalid key %q", key)
+		}
+		keys[index] = true
+	}
+	return keys, nil
+}
+
+func (schedule keySchedule) keysAt(frame int) [16]bool {
+	var keys [16]bool
+	for _, entry := range schedule {
+		if entry.frame > frame {
+			break
+		}
+		keys = entry.keys
+	}
+	return keys
+}
+
+func printDivergence(divergence *chip8.Divergence, labelA, labelB string) {
+	fmt.Printf("Diverged after %d instructions, in frame %d\n", divergence.Cycle, divergence.Frame)
+	fmt.Printf("A is %s and B is %s\n\n", labelA, labelB)
+	for _, difference := range divergence.Differences {
+		fmt.Printf("  %s\n", difference)
+	}
+	if len(divergence.Before) == 0 {
+		fmt.Println("\nThe chips differed before running any instructions")
+		return
+	}
+
+	fmt.Printf("\n  %-8s %-36s %s\n", "Cycle", "A", "B")
+	for i, step := range append(divergence.Before, divergence.After...) {
+		marker := " "
+		if i == len(divergence.Before)-1 {
+			marker = ">"
+		}
+		fmt.Printf("%s %-8d %-36s %s\n", marker, step.Cycle, step.A, step.B)
+	}
+}
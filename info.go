@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+)
+
+// Usage: chip8 info rom.ch8
+func info(args []string) {
+	flags := flag.NewFlagSet("info", flag.ExitOnError)
+	romDatabasePath := flags.String("romdb", "", "Location of a ROM database file whose entries take priority over the built-in ones")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		fail("usage: chip8 info rom.ch8 [-romdb database.json]")
+	}
+
+	rom, err := os.ReadFile(positional[0])
+	if err != nil {
+		fail("Unable to read ROM file: %v", err)
+	}
+	metadata, known := lookupROM(rom, *romDatabasePath)
+	platform := chip8.DefaultPlatform
+	if known {
+		platform = metadata.Platform
+	}
+
+	fmt.Printf("File:             %s\n", filepath.Base(positional[0]))
+	fmt.Printf("SHA-1:            %s\n", chip8.ROMHash(rom))
+	fmt.Printf("Size:             %d bytes\n", len(rom))
+	romInfo, err := chip8.ValidateROM(rom, platform)
+	if err != nil {
+		fmt.Printf("Error:            %v\n", err)
+	} else {
+		fmt.Printf("Instruction set:  %s\n", romInfo.InstructionSet)
+		for _, warning := range romInfo.Warnings {
+			fmt.Printf("Warning:          %s\n", warning)
+		}
+	}
+
+	if !known {
+		fmt.Println("Not in the ROM database")
+		return
+	}
+	fmt.Printf("Title:            %s\n", metadata.Title)
+	if metadata.Author != "" {
+		fmt.Printf("Author:           %s\n", metadata.Author)
+	}
+	fmt.Printf("Platform:         %s\n", metadata.Platform.Name)
+	fmt.Printf("Quirks:           %s\n", describeQuirks(metadata.Quirks))
+	if metadata.CyclesPerFrame > 0 {
+		fmt.Printf("Cycles per frame: %d (-executionRate %d)\n", metadata.CyclesPerFrame, metadata.CyclesPerFrame*60)
+	}
+	if len(metadata.Keys) > 0 {
+		actions := make([]string, 0, len(metadata.Keys))
+		for action := range metadata.Keys {
+			actions = append(actions, action)
+		}
+		sort.Strings(actions)
+		for i, action := range actions {
+			actions[i] = fmt.Sprintf("%s %X", action, metadata.Keys[action])
+		}
+		fmt.Printf("Keys:             %s\n", strings.Join(actions, ", "))
+	}
+	if metadata.Foreground != (color.RGBA{}) || metadata.Background != (color.RGBA{}) {
+		fmt.Printf("Colours:          %s on %s\n", describeColor(metadata.Foreground), describeColor(metadata.Background))
+	}
+}
+
+// Looks a ROM up in the database file, if there is one, and then in the built-in database
+func lookupROM(rom []byte, romDatabasePath string) (chip8.ROMMetadata, bool) {
+	if romDatabasePath != "" {
+		data, err := os.ReadFile(romDatabasePath)
+		if err != nil {
+			fail("Unable to read ROM database: %v", err)
+		}
+		db, err := chip8.ParseROMDatabase(data)
+		if err != nil {
+			fail("Unable to load %s: %v", romDatabasePath, err)
+		}
+		if metadata, ok := db.Lookup(rom); ok {
+			return metadata, true
+		}
+	}
+	return chip8.DefaultROMDatabase().Lookup(rom)
+}
+
+// Lists the quirks that are set, by their names in the ROM database
+func describeQuirks(quirks chip8.Quirks) string {
+	var names []string
+	for _, name := range chip8.QuirkNames {
+		if quirks.Get(name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+func describeColor(c color.RGBA) string {
+	if c == (color.RGBA{}) {
+		return "the default"
+	}
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,271 @@
+package io
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+)
+
+const (
+	// The debugger panel is laid out on a grid of character cells
+	debuggerColumns = 80
+	debuggerRows    = 36
+
+	disassemblyRow      = 8
+	disassemblyLines    = 17
+	memoryRow           = 27
+	memoryBytesPerRow   = 16
+	memoryRowsShown     = 8
+	stackColumn         = 44
+	changeHighlightTime = 60
+)
+
+type debugTargetKind int
+
+const (
+	targetNone debugTargetKind = iota
+	targetRegister
+	targetIndexRegister
+	targetProgramCounter
+	targetDelayTimer
+	targetSoundTimer
+	targetMemory
+	// Clicking a disassembly line toggles a breakpoint instead of editing
+	targetBreakpoint
+)
+
+type debugTarget struct {
+	kind debugTargetKind
+	// Register number, or memory address for targetMemory and targetBreakpoint
+	index int
+}
+
+// debugItem is a run of text placed on the panel's character grid
+type debugItem struct {
+	text        string
+	column, row int
+	target      debugTarget
+	highlighted bool
+}
+
+func (item debugItem) contains(column, row int) bool {
+	return row == item.row && column >= item.column && column < item.column+len(item.text)
+}
+
+/*
+debugger holds the state of the debugger panel that is shown next to the
+game. It lays the panel out as a list of text items so that drawing and
+mouse handling can share the same positions.
+
+The debugger was meant to have a window of its own, but ebitengine can
+only open one window per process, so it is a panel in the game window
+instead.
+*/
+type debugger struct {
+	breakpoints map[uint16]bool
+	// Frames since each byte of memory last changed
+	previousMemory []byte
+	changeAge      []int
+	// First address shown in the hex view
+	memoryOffset int
+	editing      debugTarget
+	editBuffer   string
+}
+
+func newDebugger() *debugger {
+	return &debugger{
+		breakpoints:  map[uint16]bool{},
+		memoryOffset: 0x200,
+	}
+}
+
+// Tracks which bytes of memory changed since the previous frame
+func (d *debugger) update(c *chip8.Chip) {
+	memory := c.Memory()
+	if len(d.previousMemory) != len(memory) {
+		d.previousMemory = memory
+		d.changeAge = make([]int, len(memory))
+		for i := range d.changeAge {
+			d.changeAge[i] = changeHighlightTime
+		}
+		return
+	}
+	for i, v := range memory {
+		if v != d.previousMemory[i] {
+			d.changeAge[i] = 0
+		} else if d.changeAge[i] < changeHighlightTime {
+			d.changeAge[i]++
+		}
+	}
+	d.previousMemory = memory
+}
+
+func (d *debugger) scrollMemory(rows int) {
+	d.memoryOffset += rows * memoryBytesPerRow
+	maxOffset := len(d.previousMemory) - memoryRowsShown*memoryBytesPerRow
+	if d.memoryOffset > maxOffset {
+		d.memoryOffset = maxOffset
+	}
+	if d.memoryOffset < 0 {
+		d.memoryOffset = 0
+	}
+}
+
+// Shows the value being typed in place of the value of the item being edited
+func (d *debugger) editable(name, value string, target debugTarget) debugItem {
+	if d.editing == target {
+		value = d.editBuffer + "_"
+	}
+	return debugItem{text: name + value, target: target, highlighted: d.editing == target}
+}
+
+func (d *debugger) layout(c *chip8.Chip) []debugItem {
+	var items []debugItem
+	add := func(item debugItem, column, row int) {
+		item.column, item.row = column, row
+		items = append(items, item)
+	}
+	label := func(text string, column, row int) {
+		add(debugItem{text: text}, column, row)
+	}
+
+	label("REGISTERS", 0, 0)
+	for i, v := range c.Registers() {
+		name := fmt.Sprintf("V%X:", i)
+		add(d.editable(name, fmt.Sprintf("%02X", v), debugTarget{targetRegister, i}), (i%4)*8, 1+i/4)
+	}
+	add(d.editable("I:", fmt.Sprintf("%03X", c.IndexRegister()), debugTarget{kind: targetIndexRegister}), 0, 5)
+	add(d.editable("PC:", fmt.Sprintf("%03X", c.ProgramCounter()), debugTarget{kind: targetProgramCounter}), 8, 5)
+	label(fmt.Sprintf("SP:%d", c.StackPointer()), 17, 5)
+	add(d.editable("DT:", fmt.Sprintf("%02X", c.DelayTimerValue()), debugTarget{kind: targetDelayTimer}), 0, 6)
+	add(d.editable("ST:", fmt.Sprintf("%02X", c.SoundTimerValue), debugTarget{kind: targetSoundTimer}), 8, 6)
+
+	label("CALL STACK", stackColumn, 0)
+	stack := c.Stack()
+	for i := len(stack) - 1; i >= 0; i-- {
+		label(fmt.Sprintf("#%-2d ret 0x%03X", i, stack[i]), stackColumn, 1+len(stack)-1-i)
+	}
+
+	label("DISASSEMBLY (click to toggle breakpoints)", 0, disassemblyRow-1)
+	memory := c.Memory()
+	pc := int(c.ProgramCounter())
+	for line := 0; line < disassemblyLines; line++ {
+		address := pc + 2*(line-disassemblyLines/2)
+		if address < 0 || address+1 >= len(memory) {
+			continue
+		}
+		instruction := uint16(memory[address])<<8 | uint16(memory[address+1])
+		marker := "  "
+		if address == pc {
+			marker = "> "
+		}
+		breakpoint := " "
+		if d.breakpoints[uint16(address)] {
+			breakpoint = "*"
+		}
+		text := fmt.Sprintf("%s%s 0x%03X  %04X  %s", marker, breakpoint, address, instruction, chip8.Disassemble(instruction))
+		add(debugItem{text: text, target: debugTarget{targetBreakpoint, address}, highlighted: address == pc}, 0, disassemblyRow+line)
+	}
+
+	label("MEMORY (scroll to move, click to edit)", 0, memoryRow-1)
+	for row := 0; row < memoryRowsShown; row++ {
+		rowAddress := d.memoryOffset + row*memoryBytesPerRow
+		if rowAddress >= len(memory) {
+			break
+		}
+		label(fmt.Sprintf("%03X:", rowAddress), 0, memoryRow+row)
+		for i := 0; i < memoryBytesPerRow && rowAddress+i < len(memory); i++ {
+			address := rowAddress + i
+			target := debugTarget{targetMemory, address}
+			item := d.editable("", fmt.Sprintf("%02X", memory[address]), target)
+			if d.changeAge != nil && d.changeAge[address] < changeHighlightTime {
+				item.highlighted = true
+			}
+			add(item, 5+i*3, memoryRow+row)
+		}
+	}
+	return items
+}
+
+// Handles a click on the given character cell
+func (d *debugger) click(c *chip8.Chip, column, row int) {
+	d.editing = debugTarget{}
+	d.editBuffer = ""
+	for _, item := range d.layout(c) {
+		if !item.contains(column, row) {
+			continue
+		}
+		switch item.target.kind {
+		case targetNone:
+		case targetBreakpoint:
+			address := uint16(item.target.index)
+			if d.breakpoints[address] {
+				delete(d.breakpoints, address)
+			} else {
+				d.breakpoints[address] = true
+			}
+		default:
+			d.editing = item.target
+		}
+		return
+	}
+}
+
+func (d *debugger) isEditing() bool {
+	return d.editing.kind != targetNone
+}
+
+// Number of hex digits the value being edited can hold
+func (d *debugger) editDigits() int {
+	switch d.editing.kind {
+	case targetIndexRegister, targetProgramCounter:
+		return 3
+	}
+	return 2
+}
+
+func (d *debugger) typeChar(r rune) {
+	if len(d.editBuffer) >= d.editDigits() {
+		return
+	}
+	if _, err := strconv.ParseUint(string(r), 16, 8); err == nil {
+		d.editBuffer += string(r)
+	}
+}
+
+func (d *debugger) cancelEdit() {
+	d.editing = debugTarget{}
+	d.editBuffer = ""
+}
+
+// Writes the value that was typed into the chip
+func (d *debugger) commitEdit(c *chip8.Chip) {
+	defer d.cancelEdit()
+	if d.editBuffer == "" {
+		return
+	}
+	value, err := strconv.ParseUint(d.editBuffer, 16, 16)
+	if err != nil {
+		return
+	}
+
+	switch d.editing.kind {
+	case targetRegister:
+		c.SetRegister(d.editing.index, byte(value))
+	case targetIndexRegister:
+		c.SetIndexRegister(uint16(value))
+	case targetProgramCounter:
+		// The chip would fault straight away on an address without a whole instruction
+		if int(value)+2 > c.Platform().MemorySize {
+			return
+		}
+		c.SetProgramCounter(uint16(value))
+	case targetDelayTimer:
+		c.SetDelayTimerValue(uint8(value))
+	case targetSoundTimer:
+		c.SoundTimerValue = uint8(value)
+	case targetMemory:
+		c.WriteMemory(uint16(d.editing.index), byte(value))
+	}
+}
@@ -0,0 +1,137 @@
+package io
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+)
+
+func newTestChip(t *testing.T, rom []byte) *chip8.Chip {
+	t.Helper()
+	c, err := chip8.NewChip(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func findItem(items []debugItem, prefix string) (debugItem, bool) {
+	for _, item := range items {
+		if strings.HasPrefix(strings.TrimSpace(item.text), prefix) {
+			return item, true
+		}
+	}
+	return debugItem{}, false
+}
+
+func TestDebuggerDisassemblyCentredOnPC(t *testing.T) {
+	c := newTestChip(t, []byte{0x60, 0x01, 0x61, 0x02, 0x62, 0x03})
+	c.ExecuteCycle()
+	d := newDebugger()
+
+	item, ok := findItem(d.layout(c), ">")
+	if !ok {
+		t.Fatal("No line was marked as the current instruction")
+	}
+	if item.row != disassemblyRow+disassemblyLines/2 || !strings.Contains(item.text, "0x202  6102  LD V1, 0x02") {
+		t.Errorf("Incorrect current line %q at row %d", item.text, item.row)
+	}
+}
+
+func TestDebuggerToggleBreakpoint(t *testing.T) {
+	c := newTestChip(t, []byte{0x60, 0x01, 0x61, 0x02})
+	d := newDebugger()
+	row := disassemblyRow + disassemblyLines/2 + 1
+
+	d.click(c, 5, row)
+
+	if !d.breakpoints[0x202] {
+		t.Fatal("Clicking a line didn't set a breakpoint")
+	}
+	if item, _ := findItem(d.layout(c), "* 0x202"); item.row != row {
+		t.Error("Breakpoint was not shown")
+	}
+
+	d.click(c, 5, row)
+
+	if d.breakpoints[0x202] {
+		t.Error("Clicking a line again didn't clear the breakpoint")
+	}
+}
+
+func TestDebuggerEditRegister(t *testing.T) {
+	c := newTestChip(t, []byte{0x00, 0xE0})
+	d := newDebugger()
+	item, _ := findItem(d.layout(c), "V5:")
+
+	d.click(c, item.column, item.row)
+	for _, r := range "a7f" {
+		d.typeChar(r)
+	}
+	d.commitEdit(c)
+
+	if c.Registers()[5] != 0xA7 {
+		t.Errorf("Register was not edited, got %02X", c.Registers()[5])
+	}
+	if d.isEditing() {
+		t.Error("Debugger is still editing after committing")
+	}
+}
+
+func TestDebuggerEditProgramCounter(t *testing.T) {
+	c := newTestChip(t, []byte{0x00, 0xE0})
+	d := newDebugger()
+	for _, value := range []string{"fff", "ffe"} {
+		item, _ := findItem(d.layout(c), "PC:")
+		d.click(c, item.column, item.row)
+		for _, r := range value {
+			d.typeChar(r)
+		}
+		d.commitEdit(c)
+	}
+
+	if c.ProgramCounter() != 0xFFE {
+		t.Errorf("Program counter is 0x%03X, expected the edit to 0xFFF to be discarded", c.ProgramCounter())
+	}
+}
+
+func TestDebuggerEditMemory(t *testing.T) {
+	c := newTestChip(t, []byte{0x00, 0xE0})
+	d := newDebugger()
+	d.update(c)
+
+	// Second byte of the first row of the hex view, which starts at 0x200
+	d.click(c, 5+3, memoryRow)
+	d.typeChar('4')
+	d.typeChar('2')
+	d.commitEdit(c)
+	d.update(c)
+
+	if c.Memory()[0x201] != 0x42 {
+		t.Fatal("Memory was not edited")
+	}
+	for _, item := range d.layout(c) {
+		if item.row == memoryRow && item.column == 5+3 && !item.highlighted {
+			t.Error("Changed byte was not highlighted")
+		}
+		if item.row == memoryRow && item.column == 5 && item.highlighted {
+			t.Error("Unchanged byte was highlighted")
+		}
+	}
+}
+
+func TestDebuggerCallStack(t *testing.T) {
+	c := newTestChip(t, []byte{0x22, 0x02, 0x22, 0x04})
+	c.ExecuteCycle()
+	c.ExecuteCycle()
+	d := newDebugger()
+
+	items := d.layout(c)
+	innermost, _ := findItem(items, "#1")
+	outermost, _ := findItem(items, "#0")
+
+	if innermost.row != 1 || outermost.row != 2 || !strings.Contains(innermost.text, "0x204") {
+		t.Error("Call stack was not shown innermost first")
+	}
+}
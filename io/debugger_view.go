@@ -0,0 +1,77 @@
+package io
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+)
+
+const (
+	// Size of a character cell in ebitenutil's debug font
+	charWidth  = 6
+	lineHeight = 16
+
+	debuggerWidth  = debuggerColumns * charWidth
+	debuggerHeight = debuggerRows * lineHeight
+)
+
+var (
+	debuggerBackgroundColor = color.RGBA{0x10, 0x10, 0x18, 0xff}
+	debuggerHighlightColor  = color.RGBA{0x60, 0x30, 0x00, 0xff}
+)
+
+// Handles mouse and keyboard input for the debugger panel at panelX
+func (g *Game) updateDebugger(panelX int) {
+	d := g.debugger
+
+	if _, dy := ebiten.Wheel(); dy > 0 {
+		d.scrollMemory(-1)
+	} else if dy < 0 {
+		d.scrollMemory(1)
+	}
+
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		x, y := ebiten.CursorPosition()
+		if x >= panelX {
+			g.runner.Inspect(func(c *chip8.Chip) {
+				d.click(c, (x-panelX)/charWidth, y/lineHeight)
+			})
+			g.runner.SetBreakpoints(d.breakpoints)
+		} else {
+			d.cancelEdit()
+		}
+	}
+
+	if d.isEditing() {
+		for _, r := range ebiten.AppendInputChars(nil) {
+			d.typeChar(r)
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+			g.runner.Inspect(d.commitEdit)
+		} else if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+			d.cancelEdit()
+		}
+	}
+}
+
+func (g *Game) drawDebugger(screen *ebiten.Image, panelX int) {
+	height := float32(screen.Bounds().Dy())
+	vector.DrawFilledRect(screen, float32(panelX), 0, debuggerWidth, height, debuggerBackgroundColor, false)
+
+	var items []debugItem
+	g.runner.Inspect(func(c *chip8.Chip) {
+		items = g.debugger.layout(c)
+	})
+	for _, item := range items {
+		x := panelX + item.column*charWidth
+		y := item.row * lineHeight
+		if item.highlighted {
+			vector.DrawFilledRect(screen, float32(x), float32(y), float32(len(item.text)*charWidth), lineHeight, debuggerHighlightColor, false)
+		}
+		ebitenutil.DebugPrintAt(screen, item.text, x, y)
+	}
+}
@@ -0,0 +1,120 @@
+package io
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+)
+
+// Pixels between the two screens
+const diffGap = 8
+
+/*
+diffGame shows the two chips of a Differ side by side. Both are run on the
+game loop in lockstep, so they always get exactly the same input, and it
+pauses when they first diverge.
+*/
+type diffGame struct {
+	differ    *chip8.Differ
+	labels    [2]string
+	renderers [2]*frameRenderer
+	textures  [2]*ebiten.Image
+	paused    bool
+	diverged  bool
+	keyHints  map[string]byte
+}
+
+func (g *diffGame) Update() error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		return ebiten.Termination
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		g.paused = !g.paused
+	}
+	if g.paused && !inpututil.IsKeyJustPressed(ebiten.KeyPeriod) {
+		return nil
+	}
+	screenUpdated := g.differ.RunFrame(getKeyPresses(g.keyHints))
+	for _, renderer := range g.renderers {
+		renderer.addFrames(1)
+		if screenUpdated {
+			renderer.markDirty()
+		}
+	}
+	if !g.diverged && g.differ.Divergence() != nil {
+		g.diverged = true
+		g.paused = true
+	}
+	return nil
+}
+
+func (g *diffGame) Draw(screen *ebiten.Image) {
+	size := screen.Bounds().Size()
+	halfWidth := (size.X - diffGap) / 2
+	for i, chip := range []*chip8.Chip{g.differ.A, g.differ.B} {
+		frame, changed := g.renderers[i].render(chip.Pixels)
+		frameSize := frame.Rect.Size()
+		if g.textures[i] == nil || g.textures[i].Bounds().Size() != frameSize {
+			if g.textures[i] != nil {
+				g.textures[i].Dispose()
+			}
+			g.textures[i] = ebiten.NewImage(frameSize.X, frameSize.Y)
+			changed = true
+		}
+		if changed {
+			g.textures[i].WritePixels(frame.Pix)
+		}
+
+		left := i * (halfWidth + diffGap)
+		scale, target := fitIntegerScale(halfWidth, size.Y, frameSize.X, frameSize.Y)
+		options := &ebiten.DrawImageOptions{}
+		options.GeoM.Scale(float64(scale), float64(scale))
+		options.GeoM.Translate(float64(left+target.Min.X), float64(target.Min.Y))
+		screen.DrawImage(g.textures[i], options)
+		ebitenutil.DebugPrintAt(screen, g.labels[i], left+4, 4)
+	}
+
+	status := fmt.Sprintf("Frame %d, no divergence", g.differ.Frame())
+	if divergence := g.differ.Divergence(); divergence != nil {
+		status = fmt.Sprintf("Diverged after %d instructions, in frame %d: %s", divergence.Cycle, divergence.Frame, divergence.Differences[0])
+	}
+	if g.paused {
+		status += " (paused, P to resume, . to advance a frame)"
+	}
+	ebitenutil.DebugPrintAt(screen, status, 4, size.Y-20)
+}
+
+func (g *diffGame) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return outsideWidth, outsideHeight
+}
+
+/*
+RunDiff opens a window with the screens of the differ's two chips side by
+side, labelled with labelA and labelB, and runs them with the keyboard as
+input until the window is closed. Only the scale and filter settings of
+the config are used.
+*/
+func RunDiff(differ *chip8.Differ, labelA, labelB string, config Config) {
+	game := &diffGame{differ: differ, labels: [2]string{labelA, labelB}, keyHints: config.KeyHints}
+	for i := range game.renderers {
+		game.renderers[i] = newFrameRenderer(newPersistenceFilter(PersistenceNone, 0, 0), config.ScaleFilter)
+	}
+
+	scale := config.Scale
+	if scale < 1 {
+		scale = defaultScale / 2
+	}
+	width, height := len(differ.A.Pixels), len(differ.A.Pixels[0])
+	ebiten.SetWindowSize(2*width*scale+diffGap, height*scale)
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	ebiten.SetWindowTitle("Chip8 diff")
+	ebiten.SetTPS(frameRateHz)
+	if err := ebiten.RunGame(game); err != nil && !errors.Is(err, ebiten.Termination) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,27 @@
+package io
+
+import (
+	"image"
+	"image/color"
+)
+
+var (
+	foregroundColor = color.RGBA{0x0b, 0xd3, 0xd3, 0xff}
+	backgroundColor = color.RGBA{0x00, 0x00, 0x00, 0xff}
+)
+
+// Converts per-pixel intensities into colours, one image pixel per CHIP-8 pixel
+func rasterize(dst *image.RGBA, intensity [][]float64, foreground, background color.RGBA) {
+	for x, column := range intensity {
+		for y, val := range column {
+			dst.SetRGBA(x, y, mixColor(foreground, background, val))
+		}
+	}
+}
+
+func mixColor(fg, bg color.RGBA, amount float64) color.RGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a)*amount + float64(b)*(1-amount) + 0.5)
+	}
+	return color.RGBA{mix(fg.R, bg.R), mix(fg.G, bg.G), mix(fg.B, bg.B), mix(fg.A, bg.A)}
+}
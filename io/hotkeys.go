@@ -0,0 +1,125 @@
+package io
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+type hotkeyAction int
+
+const (
+	actionPause hotkeyAction = iota
+	actionFrameAdvance
+	actionReset
+	actionQuit
+	actionScreenshot
+	actionSpeedUp
+	actionSpeedDown
+	actionFullscreen
+	actionOverlay
+	actionDebugger
+)
+
+// None of these keys may appear in keysToIndexMap
+var hotkeysToActionMap map[ebiten.Key]hotkeyAction = map[ebiten.Key]hotkeyAction{
+	ebiten.KeyP:         actionPause,
+	ebiten.KeyPeriod:    actionFrameAdvance,
+	ebiten.KeyBackspace: actionReset,
+	ebiten.KeyEscape:    actionQuit,
+	ebiten.KeyF12:       actionScreenshot,
+	ebiten.KeyEqual:     actionSpeedUp,
+	ebiten.KeyMinus:     actionSpeedDown,
+	ebiten.KeyF11:       actionFullscreen,
+	ebiten.KeyF1:        actionOverlay,
+	ebiten.KeyF2:        actionDebugger,
+}
+
+// Multipliers of the configured execution rate that the speed hotkeys step through
+var speeds = []float64{0.25, 0.5, 1, 1.5, 2, 4}
+
+const normalSpeedIndex = 2
+
+func (g *Game) handleHotkeys() error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) && ebiten.IsKeyPressed(ebiten.KeyAlt) {
+		g.perform(actionFullscreen)
+	}
+	for k, action := range hotkeysToActionMap {
+		if inpututil.IsKeyJustPressed(k) {
+			if err := g.perform(action); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (g *Game) perform(action hotkeyAction) error {
+	now := time.Now()
+	switch action {
+	case actionPause:
+		if g.frame.Paused {
+			g.runner.Resume()
+			g.toast.show("Resumed", now)
+		} else {
+			g.runner.Pause()
+			g.toast.show("Paused", now)
+		}
+		g.frame = g.runner.Frame()
+	case actionFrameAdvance:
+		if g.frame.Paused {
+			g.runner.StepFrame()
+			g.frame = g.runner.Frame()
+			g.toast.show("Frame advance", now)
+		}
+	case actionReset:
+		g.runner.Reset()
+		g.frame = g.runner.Frame()
+		g.renderer.reset()
+		g.toast.show("Reset", now)
+	case actionQuit:
+		return ebiten.Termination
+	case actionScreenshot:
+		// Rendering again here would use up the changes that Draw is waiting to upload
+		frame := g.renderer.lastFrame()
+		if frame == nil {
+			break
+		}
+		path, err := saveScreenshot(frame, ".", now)
+		if err != nil {
+			g.toast.show(fmt.Sprintf("Screenshot failed: %v", err), now)
+		} else {
+			g.toast.show(fmt.Sprintf("Saved %s", path), now)
+		}
+	case actionSpeedUp, actionSpeedDown:
+		if action == actionSpeedUp && g.speedIndex < len(speeds)-1 {
+			g.speedIndex++
+		} else if action == actionSpeedDown && g.speedIndex > 0 {
+			g.speedIndex--
+		}
+		speed := speeds[g.speedIndex]
+		g.runner.SetSpeed(speed)
+		g.toast.show(fmt.Sprintf("Speed %d%%", int(speed*100)), now)
+	case actionFullscreen:
+		ebiten.SetFullscreen(!ebiten.IsFullscreen())
+	case actionOverlay:
+		g.showOverlay = !g.showOverlay
+	case actionDebugger:
+		g.showDebugger = !g.showDebugger
+		g.debugger.cancelEdit()
+		// Makes room for the panel next to the game rather than shrinking the game
+		width, height := ebiten.WindowSize()
+		if g.showDebugger {
+			width += debuggerWidth
+			if height < debuggerHeight {
+				height = debuggerHeight
+			}
+		} else {
+			width -= debuggerWidth
+		}
+		ebiten.SetWindowSize(width, height)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package io
+
+import "testing"
+
+func TestHotkeysDontClashWithKeypad(t *testing.T) {
+	for k := range hotkeysToActionMap {
+		if _, ok := keysToIndexMap[k]; ok {
+			t.Errorf("Hotkey %s is also a keypad key", k)
+		}
+	}
+	for k, action := range actionKeysToNameMap {
+		_, keypad := keysToIndexMap[k]
+		_, hotkey := hotkeysToActionMap[k]
+		if keypad || hotkey {
+			t.Errorf("Key %s for %q is also a keypad key or hotkey", k, action)
+		}
+	}
+}
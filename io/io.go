@@ -1,16 +1,22 @@
 package io
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"image/color"
 	"log"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/rdhillon1016/chip8-emulator/chip8"
 )
 
 const (
-	windowWidth  = 1024
-	windowHeight = 512
+	// Each CHIP-8 pixel is drawn as a 16x16 block in the initial window
+	defaultScale = 16
+	frameRateHz  = 60
 )
 
 var keysToIndexMap map[ebiten.Key]uint = map[ebiten.Key]uint{
@@ -32,55 +38,218 @@ var keysToIndexMap map[ebiten.Key]uint = map[ebiten.Key]uint{
 	ebiten.KeyV: 0xF,
 }
 
+// Keys that also press the CHIP-8 key the ROM database gives for an action
+var actionKeysToNameMap map[ebiten.Key]string = map[ebiten.Key]string{
+	ebiten.KeyArrowUp:    "up",
+	ebiten.KeyArrowDown:  "down",
+	ebiten.KeyArrowLeft:  "left",
+	ebiten.KeyArrowRight: "right",
+	ebiten.KeySpace:      "fire",
+}
+
+type Config struct {
+	ExecutionRateHz  int
+	Persistence      PersistenceMode
+	PersistenceDecay float64
+	BlendFrames      int
+	ScaleFilter      ScaleFilter
+	// Initial window size as a multiple of the framebuffer size
+	Scale      int
+	Fullscreen bool
+	// Colours of lit and unlit pixels. Zero values use the default colours.
+	Foreground color.RGBA
+	Background color.RGBA
+	// Movie to record every frame into, created along with the chip by
+	// chip8.RecordMovie
+	Record *chip8.Movie
+	// Movie to play back instead of taking input from the keyboard, on a
+	// chip created by its NewChip
+	Play *chip8.Movie
+	// CHIP-8 key for each action, from the ROM database, which the arrow
+	// keys and Space press as well as the usual keypad keys
+	KeyHints map[string]byte
+}
+
 type Game struct {
-	chip *chip8.Chip
+	// Runs the chip on its own goroutine
+	runner *chip8.Runner
+	// Latest frame published by the runner, picked up once per update
+	frame           *chip8.Frame
+	renderer        *frameRenderer
+	renderedVersion uint64
+	// Persistent GPU copy of the renderer's output, only rewritten when the
+	// display changes
+	texture    *ebiten.Image
+	speedIndex int
+	toast      toast
+	// Debug overlay with the machine state and performance stats
+	showOverlay       bool
+	instructionsMeter rateMeter
+	showDebugger      bool
+	debugger          *debugger
+	breakpointCount   uint64
+	screenWidth       int
+	keyHints          map[string]byte
 }
 
 func (g *Game) Update() error {
-	g.chip.SetKeys(getKeyPresses())
-	g.chip.ExecuteCycle()
+	previous := g.frame
+	g.frame = g.runner.Frame()
+	now := time.Now()
+	g.instructionsMeter.add(int(g.frame.Instructions-previous.Instructions), now)
+	g.renderer.addFrames(int(g.frame.Frames - previous.Frames))
+	if g.frame.BreakpointCount != g.breakpointCount {
+		g.breakpointCount = g.frame.BreakpointCount
+		g.toast.show(fmt.Sprintf("Breakpoint at 0x%03X", g.frame.Breakpoint), now)
+	}
+	if g.frame.Fault != nil && g.frame.Fault != previous.Fault {
+		g.toast.show(g.frame.Fault.Error(), now)
+	}
+	if g.frame.Desync != nil && previous.Desync == nil {
+		g.toast.show(g.frame.Desync.Error(), now)
+	} else if g.frame.MovieFinished && !previous.MovieFinished {
+		g.toast.show(fmt.Sprintf("Movie finished after %d frames", g.frame.MovieFrame), now)
+	}
+
+	if g.showDebugger {
+		g.updateDebugger(g.gameAreaWidth())
+		g.runner.Inspect(g.debugger.update)
+	}
+	// Typing into the debugger shouldn't trigger hotkeys or press keypad keys
+	editing := g.showDebugger && g.debugger.isEditing()
+	if !editing {
+		if err := g.handleHotkeys(); err != nil {
+			return err
+		}
+	}
+	if editing {
+		g.runner.SetKeys([16]bool{})
+	} else {
+		g.runner.SetKeys(getKeyPresses(g.keyHints))
+	}
 	return nil
 }
 
-func getKeyPresses() [16]bool {
+// Width of the part of the screen the game is drawn in, which excludes the debugger panel
+func (g *Game) gameAreaWidth() int {
+	if g.showDebugger && g.screenWidth > debuggerWidth {
+		return g.screenWidth - debuggerWidth
+	}
+	return g.screenWidth
+}
+
+func getKeyPresses(keyHints map[string]byte) [16]bool {
 	var keyPresses [16]bool
 	for k, v := range keysToIndexMap {
 		keyPresses[v] = ebiten.IsKeyPressed(k)
 	}
+	for k, action := range actionKeysToNameMap {
+		if key, ok := keyHints[action]; ok && ebiten.IsKeyPressed(k) {
+			keyPresses[key] = true
+		}
+	}
 	return keyPresses
 }
 
 func (g *Game) Draw(screen *ebiten.Image) {
-	pixels := g.chip.Pixels
+	if g.frame.DisplayVersion != g.renderedVersion {
+		g.renderer.markDirty()
+		g.renderedVersion = g.frame.DisplayVersion
+	}
+	frame, changed := g.renderer.render(g.frame.Pixels)
+	frameSize := frame.Rect.Size()
+	if g.texture == nil || g.texture.Bounds().Size() != frameSize {
+		if g.texture != nil {
+			g.texture.Dispose()
+		}
+		g.texture = ebiten.NewImage(frameSize.X, frameSize.Y)
+		changed = true
+	}
+	if changed {
+		g.texture.WritePixels(frame.Pix)
+	}
 
-	scaledUpPixelWidth := windowWidth / len(pixels)
-	scaledUpPixelHeight := windowHeight / len(pixels[0])
-	pixelImg := ebiten.NewImage(scaledUpPixelWidth, scaledUpPixelHeight)
-	pixelImg.Fill(color.RGBA{0x0b, 0xd3, 0xd3, 0xff})
+	// The CPU filter has already done part of the scaling, so the rest is
+	// done in whole multiples of its output to keep the filtered pixels even
+	screenSize := screen.Bounds().Size()
+	g.screenWidth = screenSize.X
+	gameWidth := g.gameAreaWidth()
+	scale, target := fitIntegerScale(gameWidth, screenSize.Y, frameSize.X, frameSize.Y)
 
 	imgOptions := &ebiten.DrawImageOptions{}
+	imgOptions.GeoM.Scale(float64(scale), float64(scale))
+	imgOptions.GeoM.Translate(float64(target.Min.X), float64(target.Min.Y))
+	screen.DrawImage(g.texture, imgOptions)
 
-	for _, column := range pixels {
-		for _, val := range column {
-			if val {
-				screen.DrawImage(pixelImg, imgOptions)
-			}
-			imgOptions.GeoM.Translate(0, float64(scaledUpPixelHeight))
-		}
-		imgOptions.GeoM.Translate(0, -float64(windowHeight))
-		imgOptions.GeoM.Translate(float64(scaledUpPixelWidth), 0)
+	if g.showDebugger {
+		g.drawDebugger(screen, gameWidth)
+	}
+	if g.showOverlay {
+		var text string
+		g.runner.Inspect(func(c *chip8.Chip) {
+			text = overlayText(c, g.instructionsMeter.rate, ebiten.ActualFPS())
+		})
+		ebitenutil.DebugPrint(screen, text)
+	}
+	if message := g.toast.current(time.Now()); message != "" {
+		ebitenutil.DebugPrintAt(screen, message, 4, screenSize.Y-20)
 	}
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
-	return 1024, 512
+	return outsideWidth, outsideHeight
 }
 
-func Run(c *chip8.Chip, executionRateHz int) {
-	ebiten.SetWindowSize(windowWidth, windowHeight)
+// Runs the chip in a window until it is closed. The chip must not be used elsewhere afterwards.
+func Run(chip *chip8.Chip, config Config) {
+	runner := chip8.NewRunner(chip, config.ExecutionRateHz)
+	persistence := newPersistenceFilter(config.Persistence, config.PersistenceDecay, config.BlendFrames)
+	game := &Game{
+		runner:     runner,
+		frame:      runner.Frame(),
+		renderer:   newFrameRenderer(persistence, config.ScaleFilter),
+		speedIndex: normalSpeedIndex,
+		debugger:   newDebugger(),
+		keyHints:   config.KeyHints,
+	}
+
+	if config.Foreground != (color.RGBA{}) {
+		game.renderer.foreground = config.Foreground
+	}
+	if config.Background != (color.RGBA{}) {
+		game.renderer.background = config.Background
+	}
+
+	// The runner is stopped before returning, so that the caller can look at
+	// the chip once the window is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+	go func() {
+		runner.Run(ctx)
+		close(stopped)
+	}()
+	if config.Record != nil {
+		runner.Record(config.Record)
+	}
+	if config.Play != nil {
+		runner.Play(config.Play)
+	}
+
+	scale := config.Scale
+	if scale < 1 {
+		scale = defaultScale
+	}
+	pixels := game.frame.Pixels
+	ebiten.SetWindowSize(len(pixels)*scale, len(pixels[0])*scale)
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	ebiten.SetFullscreen(config.Fullscreen)
 	ebiten.SetWindowTitle("Chip8")
-	ebiten.SetTPS(executionRateHz)
-	if err := ebiten.RunGame(&Game{chip: c}); err != nil {
+	ebiten.SetTPS(frameRateHz)
+	if err := ebiten.RunGame(game); err != nil && !errors.Is(err, ebiten.Termination) {
 		log.Fatal(err)
 	}
 }
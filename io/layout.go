@@ -0,0 +1,25 @@
+package io
+
+import "image"
+
+/*
+Returns the largest whole-number factor by which a framebuffer of the given
+size can be scaled up while still fitting in the screen, along with the
+rectangle it should be drawn into so that it is centred with letterboxing
+on either side. The factor is never less than 1, even if that means the
+framebuffer gets cropped in a very small window.
+*/
+func fitIntegerScale(screenWidth, screenHeight, frameWidth, frameHeight int) (int, image.Rectangle) {
+	scale := screenWidth / frameWidth
+	if heightScale := screenHeight / frameHeight; heightScale < scale {
+		scale = heightScale
+	}
+	if scale < 1 {
+		scale = 1
+	}
+
+	width, height := frameWidth*scale, frameHeight*scale
+	offsetX := (screenWidth - width) / 2
+	offsetY := (screenHeight - height) / 2
+	return scale, image.Rect(offsetX, offsetY, offsetX+width, offsetY+height)
+}
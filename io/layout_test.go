@@ -0,0 +1,37 @@
+package io
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFitIntegerScaleExact(t *testing.T) {
+	scale, rect := fitIntegerScale(1024, 512, 64, 32)
+
+	if scale != 16 || rect != image.Rect(0, 0, 1024, 512) {
+		t.Errorf("Incorrect fit %d %v", scale, rect)
+	}
+}
+
+func TestFitIntegerScaleLetterbox(t *testing.T) {
+	scale, rect := fitIntegerScale(1920, 1080, 64, 32)
+
+	// 1920/64 = 30 but 1080/32 = 33, so the width limits the scale
+	if scale != 30 || rect != image.Rect(0, 60, 1920, 1020) {
+		t.Errorf("Incorrect fit %d %v", scale, rect)
+	}
+
+	scale, rect = fitIntegerScale(1000, 1000, 128, 64)
+
+	if scale != 7 || rect != image.Rect(52, 276, 948, 724) {
+		t.Errorf("Incorrect fit %d %v", scale, rect)
+	}
+}
+
+func TestFitIntegerScaleTooSmall(t *testing.T) {
+	scale, _ := fitIntegerScale(50, 20, 64, 32)
+
+	if scale != 1 {
+		t.Errorf("Scale fell below 1, got %d", scale)
+	}
+}
@@ -0,0 +1,124 @@
+package io
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+)
+
+// Frames without the other player's input before the game says it's waiting
+const netplayStallFrames = 10
+
+// netplayGame runs a netplay session on the game loop, one tick per frame
+type netplayGame struct {
+	netplay  *chip8.Netplay
+	renderer *frameRenderer
+	texture  *ebiten.Image
+	// Consecutive ticks that didn't run a frame
+	stalled  int
+	err      error
+	keyHints map[string]byte
+}
+
+func (g *netplayGame) Update() error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		return ebiten.Termination
+	}
+	if g.err != nil {
+		return nil
+	}
+	frame := g.netplay.Frame()
+	result, err := g.netplay.Tick(getKeyPresses(g.keyHints))
+	if err != nil {
+		g.err = err
+		return nil
+	}
+	g.renderer.addFrames(g.netplay.Frame() - frame)
+	if result.Ran {
+		g.stalled = 0
+	} else {
+		g.stalled++
+	}
+	if result.ScreenUpdated {
+		g.renderer.markDirty()
+	}
+	return nil
+}
+
+func (g *netplayGame) Draw(screen *ebiten.Image) {
+	frame, changed := g.renderer.render(g.netplay.Chip().Pixels)
+	frameSize := frame.Rect.Size()
+	if g.texture == nil || g.texture.Bounds().Size() != frameSize {
+		if g.texture != nil {
+			g.texture.Dispose()
+		}
+		g.texture = ebiten.NewImage(frameSize.X, frameSize.Y)
+		changed = true
+	}
+	if changed {
+		g.texture.WritePixels(frame.Pix)
+	}
+
+	size := screen.Bounds().Size()
+	scale, target := fitIntegerScale(size.X, size.Y, frameSize.X, frameSize.Y)
+	options := &ebiten.DrawImageOptions{}
+	options.GeoM.Scale(float64(scale), float64(scale))
+	options.GeoM.Translate(float64(target.Min.X), float64(target.Min.Y))
+	screen.DrawImage(g.texture, options)
+
+	status := ""
+	if g.err != nil {
+		status = fmt.Sprintf("Game over: %v (Esc to quit)", g.err)
+	} else if g.stalled >= netplayStallFrames {
+		status = "Waiting for the other player..."
+	}
+	if status != "" {
+		ebitenutil.DebugPrintAt(screen, status, 4, size.Y-20)
+	}
+}
+
+func (g *netplayGame) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return outsideWidth, outsideHeight
+}
+
+/*
+RunNetplay opens a window for a netplay game, with this player's keys
+taken from the keyboard, until the window is closed or the game ends. The
+session is closed before returning, and its error, if the game ended
+because of one, is returned.
+*/
+func RunNetplay(netplay *chip8.Netplay, config Config) error {
+	defer netplay.Close()
+	game := &netplayGame{
+		netplay:  netplay,
+		renderer: newFrameRenderer(newPersistenceFilter(config.Persistence, config.PersistenceDecay, config.BlendFrames), config.ScaleFilter),
+		keyHints: config.KeyHints,
+	}
+	if config.Foreground != (color.RGBA{}) {
+		game.renderer.foreground = config.Foreground
+	}
+	if config.Background != (color.RGBA{}) {
+		game.renderer.background = config.Background
+	}
+
+	scale := config.Scale
+	if scale < 1 {
+		scale = defaultScale
+	}
+	pixels := netplay.Chip().Pixels
+	ebiten.SetWindowSize(len(pixels)*scale, len(pixels[0])*scale)
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	ebiten.SetFullscreen(config.Fullscreen)
+	ebiten.SetWindowTitle("Chip8 netplay")
+	ebiten.SetTPS(frameRateHz)
+	if err := ebiten.RunGame(game); err != nil && !errors.Is(err, ebiten.Termination) {
+		log.Fatal(err)
+	}
+	return game.err
+}
@@ -0,0 +1,72 @@
+package io
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+)
+
+// Layout of the original COSMAC VIP hex keypad
+var keypadLayout = [4][4]byte{
+	{0x1, 0x2, 0x3, 0xC},
+	{0x4, 0x5, 0x6, 0xD},
+	{0x7, 0x8, 0x9, 0xE},
+	{0xA, 0x0, 0xB, 0xF},
+}
+
+// rateMeter measures how many times per second something happens, averaged over one-second windows
+type rateMeter struct {
+	count       int
+	windowStart time.Time
+	rate        float64
+}
+
+func (m *rateMeter) add(n int, now time.Time) {
+	if m.windowStart.IsZero() {
+		m.windowStart = now
+	}
+	m.count += n
+	if elapsed := now.Sub(m.windowStart); elapsed >= time.Second {
+		m.rate = float64(m.count) / elapsed.Seconds()
+		m.count = 0
+		m.windowStart = now
+	}
+}
+
+// Builds the text of the debug overlay from the chip's current state
+func overlayText(c *chip8.Chip, instructionsPerSecond, fps float64) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "IPS: %.0f  FPS: %.1f\n", instructionsPerSecond, fps)
+	registers := c.Registers()
+	for i, v := range registers {
+		fmt.Fprintf(&b, "V%X:%02X", i, v)
+		if i%4 == 3 {
+			b.WriteString("\n")
+		} else {
+			b.WriteString(" ")
+		}
+	}
+	fmt.Fprintf(&b, "I:%03X PC:%03X SP:%d\n", c.IndexRegister(), c.ProgramCounter(), c.StackPointer())
+	fmt.Fprintf(&b, "DT:%02X ST:%02X\n", c.DelayTimerValue(), c.SoundTimerValue)
+	instruction := c.CurrentInstruction()
+	fmt.Fprintf(&b, "%04X  %s\n", instruction, chip8.Disassemble(instruction))
+
+	keys := c.Keys()
+	for _, row := range keypadLayout {
+		for i, key := range row {
+			if keys[key] {
+				fmt.Fprintf(&b, "%X", key)
+			} else {
+				b.WriteString(".")
+			}
+			if i < len(row)-1 {
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package io
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateMeter(t *testing.T) {
+	var meter rateMeter
+	start := time.Unix(1000, 0)
+	meter.add(0, start)
+	for i := 1; i <= 10; i++ {
+		meter.add(70, start.Add(time.Duration(i)*100*time.Millisecond))
+	}
+
+	if meter.rate != 700 {
+		t.Errorf("Incorrect rate %f", meter.rate)
+	}
+}
+
+func TestOverlayText(t *testing.T) {
+	c := newTestChip(t, []byte{0x6A, 0x42, 0xA2, 0x34})
+	c.ExecuteCycle()
+	var keys [16]bool
+	keys[0xC] = true
+	c.SetKeys(keys)
+
+	text := overlayText(c, 700, 60)
+
+	for _, expected := range []string{"IPS: 700", "VA:42", "PC:202", "A234  LD I, 0x234", ". . . C\n"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Overlay is missing %q:\n%s", expected, text)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package io
+
+import (
+	"fmt"
+	"math"
+)
+
+// Intensities below this would round to the background colour
+const minimumIntensity = 1.0 / 512
+
+type PersistenceMode int
+
+const (
+	PersistenceNone PersistenceMode = iota
+	// Each lit pixel fades out by a constant factor per emulated 60Hz frame
+	// after it is switched off, similar to the phosphor glow of a CRT
+	PersistenceDecay
+	// A pixel is drawn if it was lit in any of the last N emulated frames
+	PersistenceBlend
+)
+
+func ParsePersistenceMode(s string) (PersistenceMode, error) {
+	switch s {
+	case "none", "":
+		return PersistenceNone, nil
+	case "decay":
+		return PersistenceDecay, nil
+	case "blend":
+		return PersistenceBlend, nil
+	}
+	return PersistenceNone, fmt.Errorf("unknown persistence mode %q (expected none, decay or blend)", s)
+}
+
+/*
+persistenceFilter smooths out the flicker caused by games that erase and
+redraw their sprites with XOR on every frame. It works purely on the
+framebuffer and produces a per-pixel intensity in the range [0, 1], so it
+doesn't depend on the GPU at all.
+*/
+type persistenceFilter struct {
+	mode        PersistenceMode
+	decay       float64
+	blendFrames int
+	intensity   [][]float64
+	// Ring buffer of the last blendFrames frames, used by PersistenceBlend
+	history    [][][]bool
+	historyPos int
+	// Whether applying the same frame again would change the output, because
+	// pixels are still fading out or frames in the history differ from it
+	unsettled bool
+}
+
+func newPersistenceFilter(mode PersistenceMode, decay float64, blendFrames int) *persistenceFilter {
+	if decay < 0 {
+		decay = 0
+	} else if decay > 1 {
+		decay = 1
+	}
+	if blendFrames < 1 {
+		blendFrames = 1
+	}
+	return &persistenceFilter{
+		mode:        mode,
+		decay:       decay,
+		blendFrames: blendFrames,
+	}
+}
+
+/*
+Feeds the display into the filter after the given number of emulated 60Hz
+frames, which is 0 if the display changed within the frame the filter last
+saw. Fading follows the frames rather than how often the display is drawn,
+so it looks the same at any refresh rate. Returns the intensity of each
+pixel, along with whether any intensity differs from the previous call.
+*/
+func (f *persistenceFilter) apply(pixels [][]bool, frames int) ([][]float64, bool) {
+	f.resize(len(pixels), len(pixels[0]))
+
+	if f.mode == PersistenceBlend {
+		// Replaces the latest frame if no time has passed, and only the last
+		// len(history) frames can make a difference otherwise
+		if frames == 0 {
+			f.historyPos = (f.historyPos + len(f.history) - 1) % len(f.history)
+			frames = 1
+		} else if frames > len(f.history) {
+			frames = len(f.history)
+		}
+		for i := 0; i < frames; i++ {
+			frame := f.history[f.historyPos]
+			for x, column := range pixels {
+				copy(frame[x], column)
+			}
+			f.historyPos = (f.historyPos + 1) % len(f.history)
+		}
+	}
+	decay := math.Pow(f.decay, float64(frames))
+
+	changed := false
+	f.unsettled = false
+	for x, column := range f.intensity {
+		for y, previous := range column {
+			lit := pixels[x][y]
+			var next float64
+			switch {
+			case f.mode == PersistenceBlend:
+				// The history includes this frame, so lit pixels are always drawn
+				for _, frame := range f.history {
+					if frame[x][y] {
+						next = 1
+					}
+					if frame[x][y] != lit {
+						f.unsettled = true
+					}
+				}
+			case lit:
+				next = 1
+			case f.mode == PersistenceDecay:
+				next = previous * decay
+				// Stops fading once the pixel is too dim to show up
+				if next < minimumIntensity {
+					next = 0
+				}
+				if next > 0 {
+					f.unsettled = true
+				}
+			}
+			if next != previous {
+				column[y] = next
+				changed = true
+			}
+		}
+	}
+	return f.intensity, changed
+}
+
+// Reports whether feeding the last frame in again would change the output
+func (f *persistenceFilter) fading() bool {
+	return f.unsettled
+}
+
+func (f *persistenceFilter) reset() {
+	f.intensity = nil
+	f.history = nil
+	f.historyPos = 0
+	f.unsettled = false
+}
+
+// (Re)allocates the filter state whenever the framebuffer dimensions change
+func (f *persistenceFilter) resize(width, height int) {
+	if len(f.intensity) == width && len(f.intensity[0]) == height {
+		return
+	}
+	f.intensity = makeGrid[float64](width, height)
+	f.history = make([][][]bool, f.blendFrames)
+	for i := range f.history {
+		f.history[i] = makeGrid[bool](width, height)
+	}
+	f.historyPos = 0
+}
+
+func makeGrid[T any](width, height int) [][]T {
+	grid := make([][]T, width)
+	for i := range grid {
+		grid[i] = make([]T, height)
+	}
+	return grid
+}
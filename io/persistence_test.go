@@ -0,0 +1,111 @@
+package io
+
+import "testing"
+
+func TestPersistenceNone(t *testing.T) {
+	filter := newPersistenceFilter(PersistenceNone, 0.5, 2)
+	pixels := makeGrid[bool](4, 2)
+	pixels[1][1] = true
+	filter.apply(pixels, 1)
+
+	pixels[1][1] = false
+	intensity, _ := filter.apply(pixels, 1)
+
+	if intensity[1][1] != 0 {
+		t.Error("Pixel persisted with the filter disabled")
+	}
+}
+
+func TestPersistenceDecay(t *testing.T) {
+	filter := newPersistenceFilter(PersistenceDecay, 0.5, 0)
+	pixels := makeGrid[bool](4, 2)
+	pixels[2][0] = true
+	intensity, _ := filter.apply(pixels, 1)
+
+	if intensity[2][0] != 1 {
+		t.Error("Lit pixel was not at full intensity")
+	}
+
+	pixels[2][0] = false
+	intensity, _ = filter.apply(pixels, 1)
+
+	if intensity[2][0] != 0.5 {
+		t.Errorf("Pixel did not decay correctly, got %f", intensity[2][0])
+	}
+
+	intensity, _ = filter.apply(pixels, 1)
+
+	if intensity[2][0] != 0.25 {
+		t.Errorf("Pixel did not decay correctly, got %f", intensity[2][0])
+	}
+
+	pixels[2][0] = true
+	intensity, _ = filter.apply(pixels, 1)
+
+	if intensity[2][0] != 1 {
+		t.Error("Relit pixel was not restored to full intensity")
+	}
+}
+
+func TestPersistenceBlend(t *testing.T) {
+	filter := newPersistenceFilter(PersistenceBlend, 0, 2)
+	pixels := makeGrid[bool](4, 2)
+	pixels[3][1] = true
+	filter.apply(pixels, 1)
+
+	// Simulates a sprite being erased for a single frame before it is redrawn
+	pixels[3][1] = false
+	intensity, _ := filter.apply(pixels, 1)
+
+	if intensity[3][1] != 1 {
+		t.Error("Pixel flickered off while being blended")
+	}
+
+	intensity, _ = filter.apply(pixels, 1)
+
+	if intensity[3][1] != 0 {
+		t.Error("Pixel stayed on for longer than the blend window")
+	}
+}
+
+func TestPersistenceResize(t *testing.T) {
+	filter := newPersistenceFilter(PersistenceBlend, 0, 3)
+	filter.apply(makeGrid[bool](64, 32), 1)
+	intensity, _ := filter.apply(makeGrid[bool](128, 64), 1)
+
+	if len(intensity) != 128 || len(intensity[0]) != 64 {
+		t.Error("Filter state was not resized with the framebuffer")
+	}
+}
+
+func TestParsePersistenceMode(t *testing.T) {
+	if mode, err := ParsePersistenceMode("decay"); err != nil || mode != PersistenceDecay {
+		t.Error("Failed to parse decay mode")
+	}
+	if _, err := ParsePersistenceMode("bogus"); err == nil {
+		t.Error("Unknown mode was accepted")
+	}
+}
+
+func TestPersistenceChanged(t *testing.T) {
+	filter := newPersistenceFilter(PersistenceDecay, 0.1, 0)
+	pixels := makeGrid[bool](4, 2)
+	pixels[0][0] = true
+
+	if _, changed := filter.apply(pixels, 1); !changed {
+		t.Error("Lighting a pixel was not reported as a change")
+	}
+	if _, changed := filter.apply(pixels, 1); changed {
+		t.Error("Identical frame was reported as a change")
+	}
+
+	pixels[0][0] = false
+	for i := 0; i < 3; i++ {
+		if _, changed := filter.apply(pixels, 1); !changed {
+			t.Error("Fading pixel was not reported as a change")
+		}
+	}
+	if _, changed := filter.apply(pixels, 1); changed {
+		t.Error("Filter kept fading after the pixel went dark")
+	}
+}
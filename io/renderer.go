@@ -0,0 +1,94 @@
+package io
+
+import (
+	"image"
+	"image/color"
+)
+
+/*
+frameRenderer turns the chip's framebuffer into the image that gets
+uploaded to the GPU. The buffers are reused between frames, and the work
+is skipped entirely when neither the display nor the persistence filter's
+output has changed since the last frame.
+*/
+type frameRenderer struct {
+	persistence *persistenceFilter
+	scaleFilter ScaleFilter
+	foreground  color.RGBA
+	background  color.RGBA
+	frame       *image.RGBA
+	scaledFrame *image.RGBA
+	// Whether the chip has drawn to the display since the last render
+	dirty bool
+	// Emulated 60Hz frames run since the last render, which the persistence filter fades by
+	frames int
+	// Whether the persistence filter's output would still change without
+	// the display changing, in which case it needs to be run again
+	fading bool
+}
+
+func newFrameRenderer(persistence *persistenceFilter, scaleFilter ScaleFilter) *frameRenderer {
+	if scaleFilter == nil {
+		scaleFilter = nearestFilter{factor: 1}
+	}
+	return &frameRenderer{
+		persistence: persistence,
+		scaleFilter: scaleFilter,
+		foreground:  foregroundColor,
+		background:  backgroundColor,
+		dirty:       true,
+	}
+}
+
+func (r *frameRenderer) markDirty() {
+	r.dirty = true
+}
+
+// Records that the chip has run frames, whether or not they drew anything
+func (r *frameRenderer) addFrames(frames int) {
+	r.frames += frames
+}
+
+// Forgets the frames the persistence filter has seen, so nothing from before a reset lingers on screen
+func (r *frameRenderer) reset() {
+	r.persistence.reset()
+	r.fading = false
+	// The filter starts out dark, so it wouldn't report the lit pixels it
+	// forgot as a change. Dropping the frame makes the next render redraw it.
+	r.frame = nil
+}
+
+// Returns the image from the last render, which is what is on screen, or nil if nothing has been rendered yet
+func (r *frameRenderer) lastFrame() *image.RGBA {
+	return r.scaledFrame
+}
+
+/*
+Returns the scaled image of the framebuffer and whether it differs from the
+one returned by the previous call. The image is owned by the renderer and
+is overwritten by later calls.
+*/
+func (r *frameRenderer) render(pixels [][]bool) (*image.RGBA, bool) {
+	width, height := len(pixels), len(pixels[0])
+	resized := r.frame == nil || r.frame.Rect.Dx() != width || r.frame.Rect.Dy() != height
+	if resized {
+		r.frame = image.NewRGBA(image.Rect(0, 0, width, height))
+		r.scaledFrame = newScaledImage(r.scaleFilter, r.frame)
+	}
+
+	frames := r.frames
+	r.frames = 0
+	if !resized && !r.dirty && !(r.fading && frames > 0) {
+		return r.scaledFrame, false
+	}
+	r.dirty = false
+
+	intensity, changed := r.persistence.apply(pixels, frames)
+	r.fading = r.persistence.fading()
+	if !resized && !changed {
+		return r.scaledFrame, false
+	}
+	rasterize(r.frame, intensity, r.foreground, r.background)
+	r.scaleFilter.Scale(r.scaledFrame, r.frame)
+	return r.scaledFrame, true
+}
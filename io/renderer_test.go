@@ -0,0 +1,184 @@
+package io
+
+import (
+	"image"
+	"testing"
+)
+
+func TestRendererSkipsUnchangedFrames(t *testing.T) {
+	renderer := newFrameRenderer(newPersistenceFilter(PersistenceNone, 0, 0), nil)
+	pixels := makeGrid[bool](64, 32)
+
+	if _, changed := renderer.render(pixels); !changed {
+		t.Error("First frame was not rendered")
+	}
+	if _, changed := renderer.render(pixels); changed {
+		t.Error("Frame was rendered without the display changing")
+	}
+
+	pixels[5][5] = true
+	renderer.markDirty()
+	frame, changed := renderer.render(pixels)
+
+	if !changed || frame.RGBAAt(5, 5) != foregroundColor {
+		t.Error("Changed display was not rendered")
+	}
+	if _, changed := renderer.render(pixels); changed {
+		t.Error("Frame was rendered without the display changing")
+	}
+}
+
+func TestRendererKeepsRenderingWhileFading(t *testing.T) {
+	renderer := newFrameRenderer(newPersistenceFilter(PersistenceDecay, 0.5, 0), nil)
+	pixels := makeGrid[bool](64, 32)
+	pixels[0][0] = true
+	renderer.render(pixels)
+
+	pixels[0][0] = false
+	renderer.markDirty()
+	renderer.addFrames(1)
+	renderer.render(pixels)
+
+	if _, changed := renderer.render(pixels); changed {
+		t.Error("Pixel faded without a frame passing")
+	}
+	renderer.addFrames(1)
+	if _, changed := renderer.render(pixels); !changed {
+		t.Error("Fading pixel stopped being rendered")
+	}
+}
+
+func TestRendererFadesPerFrame(t *testing.T) {
+	// However often the display is drawn, the pixel fades by 0.5 per emulated frame
+	renderer := newFrameRenderer(newPersistenceFilter(PersistenceDecay, 0.5, 0), nil)
+	pixels := makeGrid[bool](64, 32)
+	pixels[0][0] = true
+	renderer.render(pixels)
+
+	pixels[0][0] = false
+	renderer.markDirty()
+	renderer.render(pixels)
+	renderer.render(pixels)
+	renderer.addFrames(2)
+	renderer.render(pixels)
+	renderer.render(pixels)
+
+	if intensity := renderer.persistence.intensity[0][0]; intensity != 0.25 {
+		t.Errorf("Pixel is at %f after two frames", intensity)
+	}
+}
+
+func TestRendererBlendFadesOut(t *testing.T) {
+	renderer := newFrameRenderer(newPersistenceFilter(PersistenceBlend, 0, 3), nil)
+	pixels := makeGrid[bool](64, 32)
+	pixels[5][5] = true
+	renderer.render(pixels)
+	renderer.addFrames(1)
+	renderer.render(pixels)
+
+	pixels[5][5] = false
+	renderer.markDirty()
+	for i := 0; i < 3; i++ {
+		renderer.addFrames(1)
+		renderer.render(pixels)
+	}
+	if frame := renderer.lastFrame(); frame.RGBAAt(5, 5) != backgroundColor {
+		t.Errorf("Erased pixel is still %v", frame.RGBAAt(5, 5))
+	}
+	if _, changed := renderer.render(pixels); changed {
+		t.Error("Frame was rendered after the pixel had faded out")
+	}
+}
+
+func TestRendererLastFrame(t *testing.T) {
+	renderer := newFrameRenderer(newPersistenceFilter(PersistenceNone, 0, 0), nil)
+	if renderer.lastFrame() != nil {
+		t.Error("Renderer has a frame before rendering")
+	}
+	pixels := makeGrid[bool](64, 32)
+	renderer.render(pixels)
+
+	pixels[5][5] = true
+	renderer.markDirty()
+	if frame := renderer.lastFrame(); frame == nil || frame.RGBAAt(5, 5) != backgroundColor {
+		t.Error("Last frame is not the one that was rendered")
+	}
+	if _, changed := renderer.render(pixels); !changed {
+		t.Error("Looking at the last frame used up the change")
+	}
+}
+
+func TestRendererReset(t *testing.T) {
+	renderer := newFrameRenderer(newPersistenceFilter(PersistenceBlend, 0, 4), nil)
+	pixels := makeGrid[bool](64, 32)
+	pixels[5][5] = true
+	renderer.render(pixels)
+
+	pixels[5][5] = false
+	renderer.reset()
+	frame, changed := renderer.render(pixels)
+	if !changed || frame.RGBAAt(5, 5) != backgroundColor {
+		t.Error("Frame from before the reset is still blended in")
+	}
+}
+
+func TestRendererResize(t *testing.T) {
+	renderer := newFrameRenderer(newPersistenceFilter(PersistenceNone, 0, 0), scale2xFilter{})
+	renderer.render(makeGrid[bool](64, 32))
+	frame, changed := renderer.render(makeGrid[bool](128, 64))
+
+	if !changed || frame.Rect.Size() != image.Pt(256, 128) {
+		t.Error("Renderer did not follow the framebuffer size")
+	}
+}
+
+func benchmarkPixels() [][]bool {
+	pixels := makeGrid[bool](64, 32)
+	for x := range pixels {
+		for y := range pixels[x] {
+			pixels[x][y] = (x+y)%3 == 0
+		}
+	}
+	return pixels
+}
+
+// The typical case, where most frames don't draw anything new
+func BenchmarkRenderUnchanged(b *testing.B) {
+	renderer := newFrameRenderer(newPersistenceFilter(PersistenceNone, 0, 0), nil)
+	pixels := benchmarkPixels()
+	renderer.render(pixels)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		renderer.render(pixels)
+	}
+}
+
+func BenchmarkRenderChanged(b *testing.B) {
+	renderer := newFrameRenderer(newPersistenceFilter(PersistenceNone, 0, 0), nil)
+	pixels := benchmarkPixels()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pixels[0][0] = !pixels[0][0]
+		renderer.markDirty()
+		renderer.render(pixels)
+	}
+}
+
+// Rasterizes a newly allocated frame every time, as a baseline for the renderer's
+// buffering. Only the CPU side is measured, since uploading a texture needs a display
+func BenchmarkRenderUnbuffered(b *testing.B) {
+	persistence := newPersistenceFilter(PersistenceNone, 0, 0)
+	pixels := benchmarkPixels()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		intensity, _ := persistence.apply(pixels, 1)
+		frame := image.NewRGBA(image.Rect(0, 0, len(pixels), len(pixels[0])))
+		rasterize(frame, intensity, foregroundColor, backgroundColor)
+	}
+}
@@ -0,0 +1,282 @@
+package io
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+/*
+ScaleFilter upscales the framebuffer on the CPU before it is uploaded to
+the GPU. Each filter multiplies both dimensions by a fixed factor, and the
+remaining scaling up to the window size is done with nearest-neighbour
+sampling when the result is drawn.
+*/
+type ScaleFilter interface {
+	Factor() int
+	// dst must be exactly Factor() times larger than src in both dimensions
+	Scale(dst, src *image.RGBA)
+}
+
+func ParseScaleFilter(name string) (ScaleFilter, error) {
+	switch name {
+	case "nearest", "":
+		return nearestFilter{factor: 1}, nil
+	case "scale2x", "epx":
+		return scale2xFilter{}, nil
+	case "scale3x":
+		return scale3xFilter{}, nil
+	case "hq2x":
+		return hq2xFilter{}, nil
+	case "scanlines":
+		return overlayFilter{factor: 4, horizontal: true}, nil
+	case "dotmatrix":
+		return overlayFilter{factor: 4, horizontal: true, vertical: true}, nil
+	}
+	return nil, fmt.Errorf("unknown scale filter %q (expected nearest, scale2x, scale3x, hq2x, scanlines or dotmatrix)", name)
+}
+
+func newScaledImage(filter ScaleFilter, src *image.RGBA) *image.RGBA {
+	size := src.Rect.Size().Mul(filter.Factor())
+	return image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+}
+
+type nearestFilter struct {
+	factor int
+}
+
+func (f nearestFilter) Factor() int {
+	return f.factor
+}
+
+func (f nearestFilter) Scale(dst, src *image.RGBA) {
+	if f.factor == 1 {
+		copy(dst.Pix, src.Pix)
+		return
+	}
+	bounds := src.Rect
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := src.RGBAAt(x, y)
+			for j := 0; j < f.factor; j++ {
+				for i := 0; i < f.factor; i++ {
+					dst.SetRGBA(x*f.factor+i, y*f.factor+j, c)
+				}
+			}
+		}
+	}
+}
+
+// Returns the pixel at (x, y), clamping coordinates that fall outside the image
+func clampedAt(src *image.RGBA, x, y int) color.RGBA {
+	bounds := src.Rect
+	if x < bounds.Min.X {
+		x = bounds.Min.X
+	} else if x >= bounds.Max.X {
+		x = bounds.Max.X - 1
+	}
+	if y < bounds.Min.Y {
+		y = bounds.Min.Y
+	} else if y >= bounds.Max.Y {
+		y = bounds.Max.Y - 1
+	}
+	return src.RGBAAt(x, y)
+}
+
+/*
+scale2xFilter implements the Scale2x (also known as EPX) algorithm. Using
+the neighbourhood
+
+	  A
+	C P B
+	  D
+
+P is expanded into four pixels, each of which takes the colour of the two
+neighbours it touches if those agree with each other and the opposite
+neighbours don't.
+*/
+type scale2xFilter struct{}
+
+func (scale2xFilter) Factor() int {
+	return 2
+}
+
+func (scale2xFilter) Scale(dst, src *image.RGBA) {
+	bounds := src.Rect
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			p := src.RGBAAt(x, y)
+			a := clampedAt(src, x, y-1)
+			b := clampedAt(src, x+1, y)
+			c := clampedAt(src, x-1, y)
+			d := clampedAt(src, x, y+1)
+
+			out := [4]color.RGBA{p, p, p, p}
+			if c == a && c != d && a != b {
+				out[0] = a
+			}
+			if a == b && a != c && b != d {
+				out[1] = b
+			}
+			if d == c && d != b && c != a {
+				out[2] = c
+			}
+			if b == d && b != a && d != c {
+				out[3] = d
+			}
+
+			dst.SetRGBA(2*x, 2*y, out[0])
+			dst.SetRGBA(2*x+1, 2*y, out[1])
+			dst.SetRGBA(2*x, 2*y+1, out[2])
+			dst.SetRGBA(2*x+1, 2*y+1, out[3])
+		}
+	}
+}
+
+/*
+scale3xFilter implements the Scale3x algorithm, which uses the full 3x3
+neighbourhood
+
+	A B C
+	D E F
+	G H I
+
+to expand E into nine pixels.
+*/
+type scale3xFilter struct{}
+
+func (scale3xFilter) Factor() int {
+	return 3
+}
+
+func (scale3xFilter) Scale(dst, src *image.RGBA) {
+	bounds := src.Rect
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			a := clampedAt(src, x-1, y-1)
+			b := clampedAt(src, x, y-1)
+			c := clampedAt(src, x+1, y-1)
+			d := clampedAt(src, x-1, y)
+			e := src.RGBAAt(x, y)
+			f := clampedAt(src, x+1, y)
+			g := clampedAt(src, x-1, y+1)
+			h := clampedAt(src, x, y+1)
+			i := clampedAt(src, x+1, y+1)
+
+			out := [9]color.RGBA{e, e, e, e, e, e, e, e, e}
+			if b != h && d != f {
+				if d == b {
+					out[0] = d
+				}
+				if (d == b && e != c) || (b == f && e != a) {
+					out[1] = b
+				}
+				if b == f {
+					out[2] = f
+				}
+				if (d == b && e != g) || (d == h && e != a) {
+					out[3] = d
+				}
+				if (b == f && e != i) || (h == f && e != c) {
+					out[5] = f
+				}
+				if d == h {
+					out[6] = d
+				}
+				if (d == h && e != i) || (h == f && e != g) {
+					out[7] = h
+				}
+				if h == f {
+					out[8] = f
+				}
+			}
+
+			for j := 0; j < 9; j++ {
+				dst.SetRGBA(3*x+j%3, 3*y+j/3, out[j])
+			}
+		}
+	}
+}
+
+/*
+hq2xFilter is a simplified take on hq2x. It detects edges the same way as
+Scale2x, but instead of copying the neighbouring colour it blends it with
+the centre pixel, which gives diagonals a softer, anti-aliased look without
+needing hq2x's full lookup table.
+*/
+type hq2xFilter struct{}
+
+func (hq2xFilter) Factor() int {
+	return 2
+}
+
+func (hq2xFilter) Scale(dst, src *image.RGBA) {
+	bounds := src.Rect
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			p := src.RGBAAt(x, y)
+			a := clampedAt(src, x, y-1)
+			b := clampedAt(src, x+1, y)
+			c := clampedAt(src, x-1, y)
+			d := clampedAt(src, x, y+1)
+
+			out := [4]color.RGBA{p, p, p, p}
+			if c == a && c != d && a != b {
+				out[0] = blend(a, p, 3)
+			}
+			if a == b && a != c && b != d {
+				out[1] = blend(b, p, 3)
+			}
+			if d == c && d != b && c != a {
+				out[2] = blend(c, p, 3)
+			}
+			if b == d && b != a && d != c {
+				out[3] = blend(d, p, 3)
+			}
+
+			dst.SetRGBA(2*x, 2*y, out[0])
+			dst.SetRGBA(2*x+1, 2*y, out[1])
+			dst.SetRGBA(2*x, 2*y+1, out[2])
+			dst.SetRGBA(2*x+1, 2*y+1, out[3])
+		}
+	}
+}
+
+// Mixes weight parts of x with one part of y
+func blend(x, y color.RGBA, weight int) color.RGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8((int(a)*weight + int(b)) / (weight + 1))
+	}
+	return color.RGBA{mix(x.R, y.R), mix(x.G, y.G), mix(x.B, y.B), mix(x.A, y.A)}
+}
+
+/*
+overlayFilter scales up with nearest-neighbour sampling and then darkens
+the last row (and optionally column) of every block, imitating CRT
+scanlines or the grid of a dot-matrix LCD.
+*/
+type overlayFilter struct {
+	factor     int
+	horizontal bool
+	vertical   bool
+}
+
+func (f overlayFilter) Factor() int {
+	return f.factor
+}
+
+func (f overlayFilter) Scale(dst, src *image.RGBA) {
+	nearestFilter{factor: f.factor}.Scale(dst, src)
+
+	bounds := dst.Rect
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			onRow := f.horizontal && y%f.factor == f.factor-1
+			onColumn := f.vertical && x%f.factor == f.factor-1
+			if onRow || onColumn {
+				c := dst.RGBAAt(x, y)
+				dst.SetRGBA(x, y, color.RGBA{c.R / 3, c.G / 3, c.B / 3, c.A})
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package io
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+var (
+	testOn  = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	testOff = color.RGBA{0x00, 0x00, 0x00, 0xff}
+)
+
+// Builds an image from rows of '#' (on) and '.' (off) characters
+func imageFromRows(rows ...string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, c := range row {
+			if c == '#' {
+				img.SetRGBA(x, y, testOn)
+			} else {
+				img.SetRGBA(x, y, testOff)
+			}
+		}
+	}
+	return img
+}
+
+func assertImageRows(t *testing.T, img *image.RGBA, rows ...string) {
+	t.Helper()
+	if img.Rect.Dx() != len(rows[0]) || img.Rect.Dy() != len(rows) {
+		t.Fatalf("Image has wrong size %v", img.Rect.Size())
+	}
+	for y, row := range rows {
+		for x, c := range row {
+			expected := testOff
+			if c == '#' {
+				expected = testOn
+			}
+			if img.RGBAAt(x, y) != expected {
+				t.Errorf("Pixel (%d, %d) is %v, expected %v", x, y, img.RGBAAt(x, y), expected)
+			}
+		}
+	}
+}
+
+func scaleWith(filter ScaleFilter, src *image.RGBA) *image.RGBA {
+	dst := newScaledImage(filter, src)
+	filter.Scale(dst, src)
+	return dst
+}
+
+func TestNearestFilter(t *testing.T) {
+	src := imageFromRows(
+		"#.",
+		".#",
+	)
+	dst := scaleWith(nearestFilter{factor: 2}, src)
+
+	assertImageRows(t, dst,
+		"##..",
+		"##..",
+		"..##",
+		"..##",
+	)
+}
+
+func TestScale2xFilter(t *testing.T) {
+	src := imageFromRows(
+		"....",
+		".#..",
+		".##.",
+		"....",
+	)
+	dst := scaleWith(scale2xFilter{}, src)
+
+	// The staircase is smoothed into a diagonal
+	assertImageRows(t, dst,
+		"........",
+		"........",
+		"..##....",
+		"..###...",
+		"..####..",
+		"...###..",
+		"........",
+		"........",
+	)
+}
+
+func TestScale3xFilter(t *testing.T) {
+	src := imageFromRows(
+		"....",
+		".#..",
+		".##.",
+		"....",
+	)
+	dst := scaleWith(scale3xFilter{}, src)
+
+	assertImageRows(t, dst,
+		"............",
+		"............",
+		"............",
+		"...###......",
+		"...###......",
+		"...####.....",
+		"...######...",
+		"....#####...",
+		".....####...",
+		"............",
+		"............",
+		"............",
+	)
+}
+
+func TestHq2xFilterBlendsEdges(t *testing.T) {
+	src := imageFromRows(
+		"....",
+		".#..",
+		".##.",
+		"....",
+	)
+	dst := scaleWith(hq2xFilter{}, src)
+
+	edge := dst.RGBAAt(4, 3)
+	if edge == testOn || edge == testOff {
+		t.Errorf("Diagonal edge was not blended, got %v", edge)
+	}
+	if dst.RGBAAt(2, 2) != testOn || dst.RGBAAt(0, 0) != testOff {
+		t.Error("Flat areas were modified")
+	}
+}
+
+func TestOverlayFilter(t *testing.T) {
+	src := imageFromRows("#")
+	dst := scaleWith(overlayFilter{factor: 2, horizontal: true, vertical: true}, src)
+
+	if dst.RGBAAt(0, 0) != testOn {
+		t.Error("Pixel body was darkened")
+	}
+	if dst.RGBAAt(0, 1) == testOn || dst.RGBAAt(1, 0) == testOn {
+		t.Error("Grid lines were not darkened")
+	}
+}
+
+func TestParseScaleFilter(t *testing.T) {
+	for _, name := range []string{"nearest", "scale2x", "epx", "scale3x", "hq2x", "scanlines", "dotmatrix"} {
+		if _, err := ParseScaleFilter(name); err != nil {
+			t.Errorf("Failed to parse filter %s", name)
+		}
+	}
+	if _, err := ParseScaleFilter("bogus"); err == nil {
+		t.Error("Unknown filter was accepted")
+	}
+}
@@ -0,0 +1,25 @@
+package io
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Saves img as a timestamped PNG file in dir and returns the path it was written to
+func saveScreenshot(img image.Image, dir string, now time.Time) (string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("chip8-%s.png", now.Format("20060102-150405.000")))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, img); err != nil {
+		return "", err
+	}
+	return path, file.Close()
+}
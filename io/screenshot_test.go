@@ -0,0 +1,37 @@
+package io
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSaveScreenshot(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	img.SetRGBA(3, 4, foregroundColor)
+
+	path, err := saveScreenshot(img, t.TempDir(), time.Unix(1000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	saved, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if saved.Bounds() != img.Bounds() {
+		t.Error("Screenshot has the wrong size")
+	}
+	r, g, b, _ := saved.At(3, 4).RGBA()
+	if uint8(r>>8) != foregroundColor.R || uint8(g>>8) != foregroundColor.G || uint8(b>>8) != foregroundColor.B {
+		t.Error("Screenshot has the wrong contents")
+	}
+}
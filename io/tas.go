@@ -0,0 +1,318 @@
+package io
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+)
+
+const (
+	// Frames between the save states kept for seeking backwards
+	tasStateInterval = 30
+	tasBranches      = 8
+
+	// The piano roll panel is laid out on a grid of character cells like the debugger
+	pianoRollColumns  = 60
+	pianoRollRows     = 36
+	pianoRollFirstRow = 6
+	pianoRollLines    = pianoRollRows - pianoRollFirstRow
+	// Each key, and the reset flag after them, is a cell three characters wide
+	pianoRollKeyColumn = 7
+	pianoRollCellWidth = 3
+	pianoRollResetCell = 16
+)
+
+type tasMode int
+
+const (
+	// Frames are played back from the movie, which can't be changed
+	tasReadOnly tasMode = iota
+	// Keys held on the keyboard are added to each frame as it's played,
+	// and the piano roll can change any frame
+	tasRecord
+)
+
+func (mode tasMode) String() string {
+	if mode == tasRecord {
+		return "RECORD"
+	}
+	return "READ-ONLY"
+}
+
+// A save state along with the movie's frames when it was saved
+type tasBranch struct {
+	frame  int
+	state  *chip8.State
+	frames []chip8.MovieFrame
+}
+
+/*
+tasSession edits a movie one frame at a time. The chip is always at the
+start of frame, having run every frame before it, so going back means
+loading the nearest earlier save state and running forward from it.
+Changing a frame that has already been run does that automatically, so
+the chip and the checksums in the movie always match its frames.
+*/
+type tasSession struct {
+	chip  *chip8.Chip
+	movie *chip8.Movie
+	mode  tasMode
+	// Instructions in each frame added to the movie
+	cyclesPerFrame int
+	frame          int
+	// Save states taken at the start of every tasStateInterval'th frame
+	states   map[int]*chip8.State
+	branches [tasBranches]*tasBranch
+	// First frame shown in the piano roll
+	scroll int
+}
+
+// The chip must have just been created for the movie, and not have run any of its frames
+func newTASSession(chip *chip8.Chip, movie *chip8.Movie, mode tasMode, cyclesPerFrame int) (*tasSession, error) {
+	state, err := chip.SaveState()
+	if err != nil {
+		return nil, err
+	}
+	if cyclesPerFrame < 1 {
+		cyclesPerFrame = 1
+	}
+	return &tasSession{
+		chip:           chip,
+		movie:          movie,
+		mode:           mode,
+		cyclesPerFrame: cyclesPerFrame,
+		states:         map[int]*chip8.State{0: state},
+	}, nil
+}
+
+func (s *tasSession) toggleMode() {
+	if s.mode == tasRecord {
+		s.mode = tasReadOnly
+	} else {
+		s.mode = tasRecord
+	}
+}
+
+/*
+Runs the current frame. In record mode the keys are added to the frame,
+and a new frame is added at the end of the movie. Returns whether the
+screen was updated, and false for ok at the end of the movie in read-only
+mode.
+*/
+func (s *tasSession) advance(keys [16]bool) (screenUpdated, ok bool) {
+	if s.mode == tasReadOnly && s.frame >= len(s.movie.Frames) {
+		return false, false
+	}
+	if s.mode == tasRecord {
+		s.extend(s.frame)
+		input := &s.movie.Frames[s.frame]
+		for i, pressed := range keys {
+			if pressed && input.Keys&(1<<i) == 0 {
+				input.Keys |= 1 << i
+				s.dropStates(s.frame)
+			}
+		}
+	}
+	return s.runFrame(), true
+}
+
+func (s *tasSession) runFrame() bool {
+	if s.frame%tasStateInterval == 0 {
+		// Saving can't fail, since newTASSession already saved a state
+		s.states[s.frame], _ = s.chip.SaveState()
+	}
+	screenUpdated := s.movie.RerecordFrame(s.chip, s.frame)
+	s.frame++
+	s.follow()
+	return screenUpdated
+}
+
+// Adds empty frames to the end of the movie until it has the frame
+func (s *tasSession) extend(frame int) {
+	for len(s.movie.Frames) <= frame {
+		s.movie.Frames = append(s.movie.Frames, chip8.MovieFrame{Cycles: s.cyclesPerFrame})
+	}
+}
+
+// Moves to the start of the frame, which can't be past the end of the movie
+func (s *tasSession) seek(frame int) {
+	if frame > len(s.movie.Frames) {
+		frame = len(s.movie.Frames)
+	}
+	if frame < 0 {
+		frame = 0
+	}
+	if frame < s.frame {
+		// Editing frames throws states away, but the one for frame 0 is always kept
+		start := frame - frame%tasStateInterval
+		for s.states[start] == nil {
+			start -= tasStateInterval
+		}
+		s.chip.LoadState(s.states[start])
+		s.frame = start
+	}
+	for s.frame < frame {
+		s.runFrame()
+	}
+	s.follow()
+}
+
+/*
+Toggles a key, or the reset flag for pianoRollResetCell, in a frame of
+the movie. If the frame has already been run, the frames after it are run
+again so that the chip stays at the current frame.
+*/
+func (s *tasSession) toggle(frame, cell int) error {
+	if s.mode == tasReadOnly {
+		return errors.New("switch to record mode to edit the movie")
+	}
+	s.extend(frame)
+	if cell == pianoRollResetCell {
+		s.movie.Frames[frame].Reset = !s.movie.Frames[frame].Reset
+	} else {
+		s.movie.Frames[frame].Keys ^= 1 << cell
+	}
+	s.changedFrom(frame)
+	return nil
+}
+
+// Runs the frames from the changed one up to the current frame again
+func (s *tasSession) changedFrom(frame int) {
+	s.dropStates(frame)
+	if frame < s.frame {
+		current := s.frame
+		s.seek(frame)
+		s.seek(current)
+	}
+}
+
+func (s *tasSession) saveBranch(slot int) {
+	state, _ := s.chip.SaveState()
+	s.branches[slot] = &tasBranch{
+		frame:  s.frame,
+		state:  state,
+		frames: append([]chip8.MovieFrame(nil), s.movie.Frames...),
+	}
+}
+
+/*
+Loads a branch. In record mode the movie's frames are replaced with the
+ones saved with the branch, but in read-only mode the movie is kept and
+an error is returned if the branch's frames up to its state don't match
+it, since the chip will no longer be in step with the movie.
+*/
+func (s *tasSession) loadBranch(slot int) error {
+	branch := s.branches[slot]
+	if branch == nil {
+		return fmt.Errorf("branch %d is empty", slot+1)
+	}
+	var mismatch error
+	changed := branch.frame
+	if s.mode == tasRecord {
+		changed = firstDifference(s.movie.Frames, branch.frames)
+		s.movie.Frames = append([]chip8.MovieFrame(nil), branch.frames...)
+	} else if firstDifference(s.movie.Frames, branch.frames[:branch.frame]) < branch.frame {
+		mismatch = fmt.Errorf("branch %d doesn't match the movie", slot+1)
+	}
+
+	s.dropStates(changed)
+	s.chip.LoadState(branch.state)
+	s.frame = branch.frame
+	s.follow()
+	return mismatch
+}
+
+// Throws away the save states that depend on the frame, which are the ones taken after it
+func (s *tasSession) dropStates(frame int) {
+	for start := range s.states {
+		if start > frame {
+			delete(s.states, start)
+		}
+	}
+}
+
+// Returns the index of the first frame whose input differs, ignoring checksums
+func firstDifference(a, b []chip8.MovieFrame) int {
+	i := 0
+	for ; i < len(a) && i < len(b); i++ {
+		if a[i].Keys != b[i].Keys || a[i].Cycles != b[i].Cycles || a[i].Reset != b[i].Reset {
+			break
+		}
+	}
+	return i
+}
+
+// Scrolls the piano roll to keep the current frame in view
+func (s *tasSession) follow() {
+	if s.frame < s.scroll || s.frame >= s.scroll+pianoRollLines {
+		s.scroll = s.frame - pianoRollLines/2
+	}
+	s.scrollBy(0)
+}
+
+func (s *tasSession) scrollBy(frames int) {
+	s.scroll += frames
+	if s.scroll < 0 {
+		s.scroll = 0
+	}
+}
+
+// Lays out the piano roll panel, with the current frame highlighted
+func (s *tasSession) layout() []debugItem {
+	items := []debugItem{
+		{text: fmt.Sprintf("%s  frame %d of %d", s.mode, s.frame, len(s.movie.Frames))},
+	}
+	branches := "Branches"
+	for slot, branch := range s.branches {
+		if branch != nil {
+			branches += fmt.Sprintf(" %d@%d", slot+1, branch.frame)
+		}
+	}
+	items = append(items,
+		debugItem{text: branches, row: 1},
+		debugItem{text: "P play/pause  . advance  , back  M mode  F9 save", row: 2},
+		debugItem{text: "F1-F8 load a branch, with Shift to save one", row: 3},
+	)
+
+	header := "Frame  "
+	for key := 0; key < 16; key++ {
+		header += fmt.Sprintf(" %X ", key)
+	}
+	items = append(items, debugItem{text: header + " R ", row: pianoRollFirstRow - 1})
+
+	for line := 0; line < pianoRollLines; line++ {
+		frame := s.scroll + line
+		row := pianoRollFirstRow + line
+		var input chip8.MovieFrame
+		if frame < len(s.movie.Frames) {
+			input = s.movie.Frames[frame]
+		}
+		items = append(items, debugItem{text: fmt.Sprintf("%6d", frame), row: row, highlighted: frame == s.frame})
+		for key := 0; key < 16; key++ {
+			text := " . "
+			if input.Keys&(1<<key) != 0 {
+				text = fmt.Sprintf(" %X ", key)
+			}
+			items = append(items, debugItem{text: text, column: pianoRollKeyColumn + key*pianoRollCellWidth, row: row, highlighted: frame == s.frame})
+		}
+		text := " . "
+		if input.Reset {
+			text = " R "
+		}
+		items = append(items, debugItem{text: text, column: pianoRollKeyColumn + pianoRollResetCell*pianoRollCellWidth, row: row, highlighted: frame == s.frame})
+	}
+	return items
+}
+
+// Returns the frame and cell under a character cell of the panel, or false if there isn't one
+func (s *tasSession) cellAt(column, row int) (frame, cell int, ok bool) {
+	if row < pianoRollFirstRow || row >= pianoRollRows || column < pianoRollKeyColumn {
+		return 0, 0, false
+	}
+	cell = (column - pianoRollKeyColumn) / pianoRollCellWidth
+	if cell > pianoRollResetCell {
+		return 0, 0, false
+	}
+	return s.scroll + row - pianoRollFirstRow, cell, true
+}
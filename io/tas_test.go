@@ -0,0 +1,187 @@
+package io
+
+import (
+	"testing"
+
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+)
+
+// Adds random numbers to V2 while key 0 is held, and draws with it
+var tasROM = []byte{
+	0xC0, 0xFF, // 200: RND V0, 0xFF
+	0xE1, 0xA1, // 202: SKNP V1
+	0x82, 0x04, // 204: ADD V2, V0
+	0xD2, 0x03, // 206: DRW V2, V0, 3
+	0x12, 0x00, // 208: JP 0x200
+}
+
+func newTestTASSession(t *testing.T, mode tasMode) *tasSession {
+	t.Helper()
+	chip, movie, err := chip8.RecordMovie(tasROM, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newTASSession(chip, movie, mode, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func recordFrames(s *tasSession, frames int) {
+	for i := 0; i < frames; i++ {
+		var keys [16]bool
+		keys[0] = i%4 == 0
+		s.advance(keys)
+	}
+}
+
+// Returns the checksum a fresh chip has after replaying the whole movie
+func replayedChecksum(t *testing.T, movie *chip8.Movie) uint32 {
+	t.Helper()
+	chip, err := movie.NewChip(tasROM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := movie.Replay(chip); err != nil {
+		t.Fatal(err)
+	}
+	return chip.StateChecksum()
+}
+
+func TestTASRecordAndSeek(t *testing.T) {
+	s := newTestTASSession(t, tasRecord)
+	recordFrames(s, 100)
+	if s.frame != 100 || len(s.movie.Frames) != 100 || s.movie.Frames[4].Keys != 1 || s.movie.Frames[5].Keys != 0 {
+		t.Fatalf("Recorded %d frames, at frame %d", len(s.movie.Frames), s.frame)
+	}
+	end := s.chip.StateChecksum()
+	if end != replayedChecksum(t, s.movie) {
+		t.Error("Replaying the movie doesn't end in the same state")
+	}
+
+	s.seek(37)
+	if s.frame != 37 {
+		t.Errorf("Seeking went to frame %d", s.frame)
+	}
+	s.seek(100)
+	if s.chip.StateChecksum() != end {
+		t.Error("Seeking backwards and forwards changed the state")
+	}
+
+	// Rewinding and advancing in record mode without holding any keys keeps the frames
+	s.seek(90)
+	for s.frame < 100 {
+		s.advance([16]bool{})
+	}
+	if s.chip.StateChecksum() != end || len(s.movie.Frames) != 100 {
+		t.Error("Advancing over recorded frames changed them")
+	}
+}
+
+func TestTASToggleReplaysLaterFrames(t *testing.T) {
+	s := newTestTASSession(t, tasRecord)
+	recordFrames(s, 100)
+	s.seek(80)
+	if err := s.toggle(5, 0); err != nil {
+		t.Fatal(err)
+	}
+	if s.frame != 80 || s.movie.Frames[5].Keys != 1 {
+		t.Fatalf("Toggling moved to frame %d, with keys %04X", s.frame, s.movie.Frames[5].Keys)
+	}
+	s.seek(100)
+	if s.chip.StateChecksum() != replayedChecksum(t, s.movie) {
+		t.Error("State after editing a frame doesn't match a replay of the edited movie")
+	}
+
+	if err := s.toggle(120, pianoRollResetCell); err != nil || len(s.movie.Frames) != 121 || !s.movie.Frames[120].Reset {
+		t.Errorf("Toggling the reset flag past the end of the movie returned %v", err)
+	}
+}
+
+func TestTASReadOnly(t *testing.T) {
+	s := newTestTASSession(t, tasRecord)
+	recordFrames(s, 20)
+	s.seek(0)
+	s.toggleMode()
+
+	if err := s.toggle(3, 0); err == nil {
+		t.Error("Edited a frame in read-only mode")
+	}
+	var keys [16]bool
+	keys[5] = true
+	for i := 0; i < 20; i++ {
+		if _, ok := s.advance(keys); !ok {
+			t.Fatalf("Playback stopped at frame %d", s.frame)
+		}
+	}
+	if _, ok := s.advance(keys); ok {
+		t.Error("Played past the end of the movie")
+	}
+	for _, frame := range s.movie.Frames {
+		if frame.Keys&(1<<5) != 0 {
+			t.Fatal("Keys were recorded in read-only mode")
+		}
+	}
+}
+
+func TestTASBranches(t *testing.T) {
+	s := newTestTASSession(t, tasRecord)
+	recordFrames(s, 50)
+	s.saveBranch(0)
+	branchState := s.chip.StateChecksum()
+
+	s.seek(20)
+	s.toggle(25, 0)
+	s.seek(50)
+	if s.chip.StateChecksum() == branchState {
+		t.Fatal("Editing a frame didn't change the state")
+	}
+
+	// In read-only mode the edited movie is kept, but doesn't match the branch
+	s.toggleMode()
+	if err := s.loadBranch(0); err == nil {
+		t.Error("Loading a branch that doesn't match the movie in read-only mode succeeded")
+	}
+	if s.movie.Frames[25].Keys&1 == 0 {
+		t.Error("Loading a branch in read-only mode changed the movie")
+	}
+
+	s.toggleMode()
+	if err := s.loadBranch(0); err != nil {
+		t.Fatal(err)
+	}
+	if s.frame != 50 || s.chip.StateChecksum() != branchState || s.movie.Frames[25].Keys&1 != 0 {
+		t.Error("Loading a branch in record mode didn't restore its state and frames")
+	}
+	s.seek(30)
+	s.seek(50)
+	if s.chip.StateChecksum() != branchState {
+		t.Error("Seeking after loading a branch used states from the edited movie")
+	}
+
+	if err := s.loadBranch(1); err == nil {
+		t.Error("Loaded an empty branch")
+	}
+}
+
+func TestPianoRollCells(t *testing.T) {
+	s := newTestTASSession(t, tasRecord)
+	recordFrames(s, 100)
+	s.seek(60)
+	items := s.layout()
+	if item, ok := findItem(items, "60"); !ok || !item.highlighted {
+		t.Error("Current frame isn't highlighted")
+	}
+
+	frame, cell, ok := s.cellAt(pianoRollKeyColumn+2*pianoRollCellWidth+1, pianoRollFirstRow+3)
+	if !ok || frame != s.scroll+3 || cell != 2 {
+		t.Errorf("Cell is key %d of frame %d", cell, frame)
+	}
+	if _, cell, _ := s.cellAt(pianoRollKeyColumn+pianoRollResetCell*pianoRollCellWidth, pianoRollFirstRow); cell != pianoRollResetCell {
+		t.Error("Reset column isn't a cell")
+	}
+	if _, _, ok := s.cellAt(2, pianoRollFirstRow); ok {
+		t.Error("Frame number is a cell")
+	}
+}
@@ -0,0 +1,207 @@
+package io
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"log"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+)
+
+const pianoRollWidth = pianoRollColumns * charWidth
+
+var branchKeys = [tasBranches]ebiten.Key{
+	ebiten.KeyF1, ebiten.KeyF2, ebiten.KeyF3, ebiten.KeyF4,
+	ebiten.KeyF5, ebiten.KeyF6, ebiten.KeyF7, ebiten.KeyF8,
+}
+
+/*
+tasGame edits a movie frame by frame, with the piano roll next to the
+game. The chip is run on the game loop rather than by a Runner, since
+every frame has to be under the session's control.
+*/
+type tasGame struct {
+	session  *tasSession
+	renderer *frameRenderer
+	texture  *ebiten.Image
+	paused   bool
+	toast    toast
+	save     func() error
+	// Width of the game next to the piano roll, as of the last draw
+	gameWidth int
+	keyHints  map[string]byte
+}
+
+func (g *tasGame) Update() error {
+	now := time.Now()
+	s := g.session
+	frame := s.frame
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		return ebiten.Termination
+	case inpututil.IsKeyJustPressed(ebiten.KeyP):
+		g.paused = !g.paused
+	case inpututil.IsKeyJustPressed(ebiten.KeyM):
+		s.toggleMode()
+		g.toast.show(fmt.Sprintf("%s mode", s.mode), now)
+	case inpututil.IsKeyJustPressed(ebiten.KeyComma):
+		g.paused = true
+		s.seek(s.frame - 1)
+	case inpututil.IsKeyJustPressed(ebiten.KeyF9):
+		if err := g.save(); err != nil {
+			g.toast.show(fmt.Sprintf("Saving failed: %v", err), now)
+		} else {
+			g.toast.show(fmt.Sprintf("Saved %d frames", len(s.movie.Frames)), now)
+		}
+	}
+	for slot, key := range branchKeys {
+		if !inpututil.IsKeyJustPressed(key) {
+			continue
+		}
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			s.saveBranch(slot)
+			g.toast.show(fmt.Sprintf("Saved branch %d at frame %d", slot+1, s.frame), now)
+		} else if err := s.loadBranch(slot); err != nil {
+			g.toast.show(err.Error(), now)
+		} else {
+			g.toast.show(fmt.Sprintf("Loaded branch %d", slot+1), now)
+		}
+		// Loading a branch can change the movie without changing the frame
+		g.renderer.markDirty()
+	}
+	g.updatePianoRoll(now)
+
+	if !g.paused || inpututil.IsKeyJustPressed(ebiten.KeyPeriod) {
+		if _, ok := s.advance(getKeyPresses(g.keyHints)); !ok && !g.paused {
+			g.paused = true
+			g.toast.show("End of the movie", now)
+		}
+	}
+	if s.frame != frame {
+		g.renderer.markDirty()
+		// Seeking backwards only redraws, since no time passes for the filter
+		if s.frame > frame {
+			g.renderer.addFrames(s.frame - frame)
+		}
+	}
+	return nil
+}
+
+func (g *tasGame) updatePianoRoll(now time.Time) {
+	if _, dy := ebiten.Wheel(); dy > 0 {
+		g.session.scrollBy(-4)
+	} else if dy < 0 {
+		g.session.scrollBy(4)
+	}
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return
+	}
+	x, y := ebiten.CursorPosition()
+	if x < g.gameWidth {
+		return
+	}
+	if frame, cell, ok := g.session.cellAt((x-g.gameWidth)/charWidth, y/lineHeight); ok {
+		if err := g.session.toggle(frame, cell); err != nil {
+			g.toast.show(err.Error(), now)
+		}
+		g.renderer.markDirty()
+	}
+}
+
+func (g *tasGame) Draw(screen *ebiten.Image) {
+	frame, changed := g.renderer.render(g.session.chip.Pixels)
+	frameSize := frame.Rect.Size()
+	if g.texture == nil || g.texture.Bounds().Size() != frameSize {
+		if g.texture != nil {
+			g.texture.Dispose()
+		}
+		g.texture = ebiten.NewImage(frameSize.X, frameSize.Y)
+		changed = true
+	}
+	if changed {
+		g.texture.WritePixels(frame.Pix)
+	}
+
+	size := screen.Bounds().Size()
+	gameWidth := size.X - pianoRollWidth
+	g.gameWidth = gameWidth
+	scale, target := fitIntegerScale(gameWidth, size.Y, frameSize.X, frameSize.Y)
+	options := &ebiten.DrawImageOptions{}
+	options.GeoM.Scale(float64(scale), float64(scale))
+	options.GeoM.Translate(float64(target.Min.X), float64(target.Min.Y))
+	screen.DrawImage(g.texture, options)
+
+	vector.DrawFilledRect(screen, float32(gameWidth), 0, pianoRollWidth, float32(size.Y), debuggerBackgroundColor, false)
+	for _, item := range g.session.layout() {
+		x := gameWidth + item.column*charWidth
+		y := item.row * lineHeight
+		if item.highlighted {
+			vector.DrawFilledRect(screen, float32(x), float32(y), float32(len(item.text)*charWidth), lineHeight, debuggerHighlightColor, false)
+		}
+		ebitenutil.DebugPrintAt(screen, item.text, x, y)
+	}
+
+	if message := g.toast.current(time.Now()); message != "" {
+		ebitenutil.DebugPrintAt(screen, message, 4, size.Y-20)
+	}
+}
+
+func (g *tasGame) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return outsideWidth, outsideHeight
+}
+
+/*
+RunTAS opens a window for editing the movie frame by frame, starting
+paused at its first frame, until the window is closed. The chip must have
+been created along with the movie by chip8.RecordMovie, or by the movie's
+NewChip, and not have run yet. New frames run a frame's worth of
+instructions at the config's execution rate. save is called to write the
+movie when F9 is pressed.
+*/
+func RunTAS(chip *chip8.Chip, movie *chip8.Movie, readOnly bool, save func() error, config Config) error {
+	mode := tasRecord
+	if readOnly {
+		mode = tasReadOnly
+	}
+	session, err := newTASSession(chip, movie, mode, config.ExecutionRateHz/frameRateHz)
+	if err != nil {
+		return err
+	}
+	game := &tasGame{
+		session:  session,
+		renderer: newFrameRenderer(newPersistenceFilter(PersistenceNone, 0, 0), config.ScaleFilter),
+		paused:   true,
+		save:     save,
+		keyHints: config.KeyHints,
+	}
+	if config.Foreground != (color.RGBA{}) {
+		game.renderer.foreground = config.Foreground
+	}
+	if config.Background != (color.RGBA{}) {
+		game.renderer.background = config.Background
+	}
+
+	scale := config.Scale
+	if scale < 1 {
+		scale = defaultScale / 2
+	}
+	width, height := len(chip.Pixels)*scale, len(chip.Pixels[0])*scale
+	if height < pianoRollRows*lineHeight {
+		height = pianoRollRows * lineHeight
+	}
+	ebiten.SetWindowSize(width+pianoRollWidth, height)
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	ebiten.SetWindowTitle("Chip8 TAS")
+	ebiten.SetTPS(frameRateHz)
+	if err := ebiten.RunGame(game); err != nil && !errors.Is(err, ebiten.Termination) {
+		log.Fatal(err)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package io
+
+import "time"
+
+const toastDuration = 2 * time.Second
+
+// toast is a short message shown on top of the game to confirm an action
+type toast struct {
+	message string
+	expires time.Time
+}
+
+func (t *toast) show(message string, now time.Time) {
+	t.message = message
+	t.expires = now.Add(toastDuration)
+}
+
+// Returns the message to display, or an empty string once it has expired
+func (t *toast) current(now time.Time) string {
+	if now.After(t.expires) {
+		return ""
+	}
+	return t.message
+}
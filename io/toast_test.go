@@ -0,0 +1,24 @@
+package io
+
+import (
+	"testing"
+	"time"
+)
+
+func TestToastExpires(t *testing.T) {
+	var toast toast
+	now := time.Unix(1000, 0)
+
+	if toast.current(now) != "" {
+		t.Error("Empty toast showed a message")
+	}
+
+	toast.show("Paused", now)
+
+	if toast.current(now.Add(time.Second)) != "Paused" {
+		t.Error("Toast message was not shown")
+	}
+	if toast.current(now.Add(toastDuration+time.Millisecond)) != "" {
+		t.Error("Toast message did not expire")
+	}
+}
@@ -2,22 +2,224 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/rdhillon1016/chip8-emulator/chip8"
 	"github.com/rdhillon1016/chip8-emulator/io"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "recompile":
+			recompile(os.Args[2:])
+			return
+		case "info":
+			info(os.Args[2:])
+			return
+		case "analyze":
+			analyze(os.Args[2:])
+			return
+		case "diff":
+			diff(os.Args[2:])
+			return
+		case "replay":
+			replay(os.Args[2:])
+			return
+		}
+	}
+
 	filePath := flag.String("filePath", "./roms/Tetris.ch8", "Location of ROM file (default is ./roms/Tetris.ch8)")
 	executionRateHz := flag.Int("executionRate", 700, "Execution rate of the chip in Hz (default is 700)")
+	persistence := flag.String("persistence", "none", "Flicker reduction filter: none, decay or blend (default is none)")
+	persistenceDecay := flag.Float64("decay", 0.6, "Fraction of brightness an unlit pixel keeps each frame with -persistence decay (default is 0.6)")
+	blendFrames := flag.Int("blendFrames", 2, "Number of frames OR-ed together with -persistence blend (default is 2)")
+	scale := flag.Int("scale", 16, "Initial window size as a multiple of the display resolution (default is 16)")
+	fullscreen := flag.Bool("fullscreen", false, "Start in fullscreen mode, toggled with F11 or Alt+Enter (default is false)")
+	scaleFilterName := flag.String("filter", "nearest", "Upscaling filter: nearest, scale2x, scale3x, hq2x, scanlines or dotmatrix (default is nearest)")
+	fontName := flag.String("font", "", "Name of a built-in font or location of a font file (default is the platform's font)")
+	platformName := flag.String("platform", "chip8", "Memory layout and quirks: chip8, vip, eti660, dream6800, hp48 or octo (default is chip8)")
+	memoryAccessName := flag.String("memoryAccess", "wrap", "What happens when I-indexed instructions reach past the end of memory: wrap, fault or clamp (default is wrap)")
+	unknownOpcodesName := flag.String("unknownOpcodes", "ignore", "What happens when the ROM executes an opcode that isn't implemented: ignore, log or fault (default is ignore)")
+	recordPath := flag.String("record", "", "Record the keys pressed in every frame into a movie file, which is written on exit")
+	playPath := flag.String("play", "", "Play back a movie file recorded with -record, instead of taking input from the keyboard")
+	tasPath := flag.String("tas", "", "Edit a movie file frame by frame with a piano roll, starting a new one if it doesn't exist yet")
+	hostAddress := flag.String("host", "", "Host a netplay game on an address such as :7000, and wait for another player to join")
+	joinAddress := flag.String("join", "", "Join a netplay game hosted on an address such as 192.168.1.2:7000")
+	network := flag.String("network", "tcp", "Protocol for netplay: tcp or udp (default is tcp)")
+	ownKeys := flag.String("ownKeys", "", "Keys this player controls in netplay, as hex digits such as CD. A host that doesn't set any controls the ones the guest doesn't")
+	inputDelay := flag.Int("inputDelay", 2, "Frames of input delay in netplay, which only the host's setting decides (default is 2)")
+	rollback := flag.Int("rollback", 0, "Frames netplay may run ahead of the other player's keys by guessing them, rolling back if the guess was wrong (default is 0)")
+	romDatabasePath := flag.String("romdb", "", "Location of a ROM database file whose entries take priority over the built-in ones")
 
 	flag.Parse()
 
+	persistenceMode, err := io.ParsePersistenceMode(*persistence)
+	if err != nil {
+		fail("%v", err)
+	}
+
+	scaleFilter, err := io.ParseScaleFilter(*scaleFilterName)
+	if err != nil {
+		fail("%v", err)
+	}
+
+	platform, err := chip8.ParsePlatform(*platformName)
+	if err != nil {
+		fail("%v", err)
+	}
+
+	memoryAccess, err := chip8.ParseMemoryAccessPolicy(*memoryAccessName)
+	if err != nil {
+		fail("%v", err)
+	}
+
+	unknownOpcodes, err := chip8.ParseUnknownOpcodePolicy(*unknownOpcodesName)
+	if err != nil {
+		fail("%v", err)
+	}
+
 	fileBytes, err := os.ReadFile(*filePath)
 	if err != nil {
-		panic("Unable to read game file")
+		fail("Unable to read ROM file: %v", err)
+	}
+
+	// Settings for ROMs in the database are applied unless they're set on the command line
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	var options []chip8.Option
+	config := io.Config{ExecutionRateHz: *executionRateHz}
+	metadata, known := lookupROM(fileBytes, *romDatabasePath)
+	if known {
+		fmt.Fprintf(os.Stderr, "Using the ROM database settings for %s\n", metadata.Title)
+		options = append(options, metadata.Options()...)
+		if metadata.CyclesPerFrame > 0 && !explicit["executionRate"] {
+			config.ExecutionRateHz = metadata.CyclesPerFrame * 60
+		}
+		config.Foreground = metadata.Foreground
+		config.Background = metadata.Background
+		config.KeyHints = metadata.Keys
+	}
+	if !known || explicit["platform"] {
+		options = append(options, chip8.WithPlatform(platform))
+	}
+	options = append(options, chip8.WithMemoryAccess(memoryAccess), chip8.WithUnknownOpcodes(unknownOpcodes))
+	if *fontName != "" {
+		options = append(options, chip8.WithFont(loadFont(*fontName)))
+	}
+
+	config.Persistence = persistenceMode
+	config.PersistenceDecay = *persistenceDecay
+	config.BlendFrames = *blendFrames
+	config.ScaleFilter = scaleFilter
+	config.Scale = *scale
+	config.Fullscreen = *fullscreen
+
+	if *hostAddress != "" || *joinAddress != "" {
+		playNetplay(netplayFlags{
+			network:     *network,
+			hostAddress: *hostAddress,
+			joinAddress: *joinAddress,
+			ownKeys:     *ownKeys,
+			inputDelay:  *inputDelay,
+			rollback:    *rollback,
+			recordPath:  *recordPath,
+		}, fileBytes, options, config)
+		return
+	}
+
+	var chip *chip8.Chip
+	// Set when editing a movie with -tas
+	var tasMovie *chip8.Movie
+	tasReadOnly := false
+	switch {
+	case *tasPath != "":
+		var data []byte
+		if data, err = os.ReadFile(*tasPath); err == nil {
+			// Existing movies are opened read-only so that they aren't changed by accident
+			tasReadOnly = true
+			tasMovie, err = chip8.ParseMovie(data)
+			if err != nil {
+				fail("Unable to load %s: %v", *tasPath, err)
+			}
+			chip, err = tasMovie.NewChip(fileBytes)
+		} else if os.IsNotExist(err) {
+			chip, tasMovie, err = chip8.RecordMovie(fileBytes, time.Now().UnixNano(), options...)
+		} else {
+			fail("Unable to read movie: %v", err)
+		}
+	case *playPath != "":
+		// The movie has all the settings it was recorded with
+		var data []byte
+		data, err = os.ReadFile(*playPath)
+		if err != nil {
+			fail("Unable to read movie: %v", err)
+		}
+		config.Play, err = chip8.ParseMovie(data)
+		if err != nil {
+			fail("Unable to load %s: %v", *playPath, err)
+		}
+		chip, err = config.Play.NewChip(fileBytes)
+	case *recordPath != "":
+		chip, config.Record, err = chip8.RecordMovie(fileBytes, time.Now().UnixNano(), options...)
+	default:
+		chip, err = chip8.NewChip(fileBytes, options...)
+	}
+	if err != nil {
+		fail("Unable to load %s: %v", *filePath, err)
+	}
+	for _, warning := range chip.ROMInfo().Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	if tasMovie != nil {
+		save := func() error {
+			return writeEditedMovie(tasMovie, fileBytes, *tasPath)
+		}
+		if err := io.RunTAS(chip, tasMovie, tasReadOnly, save, config); err != nil {
+			fail("Unable to edit %s: %v", *tasPath, err)
+		}
+		if err := save(); err != nil {
+			fail("Unable to write %s: %v", *tasPath, err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %d frames to %s\n", len(tasMovie.Frames), *tasPath)
+	} else {
+		io.Run(chip, config)
 	}
 
-	io.Run(chip8.NewChip(fileBytes), *executionRateHz)
+	if config.Record != nil {
+		data, err := config.Record.Marshal()
+		if err == nil {
+			err = os.WriteFile(*recordPath, data, 0644)
+		}
+		if err != nil {
+			fail("Unable to write %s: %v", *recordPath, err)
+		}
+		fmt.Fprintf(os.Stderr, "Recorded %d frames to %s\n", len(config.Record.Frames), *recordPath)
+	}
+
+	// Listed whatever the policy, since they usually mean the ROM was written for another interpreter or is broken
+	for _, opcode := range chip.UnknownOpcodes() {
+		fmt.Fprintf(os.Stderr, "Warning: %v, executed %d times\n", opcode, opcode.Count)
+	}
+}
+
+// Looks up a built-in font by name, falling back to reading it from a file
+func loadFont(nameOrPath string) chip8.Font {
+	if font, err := chip8.LookupFont(nameOrPath); err == nil {
+		return font
+	}
+	data, err := os.ReadFile(nameOrPath)
+	if err != nil {
+		fail("Font %q is neither a built-in font nor a readable file: %v", nameOrPath, err)
+	}
+	font, err := chip8.ParseFont(filepath.Base(nameOrPath), data)
+	if err != nil {
+		fail("Unable to load font %s: %v", nameOrPath, err)
+	}
+	return font
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+	"github.com/rdhillon1016/chip8-emulator/io"
+)
+
+// Settings from the command line for hosting or joining a netplay game
+type netplayFlags struct {
+	network     string
+	hostAddress string
+	joinAddress string
+	ownKeys     string
+	inputDelay  int
+	rollback    int
+	recordPath  string
+}
+
+// Hosts or joins a netplay game and plays it until the window is closed or the game ends
+func playNetplay(flags netplayFlags, rom []byte, options []chip8.Option, config io.Config) {
+	keys, err := parseKeys(flags.ownKeys)
+	if err != nil {
+		fail("Invalid -ownKeys: %v", err)
+	}
+	netplayConfig := chip8.NetplayConfig{
+		Keys:           keys,
+		InputDelay:     flags.inputDelay,
+		CyclesPerFrame: config.ExecutionRateHz / 60,
+		RollbackFrames: flags.rollback,
+	}
+
+	var netplay *chip8.Netplay
+	if flags.hostAddress != "" {
+		listener, err := chip8.ListenNetplay(flags.network, flags.hostAddress)
+		if err != nil {
+			fail("Unable to host a game: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Waiting for a player to join on %v\n", listener.Addr())
+		transport, err := listener.Accept()
+		listener.Close()
+		if err != nil {
+			fail("Unable to host a game: %v", err)
+		}
+		netplay, err = chip8.HostNetplay(transport, rom, time.Now().UnixNano(), netplayConfig, options...)
+		if err != nil {
+			fail("Unable to start the game: %v", err)
+		}
+	} else {
+		transport, err := chip8.DialNetplay(flags.network, flags.joinAddress)
+		if err != nil {
+			fail("Unable to join %s: %v", flags.joinAddress, err)
+		}
+		netplay, err = chip8.JoinNetplay(transport, rom, netplayConfig)
+		if err != nil {
+			fail("Unable to join %s: %v", flags.joinAddress, err)
+		}
+	}
+
+	gameErr := io.RunNetplay(netplay, config)
+	if flags.recordPath != "" {
+		data, err := netplay.Movie().Marshal()
+		if err == nil {
+			err = os.WriteFile(flags.recordPath, data, 0644)
+		}
+		if err != nil {
+			fail("Unable to write %s: %v", flags.recordPath, err)
+		}
+		fmt.Fprintf(os.Stderr, "Recorded %d frames to %s\n", netplay.Frame(), flags.recordPath)
+	}
+	if gameErr != nil {
+		fail("%v", gameErr)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+)
+
+// Usage: chip8 recompile rom.ch8 -o game.go
+func recompile(args []string) {
+	flags := flag.NewFlagSet("recompile", flag.ExitOnError)
+	output := flags.String("o", "", "Location of the generated Go file (default is standard output)")
+	packageName := flags.String("package", "main", "Name of the generated package (default is main)")
+	executionRateHz := flags.Int("executionRate", 700, "Execution rate of the generated game in Hz (default is 700)")
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 1 {
+		fail("usage: chip8 recompile rom.ch8 [-o game.go] [-package name]")
+	}
+
+	rom, err := os.ReadFile(positional[0])
+	if err != nil {
+		fail("Unable to read ROM file: %v", err)
+	}
+	source, err := chip8.Recompile(rom, chip8.RecompileOptions{
+		Package:         *packageName,
+		Source:          filepath.Base(positional[0]),
+		ExecutionRateHz: *executionRateHz,
+	})
+	if err != nil {
+		fail("Unable to recompile ROM: %v", err)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(source)
+		return
+	}
+	if err := os.WriteFile(*output, source, 0644); err != nil {
+		fail("Unable to write %s: %v", *output, err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rdhillon1016/chip8-emulator/chip8"
+)
+
+// Usage: chip8 replay movie.json rom.ch8
+func replay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	positional := parseInterspersed(flags, args)
+	if len(positional) != 2 {
+		fail("usage: chip8 replay movie.json rom.ch8")
+	}
+
+	data, err := os.ReadFile(positional[0])
+	if err != nil {
+		fail("Unable to read movie: %v", err)
+	}
+	movie, err := chip8.ParseMovie(data)
+	if err != nil {
+		fail("Unable to load %s: %v", positional[0], err)
+	}
+	rom, err := os.ReadFile(positional[1])
+	if err != nil {
+		fail("Unable to read ROM file: %v", err)
+	}
+	chip, err := movie.NewChip(rom)
+	if err != nil {
+		fail("Unable to load %s: %v", positional[1], err)
+	}
+
+	if err := movie.Replay(chip); err != nil {
+		fail("%v", err)
+	}
+	fmt.Printf("Replayed %d frames, and every state checksum matched\n", len(movie.Frames))
+}
+
+// Writes a movie whose frames may have been changed since they were run, recomputing its checksums first
+func writeEditedMovie(movie *chip8.Movie, rom []byte, path string) error {
+	if err := movie.UpdateChecksums(rom); err != nil {
+		return err
+	}
+	data, err := movie.Marshal()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}